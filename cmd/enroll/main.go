@@ -0,0 +1,104 @@
+// Command enroll enrolls a participant in a study and creates their initial schedules.
+//
+// Usage:
+//
+//	enroll -participant <id> -study <id> [-name <name>] [-timezone <IANA zone>]
+//
+// Every questionnaire of the study gets a pending schedule due at enrollment, and a new schedule message
+// is sent for each. Running the command again for the same participant is safe: nothing is created twice,
+// and the messages of the pending initial schedules are sent again.
+//
+// The database and the queues are selected with the same configuration as the rescheduler itself
+// (see the config package).
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"rescheduler/internals/clock"
+	"rescheduler/internals/config"
+	"rescheduler/internals/database"
+	"rescheduler/internals/idgen"
+	"rescheduler/internals/models"
+	"rescheduler/internals/rescheduler"
+	"rescheduler/internals/sqs"
+	"rescheduler/internals/store"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "Error: ", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	flags := flag.NewFlagSet("enroll", flag.ContinueOnError)
+	participant := models.Participant{}
+	flags.StringVar(&participant.ID, "participant", "", "ID of the participant, required")
+	flags.StringVar(&participant.StudyID, "study", "", "ID of the study, required")
+	flags.StringVar(&participant.Name, "name", "", "name of the participant")
+	flags.StringVar(&participant.TimeZone, "timezone", "", "IANA time zone of the participant, that of the study when empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if participant.ID == "" || participant.StudyID == "" {
+		flags.Usage()
+		return fmt.Errorf("-participant and -study are required")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	provider, err := database.NewCredentialProvider(cfg.Database, cfg.Region)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Handler)
+	defer cancel()
+
+	connectCtx, cancelConnect := context.WithTimeout(ctx, cfg.Timeouts.Database)
+	pool, err := database.OpenPool(connectCtx, cfg.Database.Dialect, provider, 0)
+	cancelConnect()
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	withDialect := store.WithDialect(cfg.Database.Dialect)
+	r, err := rescheduler.New(rescheduler.Stores{
+		Questionnaires:          store.NewQuestionnaireStore(pool.DB, withDialect),
+		ScheduledQuestionnaires: store.NewScheduledQuestionnaireStore(pool.DB, withDialect),
+		Participants:            store.NewParticipantStore(pool.DB, withDialect),
+		QuestionnaireResults:    store.NewQuestionnaireResultStore(pool.DB, withDialect),
+		Protocols:               store.NewStudyProtocolStore(pool.DB, withDialect),
+		Studies:                 store.NewStudyStore(pool.DB, withDialect),
+	}, sqs.NewSQSHandlerWithQueues(cfg.Queues.NewScheduleURL, cfg.Queues.CompletionURL, cfg.Region), clock.System{}, idgen.UUID{})
+	if err != nil {
+		return err
+	}
+
+	result, err := r.Enroll(ctx, &participant)
+	if err != nil {
+		return err
+	}
+
+	if result.Enrolled {
+		fmt.Println("Enrolled participant", result.Participant.ID, "in study", result.Participant.StudyID)
+	} else {
+		fmt.Println("Participant", result.Participant.ID, "was already enrolled in study", result.Participant.StudyID)
+	}
+	for _, schedule := range result.Created {
+		fmt.Println("Scheduled questionnaire", schedule.QuestionnaireID, "at", schedule.ScheduledAt, "as", schedule.ID)
+	}
+	for _, schedule := range result.Existing {
+		fmt.Println("Questionnaire", schedule.QuestionnaireID, "was already scheduled at", schedule.ScheduledAt, "as", schedule.ID)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+// Command migrate applies the embedded database schema migrations.
+//
+// Usage:
+//
+//	migrate up            apply every pending migration
+//	migrate down          roll back the most recently applied migration
+//	migrate status        list migrations and whether they are applied
+//	migrate to <version>  migrate up or down to the given version, 0 rolls back everything
+//
+// The database is selected with the same configuration as the rescheduler itself
+// (see the config package); the queue settings are not required.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"rescheduler/internals/config"
+	"rescheduler/internals/database"
+	"rescheduler/internals/migrate"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "Error: ", err)
+		os.Exit(1)
+	}
+}
+
+func usage() error {
+	return fmt.Errorf("usage: migrate up | down | status | to <version>")
+}
+
+func run(args []string) error {
+	if len(args) == 0 {
+		return usage()
+	}
+
+	cfg, err := config.LoadDatabase()
+	if err != nil {
+		return err
+	}
+
+	provider, err := database.NewCredentialProvider(cfg.Database, cfg.Region)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	connectCtx, cancel := context.WithTimeout(ctx, cfg.Timeouts.Database)
+	pool, err := database.OpenPool(connectCtx, cfg.Database.Dialect, provider, 0)
+	cancel()
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	migrator, err := migrate.New(pool.DB, cfg.Database.Dialect)
+	if err != nil {
+		return err
+	}
+
+	var done []migrate.Migration
+	switch args[0] {
+	case "up":
+		done, err = migrator.Up(ctx)
+	case "down":
+		done, err = migrator.Down(ctx)
+	case "to":
+		if len(args) != 2 {
+			return usage()
+		}
+		version, convErr := strconv.Atoi(args[1])
+		if convErr != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		done, err = migrator.To(ctx, version)
+	case "status":
+		return printStatus(ctx, migrator)
+	default:
+		return usage()
+	}
+
+	for _, migration := range done {
+		fmt.Printf("%s %04d_%s\n", args[0], migration.Version, migration.Name)
+	}
+	if err != nil {
+		return err
+	}
+	if len(done) == 0 {
+		fmt.Println("Nothing to migrate")
+	}
+	return nil
+}
+
+func printStatus(ctx context.Context, migrator *migrate.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, status := range statuses {
+		applied := "pending"
+		if status.Applied {
+			applied = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%04d_%-40s %s\n", status.Version, status.Name, applied)
+	}
+	return nil
+}
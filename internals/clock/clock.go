@@ -0,0 +1,61 @@
+// Package clock provides an injectable source of the current time.
+//
+// Production code depends on the Clock interface and is given System, tests are given a Fake
+// which only moves when told to. This makes schedule calculations deterministic and allows
+// expiry, reminder and daylight saving time edge cases to be exercised without sleeping.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock provides the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+// System is a Clock backed by time.Now.
+type System struct{}
+
+// Now returns the current UTC time.
+func (System) Now() time.Time { return time.Now().UTC() }
+
+// Fake is a Clock that only changes when Set or Advance is called.
+// It is safe for concurrent use.
+type Fake struct {
+	mu  sync.RWMutex
+	now time.Time
+}
+
+// NewFake creates a Fake clock reading the given time.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+// Now returns the current time of the fake clock.
+func (f *Fake) Now() time.Time {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.now
+}
+
+// Set moves the fake clock to the given time.
+func (f *Fake) Set(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = now
+}
+
+// Advance moves the fake clock forward by d and returns the new time.
+// The duration is elapsed time, so advancing across a daylight saving time
+// transition changes the wall clock reading by more or less than d.
+func (f *Fake) Advance(d time.Duration) time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = f.now.Add(d)
+	return f.now
+}
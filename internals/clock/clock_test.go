@@ -0,0 +1,48 @@
+// File: ./internals/clock/clock_test.go
+
+package clock
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestFake_Advance(t *testing.T) {
+	start := time.Date(2023, 12, 4, 2, 11, 0, 0, time.UTC)
+	fake := NewFake(start)
+
+	if got := fake.Now(); !got.Equal(start) {
+		t.Fatalf("Unexpected time.\nGot: %v\nExpected: %v", got, start)
+	}
+
+	if got := fake.Advance(36 * time.Hour); !got.Equal(start.Add(36 * time.Hour)) {
+		t.Fatalf("Unexpected time after Advance.\nGot: %v\nExpected: %v", got, start.Add(36*time.Hour))
+	}
+
+	fake.Set(start)
+	if got := fake.Now(); !got.Equal(start) {
+		t.Fatalf("Unexpected time after Set.\nGot: %v\nExpected: %v", got, start)
+	}
+}
+
+func TestFake_AdvanceAcrossDST(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatalf("Error loading location: %v", err)
+	}
+
+	// Clocks in London go forward from 01:00 to 02:00 on 26 March 2023: 24 hours after 09:00 reads 10:00.
+	start := time.Date(2023, 3, 25, 9, 0, 0, 0, london)
+	fake := NewFake(start)
+	fake.Advance(24 * time.Hour)
+
+	now := fake.Now()
+	expected := time.Date(2023, 3, 26, 10, 0, 0, 0, london)
+	if !now.Equal(expected) || now.Hour() != 10 || now.Location() != london {
+		t.Fatalf("Unexpected wall clock after DST transition.\nGot: %v\nExpected: %v", now, expected)
+	}
+	if elapsed := now.Sub(start); elapsed != 24*time.Hour {
+		t.Fatalf("Unexpected elapsed time.\nGot: %v\nExpected: %v", elapsed, 24*time.Hour)
+	}
+}
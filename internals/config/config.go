@@ -0,0 +1,365 @@
+// Package config loads the runtime configuration of the rescheduler.
+//
+// Configuration is assembled from, in increasing order of precedence:
+//
+//  1. built-in defaults,
+//  2. an optional YAML or JSON file named by RESCHEDULER_CONFIG_FILE,
+//  3. the section of that file matching the environment named by RESCHEDULER_ENV,
+//  4. environment variables.
+//
+// Load validates the result and returns a single error listing every missing or invalid key,
+// so a misconfigured deployment fails at startup rather than on the first event.
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"rescheduler/internals/dialect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Environment variables read by Load.
+const (
+	EnvConfigFile          = "RESCHEDULER_CONFIG_FILE"
+	EnvEnvironment         = "RESCHEDULER_ENV"
+	EnvRegion              = "AWS_REGION"
+	EnvNewScheduleQueueURL = "RESCHEDULER_NEW_SCHEDULE_QUEUE_URL"
+	EnvCompletionQueueURL  = "RESCHEDULER_COMPLETION_QUEUE_URL"
+	EnvDBDialect           = "RESCHEDULER_DB_DIALECT"
+	EnvDBHost              = "RESCHEDULER_DB_HOST"
+	EnvDBPort              = "RESCHEDULER_DB_PORT"
+	EnvDBName              = "RESCHEDULER_DB_NAME"
+	EnvDBUsername          = "RESCHEDULER_DB_USERNAME"
+	EnvDBPassword          = "RESCHEDULER_DB_PASSWORD"
+	EnvDBCredentials       = "RESCHEDULER_DB_CREDENTIALS"
+	EnvDBDSN               = "RESCHEDULER_DB_DSN"
+	EnvDBCredentialsFile   = "RESCHEDULER_DB_CREDENTIALS_FILE"
+	EnvDBSecretID          = "RESCHEDULER_DB_SECRET_ID"
+	EnvDBRotationInterval  = "RESCHEDULER_DB_ROTATION_INTERVAL"
+	EnvHandlerTimeout      = "RESCHEDULER_HANDLER_TIMEOUT"
+	EnvDBTimeout           = "RESCHEDULER_DB_TIMEOUT"
+
+	// EnvFeaturePrefix prefixes feature toggles, e.g. RESCHEDULER_FEATURE_DRY_RUN=true enables "dry_run".
+	EnvFeaturePrefix = "RESCHEDULER_FEATURE_"
+)
+
+// Config is the runtime configuration of the rescheduler.
+type Config struct {
+	// Environment is the name of the deployment environment, e.g. "production".
+	Environment string `yaml:"-"`
+
+	Region   string         `yaml:"region"`
+	Queues   QueueConfig    `yaml:"queues"`
+	Database DatabaseConfig `yaml:"database"`
+	Timeouts TimeoutConfig  `yaml:"timeouts"`
+
+	// Features holds named feature toggles. Unknown features are disabled.
+	Features map[string]bool `yaml:"features"`
+}
+
+// QueueConfig holds the SQS queue URLs messages are sent to.
+type QueueConfig struct {
+	NewScheduleURL string `yaml:"new_schedule_url"`
+	// CompletionURL defaults to NewScheduleURL when not set.
+	CompletionURL string `yaml:"completion_url"`
+}
+
+// Sources of database credentials, selected by DatabaseConfig.Credentials.
+const (
+	// CredentialsConfig uses Host, Port, Name, Username and Password from the configuration.
+	CredentialsConfig = "config"
+	// CredentialsEnv reads RESCHEDULER_DB_* variables each time the credentials are refreshed.
+	CredentialsEnv = "env"
+	// CredentialsDSN parses DSN.
+	CredentialsDSN = "dsn"
+	// CredentialsFile reads the JSON file at CredentialsFile.
+	CredentialsFile = "file"
+	// CredentialsSecretsManager reads the AWS Secrets Manager secret SecretID.
+	CredentialsSecretsManager = "secretsmanager"
+)
+
+// DatabaseConfig holds the database connection settings.
+type DatabaseConfig struct {
+	// Dialect selects the database, MySQL unless set. With SQLite, Name is the path of the database file.
+	Dialect dialect.Dialect `yaml:"dialect"`
+
+	// Credentials selects where the connection settings come from, one of the Credentials* constants.
+	Credentials string `yaml:"credentials"`
+
+	Host string `yaml:"host"`
+	// Port defaults to the default port of the dialect.
+	Port     string `yaml:"port"`
+	Name     string `yaml:"name"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+
+	DSN             string `yaml:"dsn"`
+	CredentialsFile string `yaml:"credentials_file"`
+	SecretID        string `yaml:"secret_id"`
+
+	// RotationInterval is how often credentials are refreshed, zero disables refreshing.
+	RotationInterval time.Duration `yaml:"rotation_interval"`
+}
+
+// TimeoutConfig holds the timeouts applied by the entry points.
+type TimeoutConfig struct {
+	// Handler bounds the processing of a single event.
+	Handler time.Duration `yaml:"handler"`
+	// Database bounds establishing the database connection.
+	Database time.Duration `yaml:"database"`
+}
+
+// file is the layout of the configuration file: a base Config plus per-environment overrides.
+type file struct {
+	Config       `yaml:",inline"`
+	Environments map[string]yaml.Node `yaml:"environments"`
+}
+
+// ValidationError lists every missing or invalid configuration key.
+type ValidationError struct {
+	Missing []string
+	Invalid []string
+}
+
+func (e *ValidationError) Error() string {
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, "missing required keys: "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.Invalid) > 0 {
+		parts = append(parts, "invalid values: "+strings.Join(e.Invalid, ", "))
+	}
+	return "invalid configuration: " + strings.Join(parts, "; ")
+}
+
+// Default returns the configuration used before any file or environment variable is applied.
+func Default() Config {
+	return Config{
+		Database: DatabaseConfig{Dialect: dialect.MySQL, Credentials: CredentialsConfig},
+		Timeouts: TimeoutConfig{
+			Handler:  30 * time.Second,
+			Database: 5 * time.Second,
+		},
+		Features: map[string]bool{},
+	}
+}
+
+// Load assembles the configuration from defaults, the optional configuration file and the environment,
+// and validates it. The returned error is a *ValidationError when keys are missing or invalid.
+func Load() (*Config, error) {
+	return load(true)
+}
+
+// LoadDatabase is like Load but only requires the keys needed to connect to the database.
+// It is used by tools, such as the migrate command, that never send messages.
+func LoadDatabase() (*Config, error) {
+	return load(false)
+}
+
+func load(requireQueues bool) (*Config, error) {
+	cfg := Default()
+	cfg.Environment = os.Getenv(EnvEnvironment)
+
+	if path := os.Getenv(EnvConfigFile); path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	verr := &ValidationError{}
+	cfg.applyEnv(verr)
+
+	if d, err := dialect.Parse(string(cfg.Database.Dialect)); err == nil {
+		cfg.Database.Dialect = d
+		if cfg.Database.Port == "" {
+			cfg.Database.Port = d.DefaultPort()
+		}
+	}
+	if cfg.Queues.CompletionURL == "" {
+		cfg.Queues.CompletionURL = cfg.Queues.NewScheduleURL
+	}
+	features := make(map[string]bool, len(cfg.Features))
+	for name, enabled := range cfg.Features {
+		features[strings.ToLower(name)] = enabled
+	}
+	cfg.Features = features
+
+	cfg.validate(verr, requireQueues)
+	if len(verr.Missing) > 0 || len(verr.Invalid) > 0 {
+		return nil, verr
+	}
+	return &cfg, nil
+}
+
+// Enabled reports whether the named feature toggle is on.
+func (c *Config) Enabled(feature string) bool {
+	return c.Features[strings.ToLower(feature)]
+}
+
+// loadFile applies the configuration file and the section of the current environment.
+// YAML is a superset of JSON so both formats are decoded the same way.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	f := file{Config: *c}
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("parsing config file %s: %w", filepath.Base(path), err)
+	}
+	*c = f.Config
+
+	if c.Environment == "" {
+		return nil
+	}
+	override, ok := f.Environments[c.Environment]
+	if !ok {
+		return nil
+	}
+	if err := override.Decode(c); err != nil {
+		return fmt.Errorf("parsing environment %q of config file %s: %w", c.Environment, filepath.Base(path), err)
+	}
+	return nil
+}
+
+// applyEnv overrides the configuration with environment variables.
+// Values that cannot be parsed are recorded on verr.
+func (c *Config) applyEnv(verr *ValidationError) {
+	values := map[string]*string{
+		EnvRegion:              &c.Region,
+		EnvNewScheduleQueueURL: &c.Queues.NewScheduleURL,
+		EnvCompletionQueueURL:  &c.Queues.CompletionURL,
+		EnvDBHost:              &c.Database.Host,
+		EnvDBPort:              &c.Database.Port,
+		EnvDBName:              &c.Database.Name,
+		EnvDBUsername:          &c.Database.Username,
+		EnvDBPassword:          &c.Database.Password,
+		EnvDBCredentials:       &c.Database.Credentials,
+		EnvDBDSN:               &c.Database.DSN,
+		EnvDBCredentialsFile:   &c.Database.CredentialsFile,
+		EnvDBSecretID:          &c.Database.SecretID,
+	}
+	for key, target := range values {
+		if value, ok := os.LookupEnv(key); ok {
+			*target = value
+		}
+	}
+	if value, ok := os.LookupEnv(EnvDBDialect); ok {
+		c.Database.Dialect = dialect.Dialect(value)
+	}
+
+	durations := map[string]*time.Duration{
+		EnvHandlerTimeout:     &c.Timeouts.Handler,
+		EnvDBTimeout:          &c.Timeouts.Database,
+		EnvDBRotationInterval: &c.Database.RotationInterval,
+	}
+	for key, target := range durations {
+		value, ok := os.LookupEnv(key)
+		if !ok {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			verr.Invalid = append(verr.Invalid, fmt.Sprintf("%s=%q (expected a duration such as 10s)", key, value))
+			continue
+		}
+		*target = d
+	}
+
+	if c.Features == nil {
+		c.Features = map[string]bool{}
+	}
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(key, EnvFeaturePrefix) {
+			continue
+		}
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			verr.Invalid = append(verr.Invalid, fmt.Sprintf("%s=%q (expected true or false)", key, value))
+			continue
+		}
+		c.Features[strings.ToLower(key[len(EnvFeaturePrefix):])] = enabled
+	}
+}
+
+// requiredKey pairs a configuration value with the name reported when it is missing.
+type requiredKey struct {
+	value string
+	key   string
+}
+
+// validate records every missing or invalid key on verr.
+func (c *Config) validate(verr *ValidationError, requireQueues bool) {
+	var required []requiredKey
+	if requireQueues {
+		required = append(required,
+			requiredKey{c.Region, EnvRegion + " (region)"},
+			requiredKey{c.Queues.NewScheduleURL, EnvNewScheduleQueueURL + " (queues.new_schedule_url)"},
+		)
+	}
+	if _, err := dialect.Parse(string(c.Database.Dialect)); err != nil {
+		verr.Invalid = append(verr.Invalid, fmt.Sprintf("%s (database.dialect)=%q (expected mysql, postgres or sqlite)", EnvDBDialect, c.Database.Dialect))
+	}
+	switch c.Database.Credentials {
+	case CredentialsConfig:
+		if c.Database.Dialect.Embedded() {
+			// The name is the path of the database file, there is no server to log in to.
+			required = append(required, requiredKey{c.Database.Name, EnvDBName + " (database.name)"})
+			break
+		}
+		required = append(required,
+			requiredKey{c.Database.Host, EnvDBHost + " (database.host)"},
+			requiredKey{c.Database.Port, EnvDBPort + " (database.port)"},
+			requiredKey{c.Database.Name, EnvDBName + " (database.name)"},
+			requiredKey{c.Database.Username, EnvDBUsername + " (database.username)"},
+		)
+	case CredentialsDSN:
+		required = append(required, requiredKey{c.Database.DSN, EnvDBDSN + " (database.dsn)"})
+	case CredentialsFile:
+		required = append(required, requiredKey{c.Database.CredentialsFile, EnvDBCredentialsFile + " (database.credentials_file)"})
+	case CredentialsSecretsManager:
+		required = append(required,
+			requiredKey{c.Region, EnvRegion + " (region)"},
+			requiredKey{c.Database.SecretID, EnvDBSecretID + " (database.secret_id)"},
+		)
+	case CredentialsEnv:
+		// Read from the environment when the pool refreshes.
+	default:
+		verr.Invalid = append(verr.Invalid, fmt.Sprintf("%s (database.credentials)=%q (expected one of config, env, dsn, file, secretsmanager)", EnvDBCredentials, c.Database.Credentials))
+	}
+	// A key may be required for several reasons, such as the region for the queues and for Secrets Manager,
+	// it is listed once.
+	seen := make(map[string]bool, len(required))
+	for _, r := range required {
+		if r.value == "" && !seen[r.key] {
+			seen[r.key] = true
+			verr.Missing = append(verr.Missing, r.key)
+		}
+	}
+
+	if c.Database.Credentials == CredentialsConfig && c.Database.Port != "" {
+		if port, err := strconv.Atoi(c.Database.Port); err != nil || port <= 0 || port > 65535 {
+			verr.Invalid = append(verr.Invalid, fmt.Sprintf("%s (database.port)=%q (expected a TCP port)", EnvDBPort, c.Database.Port))
+		}
+	}
+	if c.Timeouts.Handler <= 0 {
+		verr.Invalid = append(verr.Invalid, fmt.Sprintf("%s (timeouts.handler)=%s (expected a positive duration)", EnvHandlerTimeout, c.Timeouts.Handler))
+	}
+	if c.Database.RotationInterval < 0 {
+		verr.Invalid = append(verr.Invalid, fmt.Sprintf("%s (database.rotation_interval)=%s (expected zero or a positive duration)", EnvDBRotationInterval, c.Database.RotationInterval))
+	}
+	if c.Timeouts.Database <= 0 {
+		verr.Invalid = append(verr.Invalid, fmt.Sprintf("%s (timeouts.database)=%s (expected a positive duration)", EnvDBTimeout, c.Timeouts.Database))
+	}
+
+	sort.Strings(verr.Invalid)
+}
@@ -0,0 +1,258 @@
+// File: ./internals/config/config_test.go
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"rescheduler/internals/dialect"
+)
+
+func setRequiredEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv(EnvRegion, "eu-west-2")
+	t.Setenv(EnvNewScheduleQueueURL, "https://sqs.eu-west-2.amazonaws.com/1/schedules")
+	t.Setenv(EnvDBHost, "localhost")
+	t.Setenv(EnvDBName, "scheduled_questionnaires")
+	t.Setenv(EnvDBUsername, "rescheduler")
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Error writing config file: %v", err)
+	}
+	return path
+}
+
+func TestLoad_FromEnvironment(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv(EnvHandlerTimeout, "10s")
+	t.Setenv(EnvFeaturePrefix+"DRY_RUN", "true")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if cfg.Queues.CompletionURL != cfg.Queues.NewScheduleURL {
+		t.Fatalf("Expected the completion queue to default to the new schedule queue.\nGot: %s", cfg.Queues.CompletionURL)
+	}
+	if cfg.Database.Port != "3306" {
+		t.Fatalf("Unexpected default port: %s", cfg.Database.Port)
+	}
+	if cfg.Timeouts.Handler != 10*time.Second || cfg.Timeouts.Database != 5*time.Second {
+		t.Fatalf("Unexpected timeouts: %+v", cfg.Timeouts)
+	}
+	if !cfg.Enabled("dry_run") || cfg.Enabled("unknown") {
+		t.Fatalf("Unexpected feature toggles: %+v", cfg.Features)
+	}
+}
+
+func TestLoad_PostgresDefaultPort(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv(EnvDBDialect, "postgresql")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.Database.Dialect != dialect.Postgres || cfg.Database.Port != "5432" {
+		t.Fatalf("Unexpected database config.\nGot: %s on port %s\nExpected: postgres on port 5432", cfg.Database.Dialect, cfg.Database.Port)
+	}
+}
+
+func TestLoad_SQLite(t *testing.T) {
+	t.Setenv(EnvRegion, "eu-west-2")
+	t.Setenv(EnvNewScheduleQueueURL, "https://sqs.eu-west-2.amazonaws.com/1/schedules")
+	t.Setenv(EnvDBDialect, "sqlite")
+	t.Setenv(EnvDBName, "rescheduler.db")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Expected no host or username to be required for SQLite: %v", err)
+	}
+	if cfg.Database.Dialect != dialect.SQLite || cfg.Database.Port != "" {
+		t.Fatalf("Unexpected database config.\nGot: %+v", cfg.Database)
+	}
+}
+
+func TestLoad_MissingKeys(t *testing.T) {
+	t.Setenv(EnvRegion, "")
+	t.Setenv(EnvDBPort, "not-a-port")
+	t.Setenv(EnvDBTimeout, "soon")
+	t.Setenv(EnvDBDialect, "oracle")
+
+	_, err := Load()
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Expected a *ValidationError, got %T: %v", err, err)
+	}
+
+	expectedMissing := []string{
+		EnvRegion + " (region)",
+		EnvNewScheduleQueueURL + " (queues.new_schedule_url)",
+		EnvDBHost + " (database.host)",
+		EnvDBName + " (database.name)",
+		EnvDBUsername + " (database.username)",
+	}
+	if !reflect.DeepEqual(verr.Missing, expectedMissing) {
+		t.Fatalf("Unexpected missing keys.\nGot: %v\nExpected: %v", verr.Missing, expectedMissing)
+	}
+	if len(verr.Invalid) != 3 {
+		t.Fatalf("Expected the dialect, the port and the timeout to be reported as invalid, got %v", verr.Invalid)
+	}
+}
+
+func TestLoad_FileWithEnvironmentOverride(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name: "YAML",
+			file: "config.yaml",
+			content: `
+region: eu-west-2
+queues:
+  new_schedule_url: https://sqs/schedules
+  completion_url: https://sqs/completions
+database:
+  host: localhost
+  name: scheduled_questionnaires
+  username: rescheduler
+timeouts:
+  handler: 15s
+features:
+  Dry_Run: true
+environments:
+  production:
+    database:
+      host: prod.db.internal
+    timeouts:
+      database: 2s
+`,
+		},
+		{
+			name: "JSON",
+			file: "config.json",
+			content: `{
+	"region": "eu-west-2",
+	"queues": {"new_schedule_url": "https://sqs/schedules", "completion_url": "https://sqs/completions"},
+	"database": {"host": "localhost", "name": "scheduled_questionnaires", "username": "rescheduler"},
+	"timeouts": {"handler": "15s"},
+	"features": {"dry_run": true},
+	"environments": {"production": {"database": {"host": "prod.db.internal"}, "timeouts": {"database": "2s"}}}
+}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(EnvConfigFile, writeFile(t, tt.file, tt.content))
+			t.Setenv(EnvEnvironment, "production")
+			t.Setenv(EnvDBPassword, "from-env")
+
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			expected := DatabaseConfig{Dialect: dialect.MySQL, Credentials: CredentialsConfig, Host: "prod.db.internal", Port: "3306", Name: "scheduled_questionnaires", Username: "rescheduler", Password: "from-env"}
+			if cfg.Database != expected {
+				t.Fatalf("Unexpected database config.\nGot: %+v\nExpected: %+v", cfg.Database, expected)
+			}
+			if cfg.Timeouts.Handler != 15*time.Second || cfg.Timeouts.Database != 2*time.Second {
+				t.Fatalf("Unexpected timeouts: %+v", cfg.Timeouts)
+			}
+			if cfg.Queues.CompletionURL != "https://sqs/completions" {
+				t.Fatalf("Unexpected completion queue: %s", cfg.Queues.CompletionURL)
+			}
+			if !cfg.Enabled("DRY_RUN") {
+				t.Fatalf("Expected dry_run to be enabled: %+v", cfg.Features)
+			}
+		})
+	}
+}
+
+func TestLoad_UnreadableFile(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv(EnvConfigFile, filepath.Join(t.TempDir(), "missing.yaml"))
+
+	if _, err := Load(); err == nil {
+		t.Fatalf("Expected an error for a missing config file")
+	}
+}
+
+func TestLoad_CredentialSources(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		wantMissing []string
+	}{
+		{
+			name: "DSN",
+			env:  map[string]string{EnvDBCredentials: CredentialsDSN, EnvDBDSN: "user:pw@tcp(localhost:3306)/db"},
+		},
+		{
+			name:        "DSN missing",
+			env:         map[string]string{EnvDBCredentials: CredentialsDSN},
+			wantMissing: []string{EnvDBDSN + " (database.dsn)"},
+		},
+		{
+			name:        "Secrets Manager missing secret",
+			env:         map[string]string{EnvDBCredentials: CredentialsSecretsManager, EnvDBRotationInterval: "15m"},
+			wantMissing: []string{EnvDBSecretID + " (database.secret_id)"},
+		},
+		{
+			name:        "Secrets Manager missing region",
+			env:         map[string]string{EnvDBCredentials: CredentialsSecretsManager, EnvDBSecretID: "rescheduler/db", EnvRegion: ""},
+			wantMissing: []string{EnvRegion + " (region)"},
+		},
+		{
+			name: "Environment",
+			env:  map[string]string{EnvDBCredentials: CredentialsEnv},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(EnvRegion, "eu-west-2")
+			t.Setenv(EnvNewScheduleQueueURL, "https://sqs/schedules")
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			_, err := Load()
+			if tt.wantMissing == nil {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				return
+			}
+			verr, ok := err.(*ValidationError)
+			if !ok || !reflect.DeepEqual(verr.Missing, tt.wantMissing) {
+				t.Fatalf("Unexpected error.\nGot: %v\nExpected missing: %v", err, tt.wantMissing)
+			}
+		})
+	}
+}
+
+func TestLoadDatabase_SkipsQueues(t *testing.T) {
+	t.Setenv(EnvDBHost, "localhost")
+	t.Setenv(EnvDBName, "scheduled_questionnaires")
+	t.Setenv(EnvDBUsername, "rescheduler")
+
+	if _, err := Load(); err == nil {
+		t.Fatalf("Expected Load to require the queue settings")
+	}
+	if _, err := LoadDatabase(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
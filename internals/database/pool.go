@@ -0,0 +1,149 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+	"time"
+
+	"rescheduler/internals/dialect"
+)
+
+// Pool is a connection pool whose credentials come from a CredentialProvider.
+//
+// Every new physical connection is opened with the most recently fetched credentials.
+// When a rotation interval is set, credentials are refreshed in the background and pooled
+// connections are recycled after the same interval, so a rotated password is picked up by
+// a long lived process (or a warm Lambda container) without reopening the pool.
+// A login rejected with "access denied" triggers an immediate refresh and one retry.
+type Pool struct {
+	DB *sql.DB
+
+	connector *rotatingConnector
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// OpenPool fetches the initial credentials, opens a pool for the dialect and verifies it with a ping.
+// A rotationInterval of zero disables background refreshes.
+func OpenPool(ctx context.Context, d dialect.Dialect, provider CredentialProvider, rotationInterval time.Duration) (*Pool, error) {
+	connector := &rotatingConnector{dialect: d, provider: provider}
+	if err := connector.refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	db := sql.OpenDB(connector)
+	configure(db, d)
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("Error pinging database: %v", err)
+	}
+
+	pool := &Pool{DB: db, connector: connector}
+	if rotationInterval > 0 {
+		db.SetConnMaxLifetime(rotationInterval)
+		pool.stop = make(chan struct{})
+		pool.done = make(chan struct{})
+		go pool.rotate(rotationInterval)
+	}
+
+	return pool, nil
+}
+
+// Credentials returns the credentials new connections are currently opened with.
+func (p *Pool) Credentials() DatabaseConnection {
+	creds, _ := p.connector.current()
+	return creds
+}
+
+// Refresh fetches the credentials from the provider now.
+func (p *Pool) Refresh(ctx context.Context) error {
+	return p.connector.refresh(ctx)
+}
+
+// Close stops the background refresh and closes the pool.
+func (p *Pool) Close() error {
+	if p.stop != nil {
+		close(p.stop)
+		<-p.done
+	}
+	return p.DB.Close()
+}
+
+func (p *Pool) rotate(interval time.Duration) {
+	defer close(p.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			if err := p.connector.refresh(ctx); err != nil {
+				// Keep using the previous credentials, the next tick retries.
+				fmt.Println("Error refreshing database credentials: ", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// rotatingConnector is a driver.Connector that opens connections with the latest credentials.
+type rotatingConnector struct {
+	dialect  dialect.Dialect
+	provider CredentialProvider
+
+	mu    sync.RWMutex
+	creds DatabaseConnection
+	base  driver.Connector
+}
+
+func (c *rotatingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	_, base := c.current()
+	conn, err := base.Connect(ctx)
+
+	if accessDenied(err) {
+		if refreshErr := c.refresh(ctx); refreshErr != nil {
+			return nil, err
+		}
+		_, base = c.current()
+		return base.Connect(ctx)
+	}
+	return conn, err
+}
+
+func (c *rotatingConnector) Driver() driver.Driver {
+	_, base := c.current()
+	return base.Driver()
+}
+
+func (c *rotatingConnector) current() (DatabaseConnection, driver.Connector) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.creds, c.base
+}
+
+func (c *rotatingConnector) refresh(ctx context.Context) error {
+	creds, err := c.provider.Credentials(ctx)
+	if err != nil {
+		return err
+	}
+
+	base, err := newConnector(c.dialect, creds)
+	if err != nil {
+		return fmt.Errorf("Error opening database: %v", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.creds = creds
+	c.base = base
+	return nil
+}
@@ -0,0 +1,91 @@
+// Package dialect describes the SQL databases the rescheduler can run on.
+//
+// Queries in the store and migrate packages are written once with "?" placeholders,
+// the MySQL style, and converted with Rebind for databases that number their placeholders.
+// Everything that cannot be written portably, such as the schema itself, lives in
+// per-dialect migrations.
+package dialect
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Dialect names a supported database.
+type Dialect string
+
+const (
+	// MySQL is MySQL 8 or Aurora MySQL, the default.
+	MySQL Dialect = "mysql"
+	// Postgres is PostgreSQL or Aurora PostgreSQL.
+	Postgres Dialect = "postgres"
+	// SQLite is an embedded single file database, for local development and tests.
+	SQLite Dialect = "sqlite"
+)
+
+// All returns every supported dialect.
+func All() []Dialect {
+	return []Dialect{MySQL, Postgres, SQLite}
+}
+
+// Parse returns the dialect with the given name. The empty name is MySQL.
+func Parse(name string) (Dialect, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", string(MySQL):
+		return MySQL, nil
+	case string(Postgres), "postgresql":
+		return Postgres, nil
+	case string(SQLite), "sqlite3":
+		return SQLite, nil
+	default:
+		return "", fmt.Errorf("unknown database dialect %q", name)
+	}
+}
+
+// DriverName returns the database/sql driver name registered for the dialect.
+func (d Dialect) DriverName() string {
+	return string(d)
+}
+
+// DefaultPort returns the port the database listens on by default, empty for SQLite which has no server.
+func (d Dialect) DefaultPort() string {
+	switch d {
+	case Postgres:
+		return "5432"
+	case SQLite:
+		return ""
+	default:
+		return "3306"
+	}
+}
+
+// Embedded reports whether the database is a local file rather than a server.
+func (d Dialect) Embedded() bool {
+	return d == SQLite
+}
+
+// Rebind converts the "?" placeholders of query to the style of the dialect,
+// "$1", "$2", ... for PostgreSQL. Question marks inside single quoted literals are left alone.
+func (d Dialect) Rebind(query string) string {
+	if d != Postgres || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var rebound strings.Builder
+	rebound.Grow(len(query) + 8)
+
+	n, quoted := 0, false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case r == '?' && !quoted:
+			n++
+			rebound.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		rebound.WriteRune(r)
+	}
+	return rebound.String()
+}
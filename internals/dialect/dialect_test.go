@@ -0,0 +1,56 @@
+// File: ./internals/dialect/dialect_test.go
+
+package dialect
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected Dialect
+		wantErr  bool
+	}{
+		{name: "", expected: MySQL},
+		{name: "mysql", expected: MySQL},
+		{name: "Postgres", expected: Postgres},
+		{name: "postgresql", expected: Postgres},
+		{name: "sqlite3", expected: SQLite},
+		{name: "oracle", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.name)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got %q", got)
+				}
+				return
+			}
+			if err != nil || got != tt.expected {
+				t.Fatalf("Unexpected dialect.\nGot: %q, %v\nExpected: %q", got, err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRebind(t *testing.T) {
+	query := "SELECT id FROM scheduled_questionnaires WHERE questionnaire_id = ? AND status = ? AND name <> 'why?'"
+
+	tests := []struct {
+		dialect  Dialect
+		expected string
+	}{
+		{dialect: MySQL, expected: query},
+		{dialect: SQLite, expected: query},
+		{dialect: Postgres, expected: "SELECT id FROM scheduled_questionnaires WHERE questionnaire_id = $1 AND status = $2 AND name <> 'why?'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.dialect), func(t *testing.T) {
+			if got := tt.dialect.Rebind(query); got != tt.expected {
+				t.Fatalf("Unexpected query.\nGot: %s\nExpected: %s", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+// Package idgen provides injectable generators for record identifiers.
+//
+// Production code uses UUID, tests use Sequence to get predictable identifiers
+// that can be asserted on and used in golden files.
+package idgen
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Generator provides unique identifiers for newly created records.
+type Generator interface {
+	NewID() string
+}
+
+// UUID is a Generator producing random (version 4) UUIDs.
+type UUID struct{}
+
+// NewID returns a new random UUID string.
+func (UUID) NewID() string { return uuid.New().String() }
+
+// Sequence is a Generator producing "<prefix>-1", "<prefix>-2", ...
+// It is safe for concurrent use.
+type Sequence struct {
+	mu     sync.Mutex
+	prefix string
+	n      int
+}
+
+// NewSequence creates a Sequence generator with the given prefix.
+func NewSequence(prefix string) *Sequence {
+	return &Sequence{prefix: prefix}
+}
+
+// NewID returns the next identifier of the sequence.
+func (s *Sequence) NewID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.n++
+	return fmt.Sprintf("%s-%d", s.prefix, s.n)
+}
@@ -0,0 +1,29 @@
+// File: ./internals/idgen/idgen_test.go
+
+package idgen
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSequence_NewID(t *testing.T) {
+	seq := NewSequence("schedule")
+
+	for _, expected := range []string{"schedule-1", "schedule-2", "schedule-3"} {
+		if got := seq.NewID(); got != expected {
+			t.Fatalf("Unexpected ID.\nGot: %s\nExpected: %s", got, expected)
+		}
+	}
+}
+
+func TestUUID_NewID(t *testing.T) {
+	id := UUID{}.NewID()
+	if _, err := uuid.Parse(id); err != nil {
+		t.Fatalf("Expected a valid UUID, got %q: %v", id, err)
+	}
+	if id == (UUID{}).NewID() {
+		t.Fatalf("Expected two different UUIDs")
+	}
+}
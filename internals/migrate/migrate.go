@@ -0,0 +1,362 @@
+// Package migrate applies the versioned database schema migrations of the rescheduler.
+//
+// Migrations are embedded SQL files named <version>_<name>.up.sql and <version>_<name>.down.sql,
+// in one directory per dialect: migrations/mysql, migrations/postgres and migrations/sqlite.
+// Every dialect has the same versions, so a version number describes the same schema on every database.
+//
+// Applied migrations are recorded in the schema_migrations table together with the SHA-256 checksum
+// of their up script. Every operation first verifies those checksums, so a migration edited after
+// it has been applied is reported instead of silently diverging from the database.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"rescheduler/internals/clock"
+	"rescheduler/internals/dialect"
+	"rescheduler/internals/timestamp"
+)
+
+//go:embed migrations/*/*.sql
+var embedded embed.FS
+
+// Migration is a single versioned schema change.
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// Status reports whether a migration has been applied.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt timestamp.TimeStamp
+}
+
+// ChecksumError is returned when an applied migration no longer matches its file.
+type ChecksumError struct {
+	Version  int
+	Name     string
+	Applied  string
+	Expected string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("migration %04d_%s has been edited after it was applied (checksum %s, file %s)", e.Version, e.Name, e.Applied, e.Expected)
+}
+
+// ErrUnknownVersion is returned when the database records a migration that is not embedded,
+// or a target version that does not exist is requested.
+var ErrUnknownVersion = errors.New("unknown migration version")
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migrations returns the embedded migrations of the dialect ordered by version.
+func Migrations(d dialect.Dialect) ([]Migration, error) {
+	sub, err := fs.Sub(embedded, "migrations/"+string(d))
+	if err != nil {
+		return nil, err
+	}
+	return Load(sub)
+}
+
+// Load reads the migrations in the root of fsys ordered by version.
+// Every version must have both an up and a down script.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %04d has two names: %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(content)
+			sum := sha256.Sum256(content)
+			migration.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down script", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Migrator applies migrations to a database.
+type Migrator struct {
+	db         *sql.DB
+	dialect    dialect.Dialect
+	migrations []Migration
+	clock      clock.Clock
+}
+
+// New creates a Migrator for the embedded migrations of the dialect.
+func New(db *sql.DB, d dialect.Dialect) (*Migrator, error) {
+	migrations, err := Migrations(d)
+	if err != nil {
+		return nil, err
+	}
+	return NewWithMigrations(db, d, migrations), nil
+}
+
+// NewWithMigrations creates a Migrator for the given migrations, which must be ordered by version.
+func NewWithMigrations(db *sql.DB, d dialect.Dialect, migrations []Migration) *Migrator {
+	return &Migrator{db: db, dialect: d, migrations: migrations, clock: clock.System{}}
+}
+
+// Latest returns the highest known migration version, 0 when there are none.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Status lists every known migration and whether it has been applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		row, ok := applied[migration.Version]
+		statuses = append(statuses, Status{Migration: migration, Applied: ok, AppliedAt: row.appliedAt})
+	}
+	return statuses, nil
+}
+
+// Up applies every pending migration and returns the migrations applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	return m.To(ctx, m.Latest())
+}
+
+// Down rolls back the most recently applied migration and returns it, nothing is returned when no migration is applied.
+func (m *Migrator) Down(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	current, previous := 0, 0
+	for version := range applied {
+		if version > current {
+			current = version
+		}
+	}
+	for version := range applied {
+		if version < current && version > previous {
+			previous = version
+		}
+	}
+	if current == 0 {
+		return nil, nil
+	}
+	return m.To(ctx, previous)
+}
+
+// To migrates up or down so that exactly the migrations up to and including version are applied.
+// Version 0 rolls back every migration. It returns the migrations applied or rolled back, in order.
+func (m *Migrator) To(ctx context.Context, version int) ([]Migration, error) {
+	if version != 0 && m.find(version) == nil {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	// Roll back from the newest applied migration down to the target.
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok || migration.Version <= version {
+			continue
+		}
+		if err := m.run(ctx, migration, false); err != nil {
+			return done, err
+		}
+		done = append(done, migration)
+	}
+	// Apply everything missing up to the target.
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok || migration.Version > version {
+			continue
+		}
+		if err := m.run(ctx, migration, true); err != nil {
+			return done, err
+		}
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+type appliedRow struct {
+	checksum  string
+	appliedAt timestamp.TimeStamp
+}
+
+// applied creates the schema_migrations table if needed, reads it and verifies the checksums.
+func (m *Migrator) applied(ctx context.Context) (map[int]appliedRow, error) {
+	dateTime := "DATETIME"
+	if m.dialect == dialect.Postgres {
+		dateTime = "TIMESTAMP"
+	}
+	_, err := m.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INT PRIMARY KEY NOT NULL,
+    name VARCHAR(128) NOT NULL,
+    checksum CHAR(64) NOT NULL,
+    applied_at `+dateTime+` NOT NULL
+)`)
+	if err != nil {
+		return nil, fmt.Errorf("creating schema_migrations: %w", err)
+	}
+
+	rows, err := m.db.QueryContext(ctx, "SELECT version, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("reading schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := map[int]appliedRow{}
+	for rows.Next() {
+		var version int
+		var row appliedRow
+		if err := rows.Scan(&version, &row.checksum, &row.appliedAt); err != nil {
+			return nil, fmt.Errorf("reading schema_migrations: %w", err)
+		}
+		applied[version] = row
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading schema_migrations: %w", err)
+	}
+
+	for version, row := range applied {
+		migration := m.find(version)
+		if migration == nil {
+			return nil, fmt.Errorf("%w: %d is recorded in schema_migrations", ErrUnknownVersion, version)
+		}
+		if migration.Checksum != row.checksum {
+			return nil, &ChecksumError{Version: version, Name: migration.Name, Applied: row.checksum, Expected: migration.Checksum}
+		}
+	}
+
+	return applied, nil
+}
+
+// run applies or rolls back a single migration and records it.
+// Databases with transactional DDL, such as PostgreSQL, roll back a failed migration completely;
+// MySQL commits each DDL statement implicitly, so a failure leaves the statements that already ran.
+func (m *Migrator) run(ctx context.Context, migration Migration, up bool) error {
+	script, direction := migration.Down, "down"
+	if up {
+		script, direction = migration.Up, "up"
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range Statements(script) {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("migration %04d_%s %s: %w", migration.Version, migration.Name, direction, err)
+		}
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx,
+			m.dialect.Rebind("INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)"),
+			migration.Version, migration.Name, migration.Checksum, timestamp.TimeStamp{Time: m.clock.Now()},
+		)
+	} else {
+		_, err = tx.ExecContext(ctx, m.dialect.Rebind("DELETE FROM schema_migrations WHERE version = ?"), migration.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("recording migration %04d_%s %s: %w", migration.Version, migration.Name, direction, err)
+	}
+
+	return tx.Commit()
+}
+
+func (m *Migrator) find(version int) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+// Statements splits a script into individual statements on semicolons ending a line.
+// Lines starting with "--" are comments and are dropped.
+func Statements(script string) []string {
+	var statements []string
+	var current strings.Builder
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+
+		if strings.HasSuffix(trimmed, ";") {
+			statement := strings.TrimSuffix(strings.TrimSpace(current.String()), ";")
+			statements = append(statements, statement)
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+
+	return statements
+}
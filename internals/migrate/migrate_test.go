@@ -0,0 +1,202 @@
+// File: ./internals/migrate/migrate_test.go
+
+package migrate
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"rescheduler/internals/database"
+	"rescheduler/internals/dialect"
+)
+
+func TestMigrations_Embedded(t *testing.T) {
+	var first []Migration
+	for _, d := range dialect.All() {
+		t.Run(string(d), func(t *testing.T) {
+			migrations, err := Migrations(d)
+			if err != nil {
+				t.Fatalf("Error loading embedded migrations: %v", err)
+			}
+			if len(migrations) == 0 || migrations[0].Version != 1 || migrations[0].Name != "initial" {
+				t.Fatalf("Expected 0001_initial to be the first migration, got %+v", migrations)
+			}
+			for i, migration := range migrations {
+				if migration.Version != i+1 {
+					t.Fatalf("Expected consecutive versions, migration %d has version %d", i+1, migration.Version)
+				}
+				if len(Statements(migration.Up)) == 0 || len(Statements(migration.Down)) == 0 {
+					t.Fatalf("Migration %04d_%s has an empty script", migration.Version, migration.Name)
+				}
+			}
+
+			// Every dialect must have the same versions and names as the first one.
+			if first == nil {
+				first = migrations
+				return
+			}
+			if len(migrations) != len(first) {
+				t.Fatalf("Unexpected number of migrations.\nGot: %d\nExpected: %d", len(migrations), len(first))
+			}
+			for i := range migrations {
+				if migrations[i].Version != first[i].Version || migrations[i].Name != first[i].Name {
+					t.Fatalf("Unexpected migration.\nGot: %04d_%s\nExpected: %04d_%s", migrations[i].Version, migrations[i].Name, first[i].Version, first[i].Name)
+				}
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_second.up.sql":   {Data: []byte("CREATE TABLE b (id INT);")},
+		"0002_second.down.sql": {Data: []byte("DROP TABLE b;")},
+		"0001_first.up.sql":    {Data: []byte("CREATE TABLE a (id INT);")},
+		"0001_first.down.sql":  {Data: []byte("DROP TABLE a;")},
+		"README.md":            {Data: []byte("ignored")},
+	}
+
+	migrations, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(migrations) != 2 || migrations[0].Name != "first" || migrations[1].Name != "second" {
+		t.Fatalf("Unexpected migrations: %+v", migrations)
+	}
+
+	// The checksum covers the up script, editing it must change the checksum.
+	edited := fstest.MapFS{
+		"0001_first.up.sql":   {Data: []byte("CREATE TABLE a (id BIGINT);")},
+		"0001_first.down.sql": {Data: []byte("DROP TABLE a;")},
+	}
+	editedMigrations, err := Load(edited)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if editedMigrations[0].Checksum == migrations[0].Checksum {
+		t.Fatalf("Expected the checksum to change when the up script is edited")
+	}
+}
+
+func TestLoad_MissingDown(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0001_first.up.sql": {Data: []byte("CREATE TABLE a (id INT);")},
+	}
+
+	if _, err := Load(fsys); err == nil {
+		t.Fatalf("Expected an error for a migration without a down script")
+	}
+}
+
+func TestStatements(t *testing.T) {
+	script := `-- Participants
+CREATE TABLE participants (
+    id VARCHAR(128) PRIMARY KEY NOT NULL
+);
+
+-- Index
+CREATE INDEX idx ON participants (id);
+DROP TABLE old`
+
+	expected := []string{
+		"CREATE TABLE participants (\n    id VARCHAR(128) PRIMARY KEY NOT NULL\n)",
+		"CREATE INDEX idx ON participants (id)",
+		"DROP TABLE old",
+	}
+	if got := Statements(script); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Unexpected statements.\nGot: %q\nExpected: %q", got, expected)
+	}
+}
+
+func TestMigrator_SQLite(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.Open(ctx, dialect.SQLite, database.DatabaseConnection{Database: filepath.Join(t.TempDir(), "rescheduler.db")})
+	if err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	defer db.Close()
+
+	migrator, err := New(db, dialect.SQLite)
+	if err != nil {
+		t.Fatalf("Error loading migrations: %v", err)
+	}
+
+	applied, err := migrator.Up(ctx)
+	if err != nil || len(applied) != migrator.Latest() {
+		t.Fatalf("Unexpected result of up.\nGot: %d migrations, %v\nExpected: %d migrations", len(applied), err, migrator.Latest())
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, status := range statuses {
+		if !status.Applied || status.AppliedAt.IsZero() {
+			t.Fatalf("Expected every migration to be applied, got %+v", status)
+		}
+	}
+
+	// Roll everything back and apply it again, exercising every down script.
+	if _, err := migrator.To(ctx, 0); err != nil {
+		t.Fatalf("Error rolling back: %v", err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Error migrating again: %v", err)
+	}
+	if applied, err := migrator.Up(ctx); err != nil || len(applied) != 0 {
+		t.Fatalf("Expected nothing to migrate.\nGot: %d migrations, %v", len(applied), err)
+	}
+}
+
+func TestMigrator_SQLiteEditedMigration(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.Open(ctx, dialect.SQLite, database.DatabaseConnection{Database: filepath.Join(t.TempDir(), "rescheduler.db")})
+	if err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	defer db.Close()
+
+	// The embedded SQLite migrations, copied so that one of them can be edited once applied.
+	embeddedFS, err := fs.Sub(embedded, "migrations/"+string(dialect.SQLite))
+	if err != nil {
+		t.Fatalf("Error reading embedded migrations: %v", err)
+	}
+	fsys := fstest.MapFS{}
+	err = fs.WalkDir(embeddedFS, ".", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		content, err := fs.ReadFile(embeddedFS, path)
+		fsys[path] = &fstest.MapFile{Data: content}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Error copying embedded migrations: %v", err)
+	}
+
+	migrations, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("Error loading migrations: %v", err)
+	}
+	if _, err := NewWithMigrations(db, dialect.SQLite, migrations).Up(ctx); err != nil {
+		t.Fatalf("Error migrating: %v", err)
+	}
+
+	const edited = "0009_studies.up.sql"
+	fsys[edited].Data = append(fsys[edited].Data, []byte("\n-- Edited after it was applied.\n")...)
+	migrations, err = Load(fsys)
+	if err != nil {
+		t.Fatalf("Error loading edited migrations: %v", err)
+	}
+
+	_, err = NewWithMigrations(db, dialect.SQLite, migrations).Up(ctx)
+	var checksumErr *ChecksumError
+	if !errors.As(err, &checksumErr) || checksumErr.Version != 9 || checksumErr.Name != "studies" {
+		t.Fatalf("Unexpected error for an edited migration.\nGot: %v\nExpected: a *ChecksumError for 0009_studies", err)
+	}
+}
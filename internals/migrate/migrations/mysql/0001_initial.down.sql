@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS questionnaire_results;
+DROP TABLE IF EXISTS scheduled_questionnaires;
+DROP TABLE IF EXISTS questionnaires;
+DROP TABLE IF EXISTS participants;
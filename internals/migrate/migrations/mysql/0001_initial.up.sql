@@ -1,5 +1,3 @@
-USE `scheduled_questionnaires`;
-
 CREATE TABLE IF NOT EXISTS participants (
     id VARCHAR(128) PRIMARY KEY NOT NULL,
     name VARCHAR(128) NOT NULL
@@ -29,4 +27,4 @@ CREATE TABLE IF NOT EXISTS questionnaire_results (
     participant_id VARCHAR(128) NOT NULL,
     questionnaire_schedule_id VARCHAR(128),
     completed_at DATETIME
-);
\ No newline at end of file
+);
@@ -0,0 +1,19 @@
+ALTER TABLE questionnaire_results
+    DROP FOREIGN KEY fk_questionnaire_results_schedule,
+    DROP FOREIGN KEY fk_questionnaire_results_participant,
+    DROP FOREIGN KEY fk_questionnaire_results_questionnaire;
+
+ALTER TABLE scheduled_questionnaires
+    DROP FOREIGN KEY fk_scheduled_questionnaires_participant,
+    DROP FOREIGN KEY fk_scheduled_questionnaires_questionnaire;
+
+DROP INDEX idx_questionnaire_results_schedule ON questionnaire_results;
+DROP INDEX idx_questionnaire_results_participant ON questionnaire_results;
+DROP INDEX idx_questionnaire_results_questionnaire ON questionnaire_results;
+
+DROP INDEX uq_scheduled_questionnaires_one_pending ON scheduled_questionnaires;
+ALTER TABLE scheduled_questionnaires DROP COLUMN pending_key;
+
+DROP INDEX idx_scheduled_questionnaires_pending_lookup ON scheduled_questionnaires;
+
+ALTER TABLE questionnaire_results DROP PRIMARY KEY;
@@ -0,0 +1,3 @@
+DROP INDEX idx_questionnaires_study ON questionnaires;
+
+ALTER TABLE questionnaires DROP COLUMN deleted_at;
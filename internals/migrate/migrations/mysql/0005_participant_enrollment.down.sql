@@ -0,0 +1,13 @@
+-- The cancelled status no longer exists, cancelled schedules are removed. They have no results.
+DELETE FROM scheduled_questionnaires WHERE status = 'cancelled';
+
+ALTER TABLE scheduled_questionnaires
+    MODIFY COLUMN status ENUM('pending', 'completed') NOT NULL;
+
+DROP INDEX idx_participants_study ON participants;
+
+ALTER TABLE participants
+    DROP COLUMN time_zone,
+    DROP COLUMN status,
+    DROP COLUMN enrolled_at,
+    DROP COLUMN study_id;
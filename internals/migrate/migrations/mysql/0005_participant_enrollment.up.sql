@@ -0,0 +1,13 @@
+-- Study membership and enrollment state of a participant. Participants created before enrollment existed
+-- have no study or enrollment time and are active.
+ALTER TABLE participants
+    ADD COLUMN study_id VARCHAR(128) NULL,
+    ADD COLUMN enrolled_at DATETIME NULL,
+    ADD COLUMN status ENUM('active', 'paused', 'withdrawn', 'completed') NOT NULL DEFAULT 'active',
+    ADD COLUMN time_zone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+CREATE INDEX idx_participants_study ON participants (study_id, status);
+
+-- Withdrawing a participant cancels their pending schedules
+ALTER TABLE scheduled_questionnaires
+    MODIFY COLUMN status ENUM('pending', 'completed', 'cancelled') NOT NULL;
@@ -0,0 +1,11 @@
+ALTER TABLE questionnaire_results DROP FOREIGN KEY fk_questionnaire_results_version;
+ALTER TABLE scheduled_questionnaires DROP FOREIGN KEY fk_scheduled_questionnaires_version;
+-- MySQL keeps the indexes created for the foreign keys
+DROP INDEX fk_questionnaire_results_version ON questionnaire_results;
+DROP INDEX fk_scheduled_questionnaires_version ON scheduled_questionnaires;
+
+ALTER TABLE questionnaire_results DROP COLUMN questionnaire_version;
+ALTER TABLE scheduled_questionnaires DROP COLUMN questionnaire_version;
+ALTER TABLE questionnaires DROP COLUMN current_version;
+
+DROP TABLE questionnaire_versions;
@@ -0,0 +1,6 @@
+-- The indexes backing the foreign keys predate them and are kept
+ALTER TABLE study_protocols DROP FOREIGN KEY fk_study_protocols_study;
+ALTER TABLE participants DROP FOREIGN KEY fk_participants_study;
+ALTER TABLE questionnaires DROP FOREIGN KEY fk_questionnaires_study;
+
+DROP TABLE studies;
@@ -0,0 +1,4 @@
+-- The ID of the completion event a result records, NULL for results of events without one.
+-- A redelivered event finds the result of its first delivery instead of completing the next schedule.
+ALTER TABLE questionnaire_results ADD COLUMN event_id VARCHAR(128) NULL;
+CREATE UNIQUE INDEX uq_questionnaire_results_event ON questionnaire_results (event_id);
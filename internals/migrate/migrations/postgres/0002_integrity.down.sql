@@ -0,0 +1,18 @@
+ALTER TABLE questionnaire_results
+    DROP CONSTRAINT fk_questionnaire_results_schedule,
+    DROP CONSTRAINT fk_questionnaire_results_participant,
+    DROP CONSTRAINT fk_questionnaire_results_questionnaire;
+
+ALTER TABLE scheduled_questionnaires
+    DROP CONSTRAINT fk_scheduled_questionnaires_participant,
+    DROP CONSTRAINT fk_scheduled_questionnaires_questionnaire;
+
+DROP INDEX idx_questionnaire_results_schedule;
+DROP INDEX idx_questionnaire_results_participant;
+DROP INDEX idx_questionnaire_results_questionnaire;
+
+DROP INDEX uq_scheduled_questionnaires_one_pending;
+
+DROP INDEX idx_scheduled_questionnaires_pending_lookup;
+
+ALTER TABLE questionnaire_results DROP CONSTRAINT questionnaire_results_pkey;
@@ -0,0 +1,29 @@
+-- questionnaire_results had no primary key
+ALTER TABLE questionnaire_results ADD PRIMARY KEY (id);
+
+-- Lookup of the pending schedule of a participant for a questionnaire, run on every completion event
+CREATE INDEX idx_scheduled_questionnaires_pending_lookup
+    ON scheduled_questionnaires (questionnaire_id, participant_id, status);
+
+-- At most one pending schedule per participant and questionnaire
+CREATE UNIQUE INDEX uq_scheduled_questionnaires_one_pending
+    ON scheduled_questionnaires (participant_id, questionnaire_id)
+    WHERE status = 'pending';
+
+CREATE INDEX idx_questionnaire_results_questionnaire ON questionnaire_results (questionnaire_id);
+CREATE INDEX idx_questionnaire_results_participant ON questionnaire_results (participant_id);
+CREATE INDEX idx_questionnaire_results_schedule ON questionnaire_results (questionnaire_schedule_id);
+
+ALTER TABLE scheduled_questionnaires
+    ADD CONSTRAINT fk_scheduled_questionnaires_questionnaire
+        FOREIGN KEY (questionnaire_id) REFERENCES questionnaires (id),
+    ADD CONSTRAINT fk_scheduled_questionnaires_participant
+        FOREIGN KEY (participant_id) REFERENCES participants (id);
+
+ALTER TABLE questionnaire_results
+    ADD CONSTRAINT fk_questionnaire_results_questionnaire
+        FOREIGN KEY (questionnaire_id) REFERENCES questionnaires (id),
+    ADD CONSTRAINT fk_questionnaire_results_participant
+        FOREIGN KEY (participant_id) REFERENCES participants (id),
+    ADD CONSTRAINT fk_questionnaire_results_schedule
+        FOREIGN KEY (questionnaire_schedule_id) REFERENCES scheduled_questionnaires (id);
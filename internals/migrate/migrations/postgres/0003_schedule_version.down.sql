@@ -0,0 +1 @@
+ALTER TABLE scheduled_questionnaires DROP COLUMN version;
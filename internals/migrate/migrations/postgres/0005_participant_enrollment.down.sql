@@ -0,0 +1,14 @@
+-- The cancelled status no longer exists, cancelled schedules are removed. They have no results.
+DELETE FROM scheduled_questionnaires WHERE status = 'cancelled';
+
+ALTER TABLE scheduled_questionnaires
+    DROP CONSTRAINT scheduled_questionnaires_status_check,
+    ADD CONSTRAINT scheduled_questionnaires_status_check CHECK (status IN ('pending', 'completed'));
+
+DROP INDEX idx_participants_study;
+
+ALTER TABLE participants
+    DROP COLUMN time_zone,
+    DROP COLUMN status,
+    DROP COLUMN enrolled_at,
+    DROP COLUMN study_id;
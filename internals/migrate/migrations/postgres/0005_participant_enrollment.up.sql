@@ -0,0 +1,15 @@
+-- Study membership and enrollment state of a participant. Participants created before enrollment existed
+-- have no study or enrollment time and are active.
+ALTER TABLE participants
+    ADD COLUMN study_id VARCHAR(128) NULL,
+    ADD COLUMN enrolled_at TIMESTAMP NULL,
+    ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'active'
+        CONSTRAINT participants_status_check CHECK (status IN ('active', 'paused', 'withdrawn', 'completed')),
+    ADD COLUMN time_zone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+CREATE INDEX idx_participants_study ON participants (study_id, status);
+
+-- Withdrawing a participant cancels their pending schedules
+ALTER TABLE scheduled_questionnaires
+    DROP CONSTRAINT scheduled_questionnaires_status_check,
+    ADD CONSTRAINT scheduled_questionnaires_status_check CHECK (status IN ('pending', 'completed', 'cancelled'));
@@ -0,0 +1 @@
+ALTER TABLE questionnaire_results DROP COLUMN scores;
@@ -0,0 +1,2 @@
+-- The scores computed from the answers by the scoring rules of the questionnaire, NULL when it has none
+ALTER TABLE questionnaire_results ADD COLUMN scores JSONB NULL;
@@ -0,0 +1,8 @@
+ALTER TABLE questionnaire_results DROP CONSTRAINT fk_questionnaire_results_version;
+ALTER TABLE scheduled_questionnaires DROP CONSTRAINT fk_scheduled_questionnaires_version;
+
+ALTER TABLE questionnaire_results DROP COLUMN questionnaire_version;
+ALTER TABLE scheduled_questionnaires DROP COLUMN questionnaire_version;
+ALTER TABLE questionnaires DROP COLUMN current_version;
+
+DROP TABLE questionnaire_versions;
@@ -0,0 +1,5 @@
+ALTER TABLE study_protocols DROP CONSTRAINT fk_study_protocols_study;
+ALTER TABLE participants DROP CONSTRAINT fk_participants_study;
+ALTER TABLE questionnaires DROP CONSTRAINT fk_questionnaires_study;
+
+DROP TABLE studies;
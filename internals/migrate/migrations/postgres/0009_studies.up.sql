@@ -0,0 +1,34 @@
+-- A study owns questionnaires and participants. Closing it rejects their completions and enrollments.
+-- time_zone, hours_between_attempts and max_attempts are the defaults of the participants and questionnaires
+-- of the study that set none.
+CREATE TABLE studies (
+    id VARCHAR(128) PRIMARY KEY NOT NULL,
+    name VARCHAR(128) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'active'
+        CONSTRAINT studies_status_check CHECK (status IN ('active', 'closed')),
+    starts_at TIMESTAMP,
+    ends_at TIMESTAMP,
+    time_zone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    hours_between_attempts INT NOT NULL DEFAULT 24,
+    max_attempts INT
+);
+
+-- Every study already referenced by a questionnaire, a participant or a protocol becomes an active study named after its ID
+INSERT INTO studies (id, name)
+    SELECT study_id, study_id FROM questionnaires
+    UNION
+    SELECT study_id, study_id FROM participants WHERE study_id IS NOT NULL
+    UNION
+    SELECT study_id, study_id FROM study_protocols;
+
+ALTER TABLE questionnaires
+    ADD CONSTRAINT fk_questionnaires_study
+        FOREIGN KEY (study_id) REFERENCES studies (id);
+
+ALTER TABLE participants
+    ADD CONSTRAINT fk_participants_study
+        FOREIGN KEY (study_id) REFERENCES studies (id);
+
+ALTER TABLE study_protocols
+    ADD CONSTRAINT fk_study_protocols_study
+        FOREIGN KEY (study_id) REFERENCES studies (id);
@@ -0,0 +1,4 @@
+-- How long a participant takes part in a study after enrolling, NULL for as long as the study runs
+ALTER TABLE studies ADD COLUMN participation_days INT NULL;
+-- The end of the participation of a participant, overriding the participation window of the study when set
+ALTER TABLE participants ADD COLUMN participation_ends_at TIMESTAMP NULL;
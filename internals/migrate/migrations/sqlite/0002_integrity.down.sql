@@ -0,0 +1,32 @@
+-- The rebuilt tables are replaced by copies without keys or constraints, dropping their indexes with them.
+-- questionnaire_results goes first as it references scheduled_questionnaires.
+CREATE TABLE questionnaire_results_old (
+    id VARCHAR(128) NOT NULL,
+    answers TEXT NOT NULL,
+    questionnaire_id VARCHAR(128) NOT NULL,
+    participant_id VARCHAR(128) NOT NULL,
+    questionnaire_schedule_id VARCHAR(128),
+    completed_at DATETIME
+);
+
+INSERT INTO questionnaire_results_old (id, answers, questionnaire_id, participant_id, questionnaire_schedule_id, completed_at)
+    SELECT id, answers, questionnaire_id, participant_id, questionnaire_schedule_id, completed_at FROM questionnaire_results;
+
+DROP TABLE questionnaire_results;
+
+ALTER TABLE questionnaire_results_old RENAME TO questionnaire_results;
+
+CREATE TABLE scheduled_questionnaires_old (
+    id VARCHAR(128) PRIMARY KEY NOT NULL,
+    questionnaire_id VARCHAR(128) NOT NULL,
+    participant_id VARCHAR(128) NOT NULL,
+    scheduled_at DATETIME NOT NULL,
+    status VARCHAR(16) NOT NULL CHECK (status IN ('pending', 'completed'))
+);
+
+INSERT INTO scheduled_questionnaires_old (id, questionnaire_id, participant_id, scheduled_at, status)
+    SELECT id, questionnaire_id, participant_id, scheduled_at, status FROM scheduled_questionnaires;
+
+DROP TABLE scheduled_questionnaires;
+
+ALTER TABLE scheduled_questionnaires_old RENAME TO scheduled_questionnaires;
@@ -0,0 +1,55 @@
+-- SQLite cannot add keys or constraints to an existing table, so both tables are rebuilt.
+CREATE TABLE scheduled_questionnaires_new (
+    id VARCHAR(128) PRIMARY KEY NOT NULL,
+    questionnaire_id VARCHAR(128) NOT NULL,
+    participant_id VARCHAR(128) NOT NULL,
+    scheduled_at DATETIME NOT NULL,
+    status VARCHAR(16) NOT NULL CHECK (status IN ('pending', 'completed')),
+    CONSTRAINT fk_scheduled_questionnaires_questionnaire
+        FOREIGN KEY (questionnaire_id) REFERENCES questionnaires (id),
+    CONSTRAINT fk_scheduled_questionnaires_participant
+        FOREIGN KEY (participant_id) REFERENCES participants (id)
+);
+
+INSERT INTO scheduled_questionnaires_new (id, questionnaire_id, participant_id, scheduled_at, status)
+    SELECT id, questionnaire_id, participant_id, scheduled_at, status FROM scheduled_questionnaires;
+
+DROP TABLE scheduled_questionnaires;
+
+ALTER TABLE scheduled_questionnaires_new RENAME TO scheduled_questionnaires;
+
+-- questionnaire_results had no primary key
+CREATE TABLE questionnaire_results_new (
+    id VARCHAR(128) PRIMARY KEY NOT NULL,
+    answers TEXT NOT NULL,
+    questionnaire_id VARCHAR(128) NOT NULL,
+    participant_id VARCHAR(128) NOT NULL,
+    questionnaire_schedule_id VARCHAR(128),
+    completed_at DATETIME,
+    CONSTRAINT fk_questionnaire_results_questionnaire
+        FOREIGN KEY (questionnaire_id) REFERENCES questionnaires (id),
+    CONSTRAINT fk_questionnaire_results_participant
+        FOREIGN KEY (participant_id) REFERENCES participants (id),
+    CONSTRAINT fk_questionnaire_results_schedule
+        FOREIGN KEY (questionnaire_schedule_id) REFERENCES scheduled_questionnaires (id)
+);
+
+INSERT INTO questionnaire_results_new (id, answers, questionnaire_id, participant_id, questionnaire_schedule_id, completed_at)
+    SELECT id, answers, questionnaire_id, participant_id, questionnaire_schedule_id, completed_at FROM questionnaire_results;
+
+DROP TABLE questionnaire_results;
+
+ALTER TABLE questionnaire_results_new RENAME TO questionnaire_results;
+
+-- Lookup of the pending schedule of a participant for a questionnaire, run on every completion event
+CREATE INDEX idx_scheduled_questionnaires_pending_lookup
+    ON scheduled_questionnaires (questionnaire_id, participant_id, status);
+
+-- At most one pending schedule per participant and questionnaire
+CREATE UNIQUE INDEX uq_scheduled_questionnaires_one_pending
+    ON scheduled_questionnaires (participant_id, questionnaire_id)
+    WHERE status = 'pending';
+
+CREATE INDEX idx_questionnaire_results_questionnaire ON questionnaire_results (questionnaire_id);
+CREATE INDEX idx_questionnaire_results_participant ON questionnaire_results (participant_id);
+CREATE INDEX idx_questionnaire_results_schedule ON questionnaire_results (questionnaire_schedule_id);
@@ -0,0 +1,2 @@
+-- Version of the row for optimistic concurrency control, incremented by every update
+ALTER TABLE scheduled_questionnaires ADD COLUMN version INT NOT NULL DEFAULT 1;
@@ -0,0 +1,3 @@
+DROP INDEX idx_questionnaires_study;
+
+ALTER TABLE questionnaires DROP COLUMN deleted_at;
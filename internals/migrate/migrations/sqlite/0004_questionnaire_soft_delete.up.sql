@@ -0,0 +1,5 @@
+-- Deleted questionnaires keep their row, and the schedules and results referencing it, with the time of deletion set
+ALTER TABLE questionnaires ADD COLUMN deleted_at DATETIME NULL;
+
+-- Listing the questionnaires of a study, paginated by ID
+CREATE INDEX idx_questionnaires_study ON questionnaires (study_id, id);
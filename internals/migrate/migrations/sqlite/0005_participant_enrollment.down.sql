@@ -0,0 +1,65 @@
+-- The cancelled status no longer exists, cancelled schedules are removed. They have no results.
+-- SQLite cannot change the CHECK constraint of a column, so scheduled_questionnaires is rebuilt.
+-- questionnaire_results references it and is set aside first, then rebuilt with the same keys.
+CREATE TABLE questionnaire_results_copy AS SELECT * FROM questionnaire_results;
+
+DROP TABLE questionnaire_results;
+
+CREATE TABLE scheduled_questionnaires_new (
+    id VARCHAR(128) PRIMARY KEY NOT NULL,
+    questionnaire_id VARCHAR(128) NOT NULL,
+    participant_id VARCHAR(128) NOT NULL,
+    scheduled_at DATETIME NOT NULL,
+    status VARCHAR(16) NOT NULL CHECK (status IN ('pending', 'completed')),
+    version INT NOT NULL DEFAULT 1,
+    CONSTRAINT fk_scheduled_questionnaires_questionnaire
+        FOREIGN KEY (questionnaire_id) REFERENCES questionnaires (id),
+    CONSTRAINT fk_scheduled_questionnaires_participant
+        FOREIGN KEY (participant_id) REFERENCES participants (id)
+);
+
+INSERT INTO scheduled_questionnaires_new (id, questionnaire_id, participant_id, scheduled_at, status, version)
+    SELECT id, questionnaire_id, participant_id, scheduled_at, status, version FROM scheduled_questionnaires
+    WHERE status <> 'cancelled';
+
+DROP TABLE scheduled_questionnaires;
+
+ALTER TABLE scheduled_questionnaires_new RENAME TO scheduled_questionnaires;
+
+CREATE TABLE questionnaire_results (
+    id VARCHAR(128) PRIMARY KEY NOT NULL,
+    answers TEXT NOT NULL,
+    questionnaire_id VARCHAR(128) NOT NULL,
+    participant_id VARCHAR(128) NOT NULL,
+    questionnaire_schedule_id VARCHAR(128),
+    completed_at DATETIME,
+    CONSTRAINT fk_questionnaire_results_questionnaire
+        FOREIGN KEY (questionnaire_id) REFERENCES questionnaires (id),
+    CONSTRAINT fk_questionnaire_results_participant
+        FOREIGN KEY (participant_id) REFERENCES participants (id),
+    CONSTRAINT fk_questionnaire_results_schedule
+        FOREIGN KEY (questionnaire_schedule_id) REFERENCES scheduled_questionnaires (id)
+);
+
+INSERT INTO questionnaire_results (id, answers, questionnaire_id, participant_id, questionnaire_schedule_id, completed_at)
+    SELECT id, answers, questionnaire_id, participant_id, questionnaire_schedule_id, completed_at FROM questionnaire_results_copy;
+
+DROP TABLE questionnaire_results_copy;
+
+CREATE INDEX idx_scheduled_questionnaires_pending_lookup
+    ON scheduled_questionnaires (questionnaire_id, participant_id, status);
+
+CREATE UNIQUE INDEX uq_scheduled_questionnaires_one_pending
+    ON scheduled_questionnaires (participant_id, questionnaire_id)
+    WHERE status = 'pending';
+
+CREATE INDEX idx_questionnaire_results_questionnaire ON questionnaire_results (questionnaire_id);
+CREATE INDEX idx_questionnaire_results_participant ON questionnaire_results (participant_id);
+CREATE INDEX idx_questionnaire_results_schedule ON questionnaire_results (questionnaire_schedule_id);
+
+DROP INDEX idx_participants_study;
+
+ALTER TABLE participants DROP COLUMN time_zone;
+ALTER TABLE participants DROP COLUMN status;
+ALTER TABLE participants DROP COLUMN enrolled_at;
+ALTER TABLE participants DROP COLUMN study_id;
@@ -0,0 +1,5 @@
+ALTER TABLE questionnaire_results DROP COLUMN questionnaire_version;
+ALTER TABLE scheduled_questionnaires DROP COLUMN questionnaire_version;
+ALTER TABLE questionnaires DROP COLUMN current_version;
+
+DROP TABLE questionnaire_versions;
@@ -0,0 +1,24 @@
+-- Immutable versions of the settings of a questionnaire: its questions, rules and attempts.
+-- The questionnaires row keeps a copy of the current version and points to it with current_version.
+CREATE TABLE questionnaire_versions (
+    questionnaire_id VARCHAR(128) NOT NULL,
+    version INT NOT NULL,
+    questions TEXT NOT NULL,
+    max_attempts INT,
+    hours_between_attempts INT NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (questionnaire_id, version),
+    CONSTRAINT fk_questionnaire_versions_questionnaire
+        FOREIGN KEY (questionnaire_id) REFERENCES questionnaires (id)
+);
+
+-- Every existing questionnaire becomes version 1 of itself
+INSERT INTO questionnaire_versions (questionnaire_id, version, questions, max_attempts, hours_between_attempts, created_at)
+    SELECT id, 1, questions, max_attempts, COALESCE(hours_between_attempts, 24), CURRENT_TIMESTAMP FROM questionnaires;
+
+ALTER TABLE questionnaires ADD COLUMN current_version INT NOT NULL DEFAULT 1;
+
+-- The version a schedule was issued against and a result answered against, version 1 for the existing ones
+ALTER TABLE scheduled_questionnaires ADD COLUMN questionnaire_version INT NOT NULL DEFAULT 1;
+ALTER TABLE questionnaire_results ADD COLUMN questionnaire_version INT NOT NULL DEFAULT 1;
+-- SQLite cannot add foreign keys to an existing table: the stores only ever write versions that exist.
@@ -0,0 +1,6 @@
+-- The protocol of a study links its questionnaires, so that completing one schedules another
+CREATE TABLE study_protocols (
+    study_id VARCHAR(128) PRIMARY KEY NOT NULL,
+    definition TEXT NOT NULL,
+    updated_at DATETIME NOT NULL
+);
@@ -0,0 +1,2 @@
+ALTER TABLE participants DROP COLUMN participation_ends_at;
+ALTER TABLE studies DROP COLUMN participation_days;
@@ -0,0 +1,2 @@
+DROP INDEX uq_questionnaire_results_event;
+ALTER TABLE questionnaire_results DROP COLUMN event_id;
@@ -4,9 +4,12 @@ Package models provides the data models used in the Rescheduler application.
 The models package defines the structures that represent key entities in the Rescheduler, such as participants, questionnaires, scheduled questionnaires, and questionnaire results. As I uunderstand the task, these models are designed to encapsulate the data associated with various aspects of a study, allowing for organized data representation and manipulation.
 
 Structures:
-- Participant: Represents a participant in a study with unique identification and a name.
+- Study: A study owning questionnaires and participants, with its status, dates and scheduling defaults.
+- Participant: Represents a participant enrolled in a study, with unique identification, a name, an enrollment status and a time zone.
 - Questionnaire: Holds information about different questionnaires, including their configurations, maximum attempts, and scheduling parameters.
+- QuestionnaireVersion: An immutable version of the questions and scheduling parameters of a questionnaire.
 - ScheduledQuestionnaire: Represents a specific request for a participant to fill in a questionnaire at a scheduled time.
+- StudyProtocol: Links the questionnaires of a study, so that completing one schedules another.
 - QuestionnaireResult: Stores the results of a participant completing a questionnaire, including answers and completion timestamp.
 
 These models in this package serve as the foundation for database interactions, providing a structured representation of the entities within the Rescheduler task.
@@ -16,23 +19,93 @@ package models
 
 import (
 	"database/sql"
+	"encoding/json"
 	"rescheduler/internals/timestamp"
 )
 
-// Participant represents a participant in the study
+type StudyStatus string
+
+const (
+	StudyActive = "active"
+	StudyClosed = "closed"
+)
+
+// Study represents a study, which owns questionnaires and participants. A closed study accepts no more
+// completions or enrollments. StartsAt and EndsAt are the dates the study runs, when known: nothing is scheduled
+// after EndsAt. ParticipationDays, when set, is how long each participant takes part after enrolling.
+// TimeZone, HoursBetweenAttempts and MaxAttempts are scheduling defaults: TimeZone for the participants enrolled
+// without one, HoursBetweenAttempts and MaxAttempts for the questionnaires created without hours between attempts.
+type Study struct {
+	ID                   string                  `json:"id"`
+	Name                 string                  `json:"name"`
+	Status               StudyStatus             `json:"status"`
+	StartsAt             timestamp.NullTimeStamp `json:"starts_at"`
+	EndsAt               timestamp.NullTimeStamp `json:"ends_at"`
+	TimeZone             string                  `json:"time_zone"`
+	HoursBetweenAttempts int                     `json:"hours_between_attempts"`
+	MaxAttempts          sql.NullInt64           `json:"max_attempts"`
+	ParticipationDays    sql.NullInt64           `json:"participation_days"`
+}
+
+type ParticipantStatus string
+
+const (
+	ParticipantActive    = "active"
+	ParticipantPaused    = "paused"
+	ParticipantWithdrawn = "withdrawn"
+	ParticipantCompleted = "completed"
+)
+
+// Participant represents a participant in the study.
+// Participants created before enrollment existed have no StudyID or EnrolledAt and are active.
+// TimeZone is an IANA time zone name such as "Europe/London". It is validated and stored but not used yet:
+// schedules are planned in UTC, a number of hours after a completion or an enrollment.
+// ParticipationEndsAt, when set, is the end of the participant's participation, overriding the participation window
+// of the study. Nothing is scheduled for the participant after it.
 type Participant struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID                  string                  `json:"id"`
+	Name                string                  `json:"name"`
+	StudyID             string                  `json:"study_id"`
+	EnrolledAt          timestamp.TimeStamp     `json:"enrolled_at"`
+	Status              ParticipantStatus       `json:"status"`
+	TimeZone            string                  `json:"time_zone"`
+	ParticipationEndsAt timestamp.NullTimeStamp `json:"participation_ends_at"`
 }
 
-// Questionnaire represents a questionnaire that participants can fill out
+// Questionnaire represents a questionnaire that participants can fill out.
+// Questions is the JSON definition of the questions, parsed by the questions package.
+// A deleted questionnaire keeps its record with DeletedAt set, so the schedules and results referencing it are kept.
+// Questions, MaxAttempts and HoursBetweenAttempts are those of the current version of the questionnaire, Version.
+// Changing any of them creates a new QuestionnaireVersion, earlier versions are never changed.
 type Questionnaire struct {
-	ID                   string        `json:"id"`
-	StudyID              string        `json:"study_id"`
-	Name                 string        `json:"name"`
-	Questions            string        `json:"questions"`
-	MaxAttempts          sql.NullInt64 `json:"max_attempts"`
-	HoursBetweenAttempts int           `json:"hours_between_attempts"`
+	ID                   string                  `json:"id"`
+	StudyID              string                  `json:"study_id"`
+	Name                 string                  `json:"name"`
+	Questions            string                  `json:"questions"`
+	MaxAttempts          sql.NullInt64           `json:"max_attempts"`
+	HoursBetweenAttempts int                     `json:"hours_between_attempts"`
+	DeletedAt            timestamp.NullTimeStamp `json:"deleted_at"`
+	Version              int                     `json:"version"`
+}
+
+// QuestionnaireVersion is an immutable version of the settings of a questionnaire, numbered from 1.
+// Schedules and results record the version they were issued and answered against,
+// so editing a questionnaire never changes the meaning of the answers already given.
+type QuestionnaireVersion struct {
+	QuestionnaireID      string              `json:"questionnaire_id"`
+	Version              int                 `json:"version"`
+	Questions            string              `json:"questions"`
+	MaxAttempts          sql.NullInt64       `json:"max_attempts"`
+	HoursBetweenAttempts int                 `json:"hours_between_attempts"`
+	CreatedAt            timestamp.TimeStamp `json:"created_at"`
+}
+
+// StudyProtocol is the protocol of a study. Definition is its JSON definition, parsed by the protocol package.
+// A study without a protocol links nothing: each questionnaire is only rescheduled after its own completions.
+type StudyProtocol struct {
+	StudyID    string              `json:"study_id"`
+	Definition string              `json:"definition"`
+	UpdatedAt  timestamp.TimeStamp `json:"updated_at"`
 }
 
 type ScheduledQuestionnaireStatus string
@@ -40,25 +113,39 @@ type ScheduledQuestionnaireStatus string
 const (
 	ScheduledQuestionnairePending   = "pending"
 	ScheduledQuestionnaireCompleted = "completed"
+	// ScheduledQuestionnaireCancelled is the status of a schedule that was pending when its participant withdrew.
+	ScheduledQuestionnaireCancelled = "cancelled"
 )
 
-// ScheduledQuestionnaire represents a scheduled questionnaire for a specific participant
+// ScheduledQuestionnaire represents a scheduled questionnaire for a specific participant.
+// Version is incremented by every update; an update based on an older version is rejected.
+// QuestionnaireVersion is the version of the questionnaire the schedule was issued against.
 type ScheduledQuestionnaire struct {
-	ID              string                       `json:"id"`
-	QuestionnaireID string                       `json:"questionnaire_id"`
-	ParticipantID   string                       `json:"participant_id"`
-	ScheduledAt     timestamp.TimeStamp          `json:"scheduled_at"`
-	Status          ScheduledQuestionnaireStatus `json:"status"`
+	ID                   string                       `json:"id"`
+	QuestionnaireID      string                       `json:"questionnaire_id"`
+	QuestionnaireVersion int                          `json:"questionnaire_version"`
+	ParticipantID        string                       `json:"participant_id"`
+	ScheduledAt          timestamp.TimeStamp          `json:"scheduled_at"`
+	Status               ScheduledQuestionnaireStatus `json:"status"`
+	Version              int64                        `json:"version"`
 }
 
-// QuestionnaireResult represents the results of a participant filling out a questionnaire
+// QuestionnaireResult represents the results of a participant filling out a questionnaire.
+// Answers is the JSON object of the participant's answers, as received in the completion event.
+// QuestionnaireVersion is the version of the questionnaire the answers were given to, the version of the schedule.
 type QuestionnaireResult struct {
 	ID                      string              `json:"id"`
-	Answers                 string              `json:"answers"`
+	Answers                 json.RawMessage     `json:"answers"`
 	QuestionnaireID         string              `json:"questionnaire_id"`
+	QuestionnaireVersion    int                 `json:"questionnaire_version"`
 	ParticipantID           string              `json:"participant_id"`
 	QuestionnaireScheduleID string              `json:"questionnaire_schedule_id"`
 	CompletedAt             timestamp.TimeStamp `json:"completed_at"`
+	// EventID is the ID of the completion event the result records, empty for an event without one.
+	// At most one result records an event.
+	EventID string `json:"event_id,omitempty"`
+	// Scores are the scores computed from the answers, keyed by score ID. They are nil when the questionnaire declares none.
+	Scores map[string]float64 `json:"scores,omitempty"`
 }
 
 // QuestionnaireCompletedEvent model
@@ -71,4 +158,6 @@ type QuestionnaireCompletedEvent struct {
 	QuestionnaireID      string              `json:"questionnaire_id"`
 	CompletedAt          timestamp.TimeStamp `json:"completed_at"`
 	RemainingCompletions int                 `json:"remaining_completions"`
+	// Answers is the JSON object of the participant's answers. It is kept as received and stored verbatim.
+	Answers json.RawMessage `json:"answers"`
 }
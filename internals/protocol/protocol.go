@@ -0,0 +1,212 @@
+// Package protocol parses the protocol of a study, stored as JSON in StudyProtocol.Definition, which links
+// the questionnaires of the study so that completing one schedules another.
+//
+// A protocol lists links and sequences:
+//
+//	{
+//	  "links": [
+//	    {"after": "intake", "schedule": "follow-up", "in_hours": 48},
+//	    {"after": "daily", "on": "series_completed", "schedule": "exit"}
+//	  ],
+//	  "sequences": [
+//	    {"id": "visit-1", "questionnaires": ["phq9", "gad7", "sleep"], "gap_hours": 0}
+//	  ]
+//	}
+//
+// A link schedules a questionnaire some hours after another one is completed, see Link. A sequence is a fixed order
+// of instruments, such as those of a visit: completing each of them schedules the next, see Sequence.
+// Protocol.Next returns what a completion schedules.
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"rescheduler/internals/questions"
+)
+
+// ErrInvalidProtocol is returned when a study protocol cannot be parsed or is inconsistent.
+var ErrInvalidProtocol = errors.New("invalid study protocol")
+
+// Trigger is the completion a link waits for.
+type Trigger string
+
+const (
+	// Completed triggers on every completion of the questionnaire. It is the default.
+	Completed Trigger = "completed"
+	// SeriesCompleted triggers once the series of the questionnaire ends: its last attempt is completed,
+	// or one of its rules stops it.
+	SeriesCompleted Trigger = "series_completed"
+)
+
+// Protocol is the parsed protocol of a study.
+type Protocol struct {
+	Links     []Link     `json:"links,omitempty"`
+	Sequences []Sequence `json:"sequences,omitempty"`
+}
+
+// Link schedules the questionnaire Schedule InHours after the questionnaire After is completed.
+type Link struct {
+	// After is the ID of the questionnaire whose completion triggers the link.
+	After string `json:"after"`
+	// On is the completion the link waits for, Completed when empty.
+	On Trigger `json:"on,omitempty"`
+	// Schedule is the ID of the questionnaire scheduled, another questionnaire of the study.
+	Schedule string `json:"schedule"`
+	// InHours is the delay between the completion and the new schedule, zero for at once.
+	InHours int `json:"in_hours,omitempty"`
+}
+
+// Sequence is a fixed order of questionnaires: every completion of one of them schedules the next,
+// GapHours after the completion. The last questionnaire schedules nothing.
+type Sequence struct {
+	// ID names the sequence, for instance after the visit it describes.
+	ID             string   `json:"id"`
+	Questionnaires []string `json:"questionnaires"`
+	GapHours       int      `json:"gap_hours,omitempty"`
+}
+
+// Parse parses and checks the definition of a study protocol.
+//
+// An empty definition, such as "" or {}, links nothing. Unknown fields are rejected so that a misspelt setting
+// is not silently ignored. Whether the questionnaires exist is not checked here: the protocol only names them.
+//
+// Parameters:
+//   - definition: The JSON definition of the protocol.
+//
+// Returns:
+//   - The parsed Protocol.
+//   - An error wrapping ErrInvalidProtocol that lists every problem found.
+func Parse(definition string) (*Protocol, error) {
+	var protocol Protocol
+	if strings.TrimSpace(definition) == "" {
+		return &protocol, nil
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(definition))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&protocol); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProtocol, err)
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("%w: unexpected data after the protocol", ErrInvalidProtocol)
+	}
+
+	if problems := protocol.check(); len(problems) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidProtocol, strings.Join(problems, ", "))
+	}
+	return &protocol, nil
+}
+
+// check returns every inconsistency of the protocol.
+func (p *Protocol) check() []string {
+	var problems []string
+	for i, link := range p.Links {
+		name := fmt.Sprintf("link #%d", i+1)
+		if strings.TrimSpace(link.After) == "" || strings.TrimSpace(link.Schedule) == "" {
+			problems = append(problems, name+" needs both a questionnaire to wait for and one to schedule")
+		} else if link.After == link.Schedule {
+			problems = append(problems, fmt.Sprintf("%s schedules %q after itself", name, link.After))
+		}
+		switch link.On {
+		case "", Completed, SeriesCompleted:
+		default:
+			problems = append(problems, fmt.Sprintf("%s has unknown trigger %q", name, link.On))
+		}
+		if link.InHours < 0 {
+			problems = append(problems, name+" schedules in a negative number of hours")
+		}
+	}
+
+	seen := make(map[string]bool, len(p.Sequences))
+	for i, sequence := range p.Sequences {
+		name := sequence.ID
+		if strings.TrimSpace(sequence.ID) == "" {
+			name = fmt.Sprintf("#%d", i+1)
+			problems = append(problems, fmt.Sprintf("sequence %s has no ID", name))
+		} else if seen[sequence.ID] {
+			problems = append(problems, fmt.Sprintf("sequence ID %q is used more than once", sequence.ID))
+		}
+		seen[sequence.ID] = true
+
+		if len(sequence.Questionnaires) < 2 {
+			problems = append(problems, fmt.Sprintf("sequence %s needs at least two questionnaires", name))
+		}
+		listed := make(map[string]bool, len(sequence.Questionnaires))
+		for _, id := range sequence.Questionnaires {
+			if strings.TrimSpace(id) == "" {
+				problems = append(problems, fmt.Sprintf("sequence %s has an empty questionnaire ID", name))
+			} else if listed[id] {
+				problems = append(problems, fmt.Sprintf("sequence %s lists %q more than once", name, id))
+			}
+			listed[id] = true
+		}
+		if sequence.GapHours < 0 {
+			problems = append(problems, fmt.Sprintf("sequence %s has a negative gap", name))
+		}
+	}
+	return problems
+}
+
+// links returns the links of the protocol followed by those its sequences stand for.
+func (p *Protocol) links() []Link {
+	links := append([]Link(nil), p.Links...)
+	for _, sequence := range p.Sequences {
+		for i := 1; i < len(sequence.Questionnaires); i++ {
+			links = append(links, Link{
+				After:    sequence.Questionnaires[i-1],
+				On:       Completed,
+				Schedule: sequence.Questionnaires[i],
+				InHours:  sequence.GapHours,
+			})
+		}
+	}
+	return links
+}
+
+// Next returns the questionnaires a completion of the given questionnaire schedules, each once, in protocol order:
+// links first, then sequences. seriesCompleted reports that the completion ends the series of the questionnaire,
+// which also triggers the links waiting for SeriesCompleted.
+//
+// The follow-ups are those a rule of a questionnaire schedules, see questions.Outcome, so the caller schedules both the same way.
+func (p *Protocol) Next(questionnaireID string, seriesCompleted bool) []questions.FollowUp {
+	var followUps []questions.FollowUp
+	scheduled := make(map[string]bool)
+	for _, link := range p.links() {
+		if link.After != questionnaireID || link.On == SeriesCompleted && !seriesCompleted || scheduled[link.Schedule] {
+			continue
+		}
+		scheduled[link.Schedule] = true
+		followUps = append(followUps, questions.FollowUp{QuestionnaireID: link.Schedule, InHours: link.InHours})
+	}
+	return followUps
+}
+
+// Questionnaires returns the IDs of every questionnaire the protocol refers to, as waited for or scheduled, each once,
+// in protocol order: links first, then sequences.
+func (p *Protocol) Questionnaires() []string {
+	var questionnaires []string
+	listed := make(map[string]bool)
+	for _, link := range p.links() {
+		for _, id := range []string{link.After, link.Schedule} {
+			if !listed[id] {
+				listed[id] = true
+				questionnaires = append(questionnaires, id)
+			}
+		}
+	}
+	return questionnaires
+}
+
+// Triggered reports whether a link or a sequence schedules the questionnaire. Such a questionnaire only becomes
+// available once the questionnaire it follows is completed, so it is not scheduled when a participant enrolls.
+func (p *Protocol) Triggered(questionnaireID string) bool {
+	for _, link := range p.links() {
+		if link.Schedule == questionnaireID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+// File: ./internals/protocol/protocol_test.go
+
+package protocol
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"rescheduler/internals/questions"
+)
+
+const visits = `{
+	"links": [
+		{"after": "intake", "schedule": "follow-up", "in_hours": 48},
+		{"after": "intake", "schedule": "consent"},
+		{"after": "daily", "on": "series_completed", "schedule": "exit", "in_hours": 24},
+		{"after": "daily", "schedule": "consent"}
+	],
+	"sequences": [
+		{"id": "visit-1", "questionnaires": ["phq9", "gad7", "sleep"], "gap_hours": 1},
+		{"id": "visit-2", "questionnaires": ["intake", "follow-up"]}
+	]
+}`
+
+func TestProtocol_Next(t *testing.T) {
+	p, err := Parse(visits)
+	if err != nil {
+		t.Fatalf("Error parsing protocol: %v", err)
+	}
+
+	tests := []struct {
+		name            string
+		questionnaireID string
+		seriesCompleted bool
+		expected        []questions.FollowUp
+	}{
+		{
+			name:            "Links, each questionnaire once",
+			questionnaireID: "intake",
+			expected:        []questions.FollowUp{{QuestionnaireID: "follow-up", InHours: 48}, {QuestionnaireID: "consent"}},
+		},
+		{name: "Every completion", questionnaireID: "daily", expected: []questions.FollowUp{{QuestionnaireID: "consent"}}},
+		{
+			name:            "End of the series",
+			questionnaireID: "daily",
+			seriesCompleted: true,
+			expected:        []questions.FollowUp{{QuestionnaireID: "exit", InHours: 24}, {QuestionnaireID: "consent"}},
+		},
+		{name: "Sequence", questionnaireID: "phq9", expected: []questions.FollowUp{{QuestionnaireID: "gad7", InHours: 1}}},
+		{name: "End of a sequence", questionnaireID: "sleep"},
+		{name: "Not in the protocol", questionnaireID: "other", seriesCompleted: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := p.Next(tt.questionnaireID, tt.seriesCompleted)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Fatalf("Unexpected follow-ups.\nGot: %+v\nExpected: %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestProtocol_Triggered(t *testing.T) {
+	p, err := Parse(visits)
+	if err != nil {
+		t.Fatalf("Error parsing protocol: %v", err)
+	}
+
+	for id, expected := range map[string]bool{"intake": false, "daily": false, "phq9": false, "follow-up": true, "exit": true, "gad7": true, "sleep": true} {
+		if got := p.Triggered(id); got != expected {
+			t.Fatalf("Unexpected trigger of %s.\nGot: %v\nExpected: %v", id, got, expected)
+		}
+	}
+}
+
+func TestProtocol_Questionnaires(t *testing.T) {
+	p, err := Parse(visits)
+	if err != nil {
+		t.Fatalf("Error parsing protocol: %v", err)
+	}
+
+	expected := []string{"intake", "follow-up", "consent", "daily", "exit", "phq9", "gad7", "sleep"}
+	if got := p.Questionnaires(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Unexpected questionnaires.\nGot: %v\nExpected: %v", got, expected)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		protocol string
+		problems []string
+	}{
+		{name: "Empty", protocol: ""},
+		{name: "Empty object", protocol: "{}"},
+		{name: "Links and sequences", protocol: visits},
+		{name: "Not JSON", protocol: "links", problems: []string{"invalid character"}},
+		{name: "Unknown field", protocol: `{"link": []}`, problems: []string{`unknown field "link"`}},
+		{name: "Trailing data", protocol: `{} {}`, problems: []string{"unexpected data"}},
+		{
+			name:     "Inconsistent link",
+			protocol: `{"links": [{"after": "a", "schedule": "a", "on": "started", "in_hours": -1}, {"after": "a"}]}`,
+			problems: []string{
+				`link #1 schedules "a" after itself`,
+				`link #1 has unknown trigger "started"`,
+				"link #1 schedules in a negative number of hours",
+				"link #2 needs both a questionnaire to wait for and one to schedule",
+			},
+		},
+		{
+			name:     "Inconsistent sequences",
+			protocol: `{"sequences": [{"id": "v", "questionnaires": ["a"]}, {"id": "v", "questionnaires": ["a", "b", "a", ""], "gap_hours": -2}, {"questionnaires": ["a", "b"]}]}`,
+			problems: []string{
+				"sequence v needs at least two questionnaires",
+				`sequence ID "v" is used more than once`,
+				`sequence v lists "a" more than once`,
+				"sequence v has an empty questionnaire ID",
+				"sequence v has a negative gap",
+				"sequence #3 has no ID",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.protocol)
+			if len(tt.problems) == 0 {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidProtocol) {
+				t.Fatalf("Expected an invalid protocol.\nGot: %v", err)
+			}
+			for _, problem := range tt.problems {
+				if !strings.Contains(err.Error(), problem) {
+					t.Fatalf("Expected the error to report %q.\nGot: %v", problem, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package questions
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrInvalidAnswers is returned when answers do not match the definition of their questionnaire.
+var ErrInvalidAnswers = errors.New("invalid answers")
+
+// AnswerError is a problem with the answer of a single question.
+type AnswerError struct {
+	// QuestionID is the question the answer is for, as given in the answers.
+	QuestionID string `json:"question_id"`
+	// Message says what is wrong with the answer.
+	Message string `json:"message"`
+}
+
+// AnswersError lists every problem found by Definition.ValidateAnswers, one per question.
+// It matches ErrInvalidAnswers with errors.Is, and callers use errors.As to report the problems per question.
+type AnswersError struct {
+	Errors []AnswerError `json:"errors"`
+}
+
+func (e *AnswersError) Error() string {
+	problems := make([]string, len(e.Errors))
+	for i, problem := range e.Errors {
+		problems[i] = problem.QuestionID + ": " + problem.Message
+	}
+	return ErrInvalidAnswers.Error() + ": " + strings.Join(problems, "; ")
+}
+
+// Is reports whether target is ErrInvalidAnswers.
+func (e *AnswersError) Is(target error) bool {
+	return target == ErrInvalidAnswers
+}
+
+// ValidateAnswers checks answers, a JSON object keyed by question ID, against the definition:
+//   - every required question is answered, with a value other than null;
+//   - every answer is for a question of the definition;
+//   - every answer has the JSON type of its question, and is one of its options or within its bounds.
+//
+// A definition without questions accepts any JSON object.
+//
+// Returns:
+//   - nil when the answers are valid.
+//   - An *AnswersError listing every problem in the order of the questions, unknown questions last,
+//     or an error wrapping ErrInvalidAnswers when the answers are not a JSON object.
+func (d *Definition) ValidateAnswers(answers json.RawMessage) error {
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(answers, &values); err != nil || values == nil {
+		return fmt.Errorf("%w: answers are not a JSON object", ErrInvalidAnswers)
+	}
+	if len(d.Questions) == 0 {
+		return nil
+	}
+
+	var problems []AnswerError
+	for _, question := range d.Questions {
+		value, ok := values[question.ID]
+		if !ok || isNull(value) {
+			if question.Required {
+				problems = append(problems, AnswerError{QuestionID: question.ID, Message: "an answer is required"})
+			}
+			continue
+		}
+		if message := question.check(value); message != "" {
+			problems = append(problems, AnswerError{QuestionID: question.ID, Message: message})
+		}
+	}
+
+	var unknown []string
+	for id := range values {
+		if _, ok := d.Question(id); !ok {
+			unknown = append(unknown, id)
+		}
+	}
+	sort.Strings(unknown)
+	for _, id := range unknown {
+		problems = append(problems, AnswerError{QuestionID: id, Message: "not a question of the questionnaire"})
+	}
+
+	if len(problems) > 0 {
+		return &AnswersError{Errors: problems}
+	}
+	return nil
+}
+
+// check returns what is wrong with a non-null answer of the question, or "" when it is valid.
+func (q Question) check(value json.RawMessage) string {
+	switch q.Type {
+	case SingleChoice:
+		var choice string
+		if err := json.Unmarshal(value, &choice); err != nil {
+			return "must be a string"
+		}
+		if !q.hasOption(choice) {
+			return fmt.Sprintf("%q is not one of the options", choice)
+		}
+	case MultiChoice:
+		var choices []string
+		if err := json.Unmarshal(value, &choices); err != nil {
+			return "must be an array of strings"
+		}
+		if q.Required && len(choices) == 0 {
+			return "at least one option must be chosen"
+		}
+		chosen := make(map[string]bool, len(choices))
+		for _, choice := range choices {
+			if !q.hasOption(choice) {
+				return fmt.Sprintf("%q is not one of the options", choice)
+			}
+			if chosen[choice] {
+				return fmt.Sprintf("%q is chosen more than once", choice)
+			}
+			chosen[choice] = true
+		}
+	case Numeric:
+		var number float64
+		if err := json.Unmarshal(value, &number); err != nil {
+			return "must be a number"
+		}
+		if q.Min != nil && number < *q.Min {
+			return fmt.Sprintf("%v is less than the minimum of %v", number, *q.Min)
+		}
+		if q.Max != nil && number > *q.Max {
+			return fmt.Sprintf("%v is more than the maximum of %v", number, *q.Max)
+		}
+	case Text:
+		var text string
+		if err := json.Unmarshal(value, &text); err != nil {
+			return "must be a string"
+		}
+		if q.Required && strings.TrimSpace(text) == "" {
+			return "an answer is required"
+		}
+		if q.MaxLength > 0 && utf8.RuneCountInString(text) > q.MaxLength {
+			return fmt.Sprintf("is longer than %d characters", q.MaxLength)
+		}
+	}
+	return ""
+}
+
+func (q Question) hasOption(value string) bool {
+	for _, option := range q.Options {
+		if option.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+// isNull reports whether a raw JSON value is null.
+func isNull(value json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(value), []byte("null"))
+}
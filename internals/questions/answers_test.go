@@ -0,0 +1,123 @@
+// File: ./internals/questions/answers_test.go
+
+package questions
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const definition = `{"questions": [
+	{"id": "mood", "type": "numeric", "required": true, "min": 0, "max": 10},
+	{"id": "sleep", "type": "single_choice", "options": [{"value": "well"}, {"value": "badly"}]},
+	{"id": "symptoms", "type": "multi_choice", "options": [{"value": "headache"}, {"value": "nausea"}]},
+	{"id": "notes", "type": "text", "max_length": 5}
+]}`
+
+func TestDefinition_ValidateAnswers(t *testing.T) {
+	d, err := Parse(definition)
+	if err != nil {
+		t.Fatalf("Error parsing definition: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		answers string
+		errors  []AnswerError
+	}{
+		{name: "Every question", answers: `{"mood": 7.5, "sleep": "well", "symptoms": ["nausea", "headache"], "notes": "tired"}`},
+		{name: "Required only", answers: `{"mood": 0}`},
+		{name: "Optional null", answers: `{"mood": 10, "sleep": null, "symptoms": [], "notes": null}`},
+		{name: "Multi-byte text within the limit", answers: `{"mood": 1, "notes": "épuis"}`},
+		{
+			name:    "Required missing",
+			answers: `{"sleep": "well"}`,
+			errors:  []AnswerError{{QuestionID: "mood", Message: "an answer is required"}},
+		},
+		{
+			name:    "Required null",
+			answers: `{"mood": null}`,
+			errors:  []AnswerError{{QuestionID: "mood", Message: "an answer is required"}},
+		},
+		{
+			name:    "Every problem, in question order",
+			answers: `{"zzz": 1, "notes": "exhausted", "symptoms": ["fever"], "sleep": "badly", "mood": 11, "aaa": true}`,
+			errors: []AnswerError{
+				{QuestionID: "mood", Message: "11 is more than the maximum of 10"},
+				{QuestionID: "symptoms", Message: `"fever" is not one of the options`},
+				{QuestionID: "notes", Message: "is longer than 5 characters"},
+				{QuestionID: "aaa", Message: "not a question of the questionnaire"},
+				{QuestionID: "zzz", Message: "not a question of the questionnaire"},
+			},
+		},
+		{
+			name:    "Wrong types",
+			answers: `{"mood": "7", "sleep": 1, "symptoms": "headache", "notes": 5}`,
+			errors: []AnswerError{
+				{QuestionID: "mood", Message: "must be a number"},
+				{QuestionID: "sleep", Message: "must be a string"},
+				{QuestionID: "symptoms", Message: "must be an array of strings"},
+				{QuestionID: "notes", Message: "must be a string"},
+			},
+		},
+		{
+			name:    "Below the minimum and repeated choice",
+			answers: `{"mood": -1, "sleep": "never", "symptoms": ["nausea", "nausea"]}`,
+			errors: []AnswerError{
+				{QuestionID: "mood", Message: "-1 is less than the minimum of 0"},
+				{QuestionID: "sleep", Message: `"never" is not one of the options`},
+				{QuestionID: "symptoms", Message: `"nausea" is chosen more than once`},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := d.ValidateAnswers(json.RawMessage(tt.answers))
+			if tt.errors == nil {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				return
+			}
+
+			var answersErr *AnswersError
+			if !errors.As(err, &answersErr) || !errors.Is(err, ErrInvalidAnswers) {
+				t.Fatalf("Expected an AnswersError.\nGot: %v", err)
+			}
+			if !reflect.DeepEqual(answersErr.Errors, tt.errors) {
+				t.Fatalf("Unexpected answer errors.\nGot: %+v\nExpected: %+v", answersErr.Errors, tt.errors)
+			}
+		})
+	}
+}
+
+func TestDefinition_ValidateAnswers_NoQuestions(t *testing.T) {
+	d, err := Parse(`{}`)
+	if err != nil {
+		t.Fatalf("Error parsing definition: %v", err)
+	}
+
+	if err := d.ValidateAnswers(json.RawMessage(`{"question": "answer"}`)); err != nil {
+		t.Fatalf("Expected any object to be valid without questions.\nGot: %v", err)
+	}
+	for _, answers := range []string{`["answer"]`, `null`, `answer`} {
+		if err := d.ValidateAnswers(json.RawMessage(answers)); !errors.Is(err, ErrInvalidAnswers) {
+			t.Fatalf("Expected %s to be invalid.\nGot: %v", answers, err)
+		}
+	}
+}
+
+func TestAnswersError_Error(t *testing.T) {
+	err := &AnswersError{Errors: []AnswerError{
+		{QuestionID: "mood", Message: "an answer is required"},
+		{QuestionID: "notes", Message: "must be a string"},
+	}}
+
+	expected := "invalid answers: mood: an answer is required; notes: must be a string"
+	if err.Error() != expected {
+		t.Fatalf("Unexpected message.\nGot: %s\nExpected: %s", err.Error(), expected)
+	}
+}
@@ -0,0 +1,169 @@
+// Package questions parses the definition of a questionnaire, stored as JSON in Questionnaire.Questions,
+// validates the answers of a participant against it, computes their scores and evaluates its rules.
+//
+// A definition lists the questions of the questionnaire:
+//
+//	{
+//	  "questions": [
+//	    {"id": "mood", "type": "numeric", "required": true, "min": 0, "max": 10},
+//	    {"id": "sleep", "type": "single_choice", "options": [{"value": "well", "score": 0}, {"value": "badly", "score": 3}]},
+//	    {"id": "symptoms", "type": "multi_choice", "options": [{"value": "headache"}, {"value": "nausea"}]},
+//	    {"id": "notes", "type": "text", "max_length": 500}
+//	  ],
+//	  "scores": [
+//	    {"id": "total", "questions": ["mood", "sleep"]}
+//	  ],
+//	  "rules": [
+//	    {"when": {"score": "total", "op": ">=", "value": 10}, "then": {"reschedule_in_hours": 24}}
+//	  ]
+//	}
+//
+// Answers are a JSON object keyed by question ID, see Definition.ValidateAnswers.
+// The optional scores are computed from valid answers by Definition.Score, see ScoreRule,
+// and the optional rules evaluated against both by Definition.Evaluate, see Rule.
+package questions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidDefinition is returned when a questionnaire definition cannot be parsed or is inconsistent.
+var ErrInvalidDefinition = errors.New("invalid questionnaire definition")
+
+// Type is the kind of answer a question expects.
+type Type string
+
+const (
+	// SingleChoice questions are answered with the value of one of their options, as a JSON string.
+	SingleChoice Type = "single_choice"
+	// MultiChoice questions are answered with the values of any of their options, as a JSON array of distinct strings.
+	MultiChoice Type = "multi_choice"
+	// Numeric questions are answered with a JSON number, between Min and Max when they are set.
+	Numeric Type = "numeric"
+	// Text questions are answered with a JSON string of at most MaxLength characters when it is set.
+	Text Type = "text"
+)
+
+// Definition is the parsed definition of a questionnaire.
+type Definition struct {
+	Questions []Question `json:"questions"`
+	// Scores are the scores computed on completion, none when empty.
+	Scores []ScoreRule `json:"scores,omitempty"`
+	// Rules adapt the schedule to the answers and scores of a completion, see Rule.
+	Rules []Rule `json:"rules,omitempty"`
+}
+
+// Question is a single question of a questionnaire.
+type Question struct {
+	// ID identifies the question, it is the key of its answer.
+	ID   string `json:"id"`
+	Type Type   `json:"type"`
+	// Text is the question as shown to the participant.
+	Text string `json:"text,omitempty"`
+	// Required questions must be answered, the others may be left out or answered with null.
+	Required bool `json:"required,omitempty"`
+	// Options are the possible answers of a choice question.
+	Options []Option `json:"options,omitempty"`
+	// Min and Max bound the answer of a numeric question, inclusive.
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+	// MaxLength bounds the answer of a text question, in characters. Zero means no limit.
+	MaxLength int `json:"max_length,omitempty"`
+}
+
+// Option is a possible answer of a choice question.
+type Option struct {
+	// Value is the answer recorded when the option is chosen.
+	Value string `json:"value"`
+	// Label is the option as shown to the participant.
+	Label string `json:"label,omitempty"`
+	// Score is the value of the option in the scores that use its question.
+	Score *float64 `json:"score,omitempty"`
+}
+
+// Parse parses and checks the definition stored in Questionnaire.Questions.
+//
+// An empty definition, such as "" or {}, has no questions: it accepts any answers, as questionnaires did before
+// definitions existed. Unknown fields are rejected so that a misspelt setting is not silently ignored.
+//
+// Parameters:
+//   - questions: The JSON definition of the questionnaire.
+//
+// Returns:
+//   - The parsed Definition.
+//   - An error wrapping ErrInvalidDefinition that lists every problem found.
+func Parse(questions string) (*Definition, error) {
+	var definition Definition
+	if strings.TrimSpace(questions) == "" {
+		return &definition, nil
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(questions))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&definition); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDefinition, err)
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("%w: unexpected data after the definition", ErrInvalidDefinition)
+	}
+
+	problems := append(definition.check(), definition.checkScores()...)
+	if problems = append(problems, definition.checkRules()...); len(problems) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidDefinition, strings.Join(problems, ", "))
+	}
+	return &definition, nil
+}
+
+// check returns every inconsistency of the definition.
+func (d *Definition) check() []string {
+	var problems []string
+	seen := make(map[string]bool, len(d.Questions))
+	for i, question := range d.Questions {
+		name := question.ID
+		if strings.TrimSpace(question.ID) == "" {
+			name = fmt.Sprintf("#%d", i+1)
+			problems = append(problems, fmt.Sprintf("question %s has no ID", name))
+		} else if seen[question.ID] {
+			problems = append(problems, fmt.Sprintf("question ID %q is used more than once", question.ID))
+		}
+		seen[question.ID] = true
+
+		switch question.Type {
+		case SingleChoice, MultiChoice:
+			if len(question.Options) == 0 {
+				problems = append(problems, fmt.Sprintf("question %s has no options", name))
+			}
+			values := make(map[string]bool, len(question.Options))
+			for _, option := range question.Options {
+				if values[option.Value] {
+					problems = append(problems, fmt.Sprintf("question %s has option %q more than once", name, option.Value))
+				}
+				values[option.Value] = true
+			}
+		case Numeric:
+			if question.Min != nil && question.Max != nil && *question.Min > *question.Max {
+				problems = append(problems, fmt.Sprintf("question %s has a min greater than its max", name))
+			}
+		case Text:
+			if question.MaxLength < 0 {
+				problems = append(problems, fmt.Sprintf("question %s has a negative max length", name))
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("question %s has unknown type %q", name, question.Type))
+		}
+	}
+	return problems
+}
+
+// Question returns the question with the given ID, and whether there is one.
+func (d *Definition) Question(id string) (Question, bool) {
+	for _, question := range d.Questions {
+		if question.ID == id {
+			return question, true
+		}
+	}
+	return Question{}, false
+}
@@ -0,0 +1,53 @@
+// File: ./internals/questions/definition_test.go
+
+package questions
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		questions int
+		err       error
+	}{
+		{name: "Empty", input: "", questions: 0},
+		{name: "Empty object", input: `{}`, questions: 0},
+		{name: "No questions", input: `{"questions": []}`, questions: 0},
+		{
+			name: "Every type",
+			input: `{"questions": [
+				{"id": "mood", "type": "numeric", "required": true, "min": 0, "max": 10},
+				{"id": "sleep", "type": "single_choice", "options": [{"value": "well", "label": "Well"}, {"value": "badly"}]},
+				{"id": "symptoms", "type": "multi_choice", "options": [{"value": "headache"}]},
+				{"id": "notes", "type": "text", "max_length": 500}
+			]}`,
+			questions: 4,
+		},
+		{name: "Not JSON", input: `mood?`, err: ErrInvalidDefinition},
+		{name: "Trailing data", input: `{} {}`, err: ErrInvalidDefinition},
+		{name: "Unknown field", input: `{"questions": [{"id": "mood", "type": "numeric", "maximum": 10}]}`, err: ErrInvalidDefinition},
+		{name: "Missing ID", input: `{"questions": [{"type": "text"}]}`, err: ErrInvalidDefinition},
+		{name: "Duplicate ID", input: `{"questions": [{"id": "a", "type": "text"}, {"id": "a", "type": "text"}]}`, err: ErrInvalidDefinition},
+		{name: "Unknown type", input: `{"questions": [{"id": "a", "type": "slider"}]}`, err: ErrInvalidDefinition},
+		{name: "Choice without options", input: `{"questions": [{"id": "a", "type": "single_choice"}]}`, err: ErrInvalidDefinition},
+		{name: "Duplicate option", input: `{"questions": [{"id": "a", "type": "multi_choice", "options": [{"value": "x"}, {"value": "x"}]}]}`, err: ErrInvalidDefinition},
+		{name: "Min greater than max", input: `{"questions": [{"id": "a", "type": "numeric", "min": 5, "max": 1}]}`, err: ErrInvalidDefinition},
+		{name: "Negative max length", input: `{"questions": [{"id": "a", "type": "text", "max_length": -1}]}`, err: ErrInvalidDefinition},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			definition, err := Parse(tt.input)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Unexpected error.\nGot: %v\nExpected: %v", err, tt.err)
+			}
+			if err == nil && len(definition.Questions) != tt.questions {
+				t.Fatalf("Unexpected number of questions.\nGot: %d\nExpected: %d", len(definition.Questions), tt.questions)
+			}
+		})
+	}
+}
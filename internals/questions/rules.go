@@ -0,0 +1,271 @@
+package questions
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Operator compares the answer or score of a Condition with its value.
+type Operator string
+
+// Operators of a Condition. The ordered ones compare numbers, = and != also compare strings.
+const (
+	Equal          Operator = "="
+	NotEqual       Operator = "!="
+	Greater        Operator = ">"
+	GreaterOrEqual Operator = ">="
+	Less           Operator = "<"
+	LessOrEqual    Operator = "<="
+	// Contains is true when the value is one of the options chosen in a multiple choice answer.
+	Contains Operator = "contains"
+)
+
+// Rule changes what happens after a completion when its condition holds, for instance
+// rescheduling sooner when a score is high:
+//
+//	{"when": {"score": "total", "op": ">=", "value": 15}, "then": {"reschedule_in_hours": 24}}
+//
+// or scheduling another questionnaire on a given answer:
+//
+//	{"when": {"answer": "q3", "op": "=", "value": "yes"}, "then": {"schedule": "questionnaire-x"}}
+type Rule struct {
+	When Condition `json:"when"`
+	Then Action    `json:"then"`
+}
+
+// Condition compares either a score or the answer of a question with a value.
+// It never holds for a score that was not computed or a question that was not answered.
+type Condition struct {
+	// Score is the ID of the score compared, with a number.
+	Score string `json:"score,omitempty"`
+	// Answer is the ID of the question whose answer is compared: numeric answers with a number with any operator,
+	// choice and text answers with a string with = or !=, and multiple choice answers with an option using contains.
+	Answer string          `json:"answer,omitempty"`
+	Op     Operator        `json:"op"`
+	Value  json.RawMessage `json:"value"`
+}
+
+// Action is what a Rule does. It does at least one of its fields.
+type Action struct {
+	// RescheduleInHours replaces HoursBetweenAttempts for the next attempt of the series. It does not add attempts:
+	// a series that has none left ends all the same.
+	RescheduleInHours int `json:"reschedule_in_hours,omitempty"`
+	// Schedule is the ID of another questionnaire of the study to schedule for the participant,
+	// ScheduleInHours after the completion.
+	Schedule        string `json:"schedule,omitempty"`
+	ScheduleInHours int    `json:"schedule_in_hours,omitempty"`
+	// Stop ends the series: no further attempt is scheduled, whatever attempts remain.
+	Stop bool `json:"stop,omitempty"`
+}
+
+// FollowUp is another questionnaire to schedule after a completion.
+type FollowUp struct {
+	QuestionnaireID string
+	InHours         int
+}
+
+// Outcome is the combined effect of the rules whose condition holds.
+type Outcome struct {
+	// RescheduleInHours is the interval of the first matching rule that sets one, zero when none does.
+	RescheduleInHours int
+	// Stop reports that a matching rule stops the series.
+	Stop bool
+	// FollowUps are the questionnaires the matching rules schedule, each once, in rule order.
+	FollowUps []FollowUp
+}
+
+// checkRules returns every inconsistency of the rules of the definition.
+func (d *Definition) checkRules() []string {
+	var problems []string
+	for i, rule := range d.Rules {
+		name := fmt.Sprintf("rule #%d", i+1)
+		if problem := d.checkCondition(rule.When); problem != "" {
+			problems = append(problems, name+" "+problem)
+		}
+
+		action := rule.Then
+		if action.RescheduleInHours == 0 && action.Schedule == "" && !action.Stop {
+			problems = append(problems, name+" does nothing")
+		}
+		if action.RescheduleInHours < 0 {
+			problems = append(problems, name+" reschedules in a negative number of hours")
+		}
+		if action.ScheduleInHours < 0 {
+			problems = append(problems, name+" schedules in a negative number of hours")
+		}
+		if action.ScheduleInHours > 0 && action.Schedule == "" {
+			problems = append(problems, name+" has schedule_in_hours but no questionnaire to schedule")
+		}
+	}
+	return problems
+}
+
+// checkCondition returns what is wrong with a condition, or "" when it is consistent.
+func (d *Definition) checkCondition(c Condition) string {
+	var number float64
+	var text string
+	isNumber := json.Unmarshal(c.Value, &number) == nil
+	isText := json.Unmarshal(c.Value, &text) == nil
+
+	switch {
+	case (c.Score == "") == (c.Answer == ""):
+		return "must compare either a score or an answer"
+	case c.Score != "":
+		if !d.hasScore(c.Score) {
+			return fmt.Sprintf("compares unknown score %q", c.Score)
+		}
+		if !c.Op.ordered() {
+			return fmt.Sprintf("compares score %q with unknown operator %q", c.Score, c.Op)
+		}
+		if !isNumber {
+			return fmt.Sprintf("compares score %q with a value that is not a number", c.Score)
+		}
+		return ""
+	}
+
+	question, ok := d.Question(c.Answer)
+	if !ok {
+		return fmt.Sprintf("compares the answer of unknown question %q", c.Answer)
+	}
+	switch question.Type {
+	case Numeric:
+		if !c.Op.ordered() || !isNumber {
+			return fmt.Sprintf("must compare numeric question %q with a number using =, !=, >, >=, < or <=", c.Answer)
+		}
+	case SingleChoice, Text:
+		if c.Op != Equal && c.Op != NotEqual || !isText {
+			return fmt.Sprintf("must compare question %q with a string using = or !=", c.Answer)
+		}
+		if question.Type == SingleChoice && !question.hasOption(text) {
+			return fmt.Sprintf("compares question %q with %q, which is not one of its options", c.Answer, text)
+		}
+	case MultiChoice:
+		if c.Op != Contains || !isText {
+			return fmt.Sprintf("must compare multiple choice question %q with an option using contains", c.Answer)
+		}
+		if !question.hasOption(text) {
+			return fmt.Sprintf("compares question %q with %q, which is not one of its options", c.Answer, text)
+		}
+	}
+	return ""
+}
+
+func (d *Definition) hasScore(id string) bool {
+	for _, rule := range d.Scores {
+		if rule.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// ordered reports whether the operator compares numbers.
+func (op Operator) ordered() bool {
+	switch op {
+	case Equal, NotEqual, Greater, GreaterOrEqual, Less, LessOrEqual:
+		return true
+	}
+	return false
+}
+
+// Evaluate applies the rules of the definition to answers that passed ValidateAnswers and their scores.
+//
+// Returns:
+//   - The Outcome of the rules whose condition holds, the zero Outcome when none does.
+//   - An error wrapping ErrInvalidAnswers when the answers are not a JSON object.
+func (d *Definition) Evaluate(answers json.RawMessage, scores Scores) (Outcome, error) {
+	var outcome Outcome
+	if len(d.Rules) == 0 {
+		return outcome, nil
+	}
+
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(answers, &values); err != nil {
+		return outcome, fmt.Errorf("%w: answers are not a JSON object", ErrInvalidAnswers)
+	}
+
+	scheduled := make(map[string]bool)
+	for _, rule := range d.Rules {
+		if !d.holds(rule.When, values, scores) {
+			continue
+		}
+
+		if rule.Then.RescheduleInHours > 0 && outcome.RescheduleInHours == 0 {
+			outcome.RescheduleInHours = rule.Then.RescheduleInHours
+		}
+		if rule.Then.Stop {
+			outcome.Stop = true
+		}
+		if id := rule.Then.Schedule; id != "" && !scheduled[id] {
+			scheduled[id] = true
+			outcome.FollowUps = append(outcome.FollowUps, FollowUp{QuestionnaireID: id, InHours: rule.Then.ScheduleInHours})
+		}
+	}
+	return outcome, nil
+}
+
+// holds reports whether the condition holds for the answers and scores.
+func (d *Definition) holds(c Condition, values map[string]json.RawMessage, scores Scores) bool {
+	if c.Score != "" {
+		score, ok := scores[c.Score]
+		if !ok {
+			return false
+		}
+		return compare(c.Op, score, c.Value)
+	}
+
+	question, _ := d.Question(c.Answer)
+	value, ok := values[c.Answer]
+	if !ok || isNull(value) {
+		return false
+	}
+	switch question.Type {
+	case Numeric:
+		var number float64
+		if err := json.Unmarshal(value, &number); err != nil {
+			return false
+		}
+		return compare(c.Op, number, c.Value)
+	case MultiChoice:
+		var choices []string
+		var option string
+		if json.Unmarshal(value, &choices) != nil || json.Unmarshal(c.Value, &option) != nil {
+			return false
+		}
+		for _, choice := range choices {
+			if choice == option {
+				return true
+			}
+		}
+		return false
+	default:
+		var answer, expected string
+		if json.Unmarshal(value, &answer) != nil || json.Unmarshal(c.Value, &expected) != nil {
+			return false
+		}
+		return (answer == expected) == (c.Op == Equal)
+	}
+}
+
+// compare applies an ordered operator to a number and the JSON number of a condition.
+func compare(op Operator, number float64, raw json.RawMessage) bool {
+	var value float64
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return false
+	}
+	switch op {
+	case Equal:
+		return number == value
+	case NotEqual:
+		return number != value
+	case Greater:
+		return number > value
+	case GreaterOrEqual:
+		return number >= value
+	case Less:
+		return number < value
+	case LessOrEqual:
+		return number <= value
+	}
+	return false
+}
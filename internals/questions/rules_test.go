@@ -0,0 +1,118 @@
+// File: ./internals/questions/rules_test.go
+
+package questions
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const adaptive = `{
+	"questions": [
+		{"id": "mood", "type": "numeric", "min": 0, "max": 27},
+		{"id": "harm", "type": "single_choice", "options": [{"value": "yes"}, {"value": "no"}]},
+		{"id": "symptoms", "type": "multi_choice", "options": [{"value": "sleep"}, {"value": "pain"}]},
+		{"id": "notes", "type": "text"}
+	],
+	"scores": [{"id": "total", "questions": ["mood"]}],
+	"rules": [
+		{"when": {"score": "total", "op": ">=", "value": 15}, "then": {"reschedule_in_hours": 24}},
+		{"when": {"score": "total", "op": ">=", "value": 10}, "then": {"reschedule_in_hours": 72}},
+		{"when": {"answer": "harm", "op": "=", "value": "yes"}, "then": {"schedule": "safety", "schedule_in_hours": 1}},
+		{"when": {"answer": "symptoms", "op": "contains", "value": "pain"}, "then": {"schedule": "pain"}},
+		{"when": {"answer": "mood", "op": ">", "value": 20}, "then": {"schedule": "safety", "stop": true}},
+		{"when": {"answer": "notes", "op": "!=", "value": "withdraw"}, "then": {"schedule": "diary"}}
+	]
+}`
+
+func TestDefinition_Evaluate(t *testing.T) {
+	d, err := Parse(adaptive)
+	if err != nil {
+		t.Fatalf("Error parsing definition: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		answers string
+		outcome Outcome
+	}{
+		{name: "No rule holds", answers: `{"mood": 3, "harm": "no", "symptoms": ["sleep"]}`, outcome: Outcome{}},
+		{name: "First reschedule wins", answers: `{"mood": 16}`, outcome: Outcome{RescheduleInHours: 24}},
+		{name: "Lower threshold", answers: `{"mood": 12}`, outcome: Outcome{RescheduleInHours: 72}},
+		{
+			name:    "Answers schedule follow-ups",
+			answers: `{"mood": 0, "harm": "yes", "symptoms": ["sleep", "pain"]}`,
+			outcome: Outcome{FollowUps: []FollowUp{{QuestionnaireID: "safety", InHours: 1}, {QuestionnaireID: "pain"}}},
+		},
+		{
+			name:    "Stop, scheduling each questionnaire once",
+			answers: `{"mood": 21, "harm": "yes"}`,
+			outcome: Outcome{RescheduleInHours: 24, Stop: true, FollowUps: []FollowUp{{QuestionnaireID: "safety", InHours: 1}}},
+		},
+		{name: "Text comparison", answers: `{"mood": 0, "notes": "fine"}`, outcome: Outcome{FollowUps: []FollowUp{{QuestionnaireID: "diary"}}}},
+		{name: "Unanswered questions never hold", answers: `{"harm": null, "notes": null}`, outcome: Outcome{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answers := json.RawMessage(tt.answers)
+			if err := d.ValidateAnswers(answers); err != nil {
+				t.Fatalf("Unexpected invalid answers: %v", err)
+			}
+			scores, err := d.Score(answers)
+			if err != nil {
+				t.Fatalf("Unexpected error scoring: %v", err)
+			}
+
+			outcome, err := d.Evaluate(answers, scores)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(outcome, tt.outcome) {
+				t.Fatalf("Unexpected outcome.\nGot: %+v\nExpected: %+v", outcome, tt.outcome)
+			}
+		})
+	}
+}
+
+func TestParse_Rules(t *testing.T) {
+	questions := `"questions": [
+		{"id": "mood", "type": "numeric"},
+		{"id": "harm", "type": "single_choice", "options": [{"value": "yes"}, {"value": "no"}]},
+		{"id": "symptoms", "type": "multi_choice", "options": [{"value": "pain"}]},
+		{"id": "notes", "type": "text"}
+	],
+	"scores": [{"id": "total", "questions": ["mood"]}]`
+
+	tests := []struct {
+		name  string
+		rules string
+		err   error
+	}{
+		{name: "Valid", rules: `[{"when": {"score": "total", "op": "<", "value": 5}, "then": {"stop": true}}]`},
+		{name: "Both score and answer", rules: `[{"when": {"score": "total", "answer": "mood", "op": "<", "value": 5}, "then": {"stop": true}}]`, err: ErrInvalidDefinition},
+		{name: "Neither score nor answer", rules: `[{"when": {"op": "<", "value": 5}, "then": {"stop": true}}]`, err: ErrInvalidDefinition},
+		{name: "Unknown score", rules: `[{"when": {"score": "sum", "op": "<", "value": 5}, "then": {"stop": true}}]`, err: ErrInvalidDefinition},
+		{name: "Unknown operator", rules: `[{"when": {"score": "total", "op": "~", "value": 5}, "then": {"stop": true}}]`, err: ErrInvalidDefinition},
+		{name: "Score with a string", rules: `[{"when": {"score": "total", "op": "=", "value": "5"}, "then": {"stop": true}}]`, err: ErrInvalidDefinition},
+		{name: "Unknown question", rules: `[{"when": {"answer": "energy", "op": "=", "value": 1}, "then": {"stop": true}}]`, err: ErrInvalidDefinition},
+		{name: "Choice ordered", rules: `[{"when": {"answer": "harm", "op": ">", "value": "yes"}, "then": {"stop": true}}]`, err: ErrInvalidDefinition},
+		{name: "Choice not an option", rules: `[{"when": {"answer": "harm", "op": "=", "value": "maybe"}, "then": {"stop": true}}]`, err: ErrInvalidDefinition},
+		{name: "Multiple choice equal", rules: `[{"when": {"answer": "symptoms", "op": "=", "value": "pain"}, "then": {"stop": true}}]`, err: ErrInvalidDefinition},
+		{name: "Text contains", rules: `[{"when": {"answer": "notes", "op": "contains", "value": "pain"}, "then": {"stop": true}}]`, err: ErrInvalidDefinition},
+		{name: "Does nothing", rules: `[{"when": {"score": "total", "op": "<", "value": 5}, "then": {}}]`, err: ErrInvalidDefinition},
+		{name: "Negative hours", rules: `[{"when": {"score": "total", "op": "<", "value": 5}, "then": {"reschedule_in_hours": -1}}]`, err: ErrInvalidDefinition},
+		{name: "Hours without questionnaire", rules: `[{"when": {"score": "total", "op": "<", "value": 5}, "then": {"stop": true, "schedule_in_hours": 2}}]`, err: ErrInvalidDefinition},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(`{` + questions + `, "rules": ` + tt.rules + `}`)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Unexpected error.\nGot: %v\nExpected: %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package questions
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// Method is how a score combines the values of its questions.
+type Method string
+
+const (
+	// Sum adds the values of the answered questions. It is the default.
+	Sum Method = "sum"
+	// Mean averages the values of the answered questions.
+	Mean Method = "mean"
+)
+
+// ScoreRule declares a score computed from the answers, such as the total of an instrument or one of its subscales:
+//
+//	{"id": "total", "questions": ["q1", "q2", "q3"], "method": "sum"}
+//
+// The value of a numeric question is its answer, the value of a choice question the Score of the options chosen.
+type ScoreRule struct {
+	// ID names the score in the scores of a result.
+	ID string `json:"id"`
+	// Questions are the IDs of the questions the score is computed from.
+	Questions []string `json:"questions"`
+	// Method combines the values of the questions, Sum when empty.
+	Method Method `json:"method,omitempty"`
+}
+
+// Scores are the scores of a result, keyed by ScoreRule ID.
+type Scores map[string]float64
+
+// checkScores returns every inconsistency of the score rules of the definition.
+func (d *Definition) checkScores() []string {
+	var problems []string
+	seen := make(map[string]bool, len(d.Scores))
+	for i, rule := range d.Scores {
+		name := rule.ID
+		if rule.ID == "" {
+			name = fmt.Sprintf("#%d", i+1)
+			problems = append(problems, fmt.Sprintf("score %s has no ID", name))
+		} else if seen[rule.ID] {
+			problems = append(problems, fmt.Sprintf("score ID %q is used more than once", rule.ID))
+		}
+		seen[rule.ID] = true
+
+		switch rule.Method {
+		case "", Sum, Mean:
+		default:
+			problems = append(problems, fmt.Sprintf("score %s has unknown method %q", name, rule.Method))
+		}
+		if len(rule.Questions) == 0 {
+			problems = append(problems, fmt.Sprintf("score %s has no questions", name))
+		}
+		for _, id := range rule.Questions {
+			question, ok := d.Question(id)
+			if !ok {
+				problems = append(problems, fmt.Sprintf("score %s uses unknown question %q", name, id))
+			} else if !question.scorable() {
+				problems = append(problems, fmt.Sprintf("score %s uses question %q, which has no numeric value", name, id))
+			}
+		}
+	}
+	return problems
+}
+
+// scorable reports whether the answers of the question have a numeric value: numeric questions,
+// and choice questions whose every option has a score.
+func (q Question) scorable() bool {
+	switch q.Type {
+	case Numeric:
+		return true
+	case SingleChoice, MultiChoice:
+		for _, option := range q.Options {
+			if option.Score == nil {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Score computes the scores declared by the definition from answers that passed ValidateAnswers.
+// Unanswered questions are left out: they add nothing to a sum and do not count towards a mean.
+// A score none of whose questions were answered is left out of the result.
+//
+// Returns:
+//   - The scores, nil when the definition declares none.
+//   - An error wrapping ErrInvalidAnswers when the answers are not a JSON object.
+func (d *Definition) Score(answers json.RawMessage) (Scores, error) {
+	if len(d.Scores) == 0 {
+		return nil, nil
+	}
+
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(answers, &values); err != nil {
+		return nil, fmt.Errorf("%w: answers are not a JSON object", ErrInvalidAnswers)
+	}
+
+	scores := make(Scores, len(d.Scores))
+	for _, rule := range d.Scores {
+		var total float64
+		answered := 0
+		for _, id := range rule.Questions {
+			question, _ := d.Question(id)
+			value, ok := values[id]
+			if !ok || isNull(value) {
+				continue
+			}
+			v, err := question.value(value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrInvalidAnswers, id, err)
+			}
+			total += v
+			answered++
+		}
+
+		if answered == 0 {
+			continue
+		}
+		if rule.Method == Mean {
+			total /= float64(answered)
+		}
+		if math.IsInf(total, 0) {
+			return nil, fmt.Errorf("%w: score %s is out of range", ErrInvalidAnswers, rule.ID)
+		}
+		scores[rule.ID] = total
+	}
+	return scores, nil
+}
+
+// value returns the numeric value of a non-null answer of a scorable question.
+func (q Question) value(answer json.RawMessage) (float64, error) {
+	switch q.Type {
+	case Numeric:
+		var number float64
+		err := json.Unmarshal(answer, &number)
+		return number, err
+	case SingleChoice:
+		var choice string
+		if err := json.Unmarshal(answer, &choice); err != nil {
+			return 0, err
+		}
+		return q.optionScore(choice), nil
+	case MultiChoice:
+		var choices []string
+		if err := json.Unmarshal(answer, &choices); err != nil {
+			return 0, err
+		}
+		var total float64
+		for _, choice := range choices {
+			total += q.optionScore(choice)
+		}
+		return total, nil
+	}
+	return 0, fmt.Errorf("question of type %s has no numeric value", q.Type)
+}
+
+func (q Question) optionScore(value string) float64 {
+	for _, option := range q.Options {
+		if option.Value == value && option.Score != nil {
+			return *option.Score
+		}
+	}
+	return 0
+}
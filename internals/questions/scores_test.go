@@ -0,0 +1,115 @@
+// File: ./internals/questions/scores_test.go
+
+package questions
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// phq is a shortened PHQ-9 style instrument with a total and two subscales.
+const phq = `{
+	"questions": [
+		{"id": "interest", "type": "single_choice", "options": [{"value": "not at all", "score": 0}, {"value": "several days", "score": 1}, {"value": "nearly every day", "score": 3}]},
+		{"id": "down", "type": "single_choice", "options": [{"value": "not at all", "score": 0}, {"value": "several days", "score": 1}, {"value": "nearly every day", "score": 3}]},
+		{"id": "symptoms", "type": "multi_choice", "options": [{"value": "sleep", "score": 1}, {"value": "appetite", "score": 2}]},
+		{"id": "energy", "type": "numeric", "min": 0, "max": 10},
+		{"id": "notes", "type": "text"}
+	],
+	"scores": [
+		{"id": "total", "questions": ["interest", "down", "symptoms", "energy"]},
+		{"id": "mood", "questions": ["interest", "down"], "method": "mean"},
+		{"id": "somatic", "questions": ["symptoms", "energy"], "method": "sum"}
+	]
+}`
+
+func TestDefinition_Score(t *testing.T) {
+	d, err := Parse(phq)
+	if err != nil {
+		t.Fatalf("Error parsing definition: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		answers string
+		scores  Scores
+	}{
+		{
+			name:    "Every question",
+			answers: `{"interest": "nearly every day", "down": "several days", "symptoms": ["sleep", "appetite"], "energy": 2.5, "notes": "tired"}`,
+			scores:  Scores{"total": 9.5, "mood": 2, "somatic": 5.5},
+		},
+		{
+			name:    "Unanswered questions are left out",
+			answers: `{"interest": "several days", "symptoms": []}`,
+			scores:  Scores{"total": 1, "mood": 1, "somatic": 0},
+		},
+		{
+			name:    "Scores without answers are left out",
+			answers: `{"energy": null, "notes": "fine"}`,
+			scores:  Scores{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := d.ValidateAnswers(json.RawMessage(tt.answers)); err != nil {
+				t.Fatalf("Unexpected invalid answers: %v", err)
+			}
+			scores, err := d.Score(json.RawMessage(tt.answers))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(scores, tt.scores) {
+				t.Fatalf("Unexpected scores.\nGot: %v\nExpected: %v", scores, tt.scores)
+			}
+		})
+	}
+}
+
+func TestDefinition_Score_NoRules(t *testing.T) {
+	d, err := Parse(`{"questions": [{"id": "mood", "type": "numeric"}]}`)
+	if err != nil {
+		t.Fatalf("Error parsing definition: %v", err)
+	}
+
+	scores, err := d.Score(json.RawMessage(`{"mood": 4}`))
+	if err != nil || scores != nil {
+		t.Fatalf("Expected no scores.\nGot: %v, %v", scores, err)
+	}
+}
+
+func TestParse_Scores(t *testing.T) {
+	questions := `"questions": [
+		{"id": "mood", "type": "numeric"},
+		{"id": "sleep", "type": "single_choice", "options": [{"value": "well", "score": 0}, {"value": "badly"}]},
+		{"id": "notes", "type": "text"}
+	]`
+
+	tests := []struct {
+		name   string
+		scores string
+		err    error
+	}{
+		{name: "Numeric question", scores: `[{"id": "total", "questions": ["mood"]}]`},
+		{name: "Mean", scores: `[{"id": "total", "questions": ["mood"], "method": "mean"}]`},
+		{name: "Missing ID", scores: `[{"questions": ["mood"]}]`, err: ErrInvalidDefinition},
+		{name: "Duplicate ID", scores: `[{"id": "total", "questions": ["mood"]}, {"id": "total", "questions": ["mood"]}]`, err: ErrInvalidDefinition},
+		{name: "Unknown method", scores: `[{"id": "total", "questions": ["mood"], "method": "median"}]`, err: ErrInvalidDefinition},
+		{name: "No questions", scores: `[{"id": "total", "questions": []}]`, err: ErrInvalidDefinition},
+		{name: "Unknown question", scores: `[{"id": "total", "questions": ["energy"]}]`, err: ErrInvalidDefinition},
+		{name: "Option without a score", scores: `[{"id": "total", "questions": ["sleep"]}]`, err: ErrInvalidDefinition},
+		{name: "Text question", scores: `[{"id": "total", "questions": ["notes"]}]`, err: ErrInvalidDefinition},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(`{` + questions + `, "scores": ` + tt.scores + `}`)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Unexpected error.\nGot: %v\nExpected: %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package rescheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"rescheduler/internals/models"
+	"rescheduler/internals/store"
+	"rescheduler/internals/timestamp"
+)
+
+// ErrInvalidEnrollment is returned when a participant to enroll is missing the fields needed to enroll them.
+var ErrInvalidEnrollment = errors.New("invalid enrollment")
+
+// EnrollmentResult describes what Enroll did for a single participant.
+type EnrollmentResult struct {
+	// Participant is the enrolled participant, as stored.
+	Participant *models.Participant
+	// Enrolled reports that the participant was enrolled by this call rather than an earlier one.
+	Enrolled bool
+	// Created are the initial schedules created by this call.
+	Created []models.ScheduledQuestionnaire
+	// Existing are the pending schedules an earlier enrollment of the participant created, still due at enrollment.
+	Existing []models.ScheduledQuestionnaire
+}
+
+// Enroll enrolls a participant in their study and creates the first pending schedule of every questionnaire
+// of the study, sending a new schedule message for each. The questionnaires the protocol of the study schedules
+// after another one are left out: HandleCompletion schedules them when the questionnaire they follow is completed.
+//
+// Enroll is idempotent, so an enrollment that failed part way can be retried with the same participant:
+//   - A participant already enrolled in the same study is not enrolled again. One enrolled in another study,
+//     or no longer active, is rejected with store.ErrConflict.
+//   - A questionnaire the participant already has a schedule for, in any status, gets no new schedule.
+//     Its follow-ups are the business of HandleCompletion.
+//   - The new schedule message is sent again for the pending schedules created by an earlier call,
+//     since that call may have failed before sending it. Consumers can drop repeats by schedule ID.
+//     Only the schedules due at enrollment are: the next attempts and follow-ups HandleCompletion created
+//     were announced when it created them.
+//
+// A participant without EnrolledAt is enrolled at the current time of the clock, and one without TimeZone
+// in the time zone of their study. Enrolling in a closed study, or one past its end date, is rejected with ErrStudyClosed.
+//
+// Parameters:
+//   - ctx: A context.Context object.
+//   - participant: The participant to enroll. ID and StudyID are required, the ID identifies retries.
+//
+// Returns:
+//   - An EnrollmentResult describing the participant and their initial schedules.
+//   - An error if the participant is invalid or conflicts with an earlier enrollment, or any store or queue operation fails.
+func (r *Rescheduler) Enroll(ctx context.Context, participant *models.Participant) (*EnrollmentResult, error) {
+	if participant == nil || participant.ID == "" || participant.StudyID == "" {
+		return nil, fmt.Errorf("%w: participant ID and study ID are required", ErrInvalidEnrollment)
+	}
+
+	study, err := r.openStudy(participant.StudyID)
+	if err != nil {
+		return nil, err
+	}
+	if study.EndsAt.Valid && !r.clock.Now().Before(study.EndsAt.TimeStamp.Time) {
+		return nil, fmt.Errorf("%w: study %s ended at %v", ErrStudyClosed, study.ID, study.EndsAt.TimeStamp)
+	}
+
+	result, err := r.enrollParticipant(participant, study)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	questionnaires, err := r.studyQuestionnaires(result.Participant.StudyID)
+	if err != nil {
+		return nil, err
+	}
+	studyProtocol, err := r.studyProtocol(result.Participant.StudyID)
+	if err != nil {
+		return nil, err
+	}
+
+	schedules, err := r.stores.ScheduledQuestionnaires.ListByParticipant(result.Participant.ID)
+	if err != nil {
+		return nil, fmt.Errorf("listing schedules: %w", err)
+	}
+	scheduled := make(map[string]models.ScheduledQuestionnaire, len(schedules))
+	for _, schedule := range schedules {
+		if _, ok := scheduled[schedule.QuestionnaireID]; !ok || schedule.Status == models.ScheduledQuestionnairePending {
+			scheduled[schedule.QuestionnaireID] = schedule
+		}
+	}
+
+	for _, questionnaire := range questionnaires {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if studyProtocol.Triggered(questionnaire.ID) {
+			continue
+		}
+
+		if schedule, ok := scheduled[questionnaire.ID]; ok {
+			// A pending schedule due later is a next attempt HandleCompletion created and announced.
+			if schedule.Status == models.ScheduledQuestionnairePending && schedule.ScheduledAt.Equal(result.Participant.EnrolledAt.Time) {
+				result.Existing = append(result.Existing, schedule)
+			}
+			continue
+		}
+
+		// The first attempt is due at enrollment, the following ones HoursBetweenAttempts after each completion.
+		schedule := models.ScheduledQuestionnaire{
+			ID:                   r.ids.NewID(),
+			QuestionnaireID:      questionnaire.ID,
+			QuestionnaireVersion: questionnaire.Version,
+			ParticipantID:        result.Participant.ID,
+			ScheduledAt:          result.Participant.EnrolledAt,
+			Status:               models.ScheduledQuestionnairePending,
+		}
+		err := r.stores.ScheduledQuestionnaires.Create(&schedule)
+		if errors.Is(err, store.ErrDuplicate) {
+			// A concurrent enrollment of the same participant created it first.
+			existing, findErr := r.stores.ScheduledQuestionnaires.FindScheduledQuestionnaireByQuestionnaireIDAndUserID(questionnaire.ID, result.Participant.ID)
+			if findErr != nil {
+				return nil, fmt.Errorf("finding concurrently created schedule: %w", findErr)
+			}
+			result.Existing = append(result.Existing, *existing)
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("creating initial schedule: %w", err)
+		}
+		result.Created = append(result.Created, schedule)
+	}
+
+	for _, schedules := range [][]models.ScheduledQuestionnaire{result.Created, result.Existing} {
+		for _, schedule := range schedules {
+			if err := r.queue.SendNewScheduleMessage(schedule.ID, schedule.ParticipantID); err != nil {
+				return nil, fmt.Errorf("sending new schedule message: %w", err)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// enrollParticipant enrolls the participant unless an earlier call did, and returns the stored participant.
+// The study gives the default time zone of the participant.
+func (r *Rescheduler) enrollParticipant(participant *models.Participant, study *models.Study) (*EnrollmentResult, error) {
+	existing, err := r.stores.Participants.FindParticipantByID(participant.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		enrolled := *participant
+		if enrolled.EnrolledAt.IsZero() {
+			enrolled.EnrolledAt = timestamp.TimeStamp{Time: r.clock.Now()}
+		}
+		if enrolled.TimeZone == "" {
+			enrolled.TimeZone = study.TimeZone
+		}
+		err = r.stores.Participants.Enroll(&enrolled)
+		if err == nil {
+			return &EnrollmentResult{Participant: &enrolled, Enrolled: true}, nil
+		}
+		if !errors.Is(err, store.ErrDuplicate) {
+			return nil, fmt.Errorf("enrolling participant: %w", err)
+		}
+		// A concurrent enrollment of the same participant got there first.
+		existing, err = r.stores.Participants.FindParticipantByID(participant.ID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("finding participant: %w", err)
+	}
+
+	if existing.StudyID != participant.StudyID {
+		return nil, fmt.Errorf("%w: participant %s is enrolled in study %q", store.ErrConflict, existing.ID, existing.StudyID)
+	}
+	if existing.Status != models.ParticipantActive {
+		return nil, fmt.Errorf("%w: participant %s is %s", store.ErrConflict, existing.ID, existing.Status)
+	}
+	return &EnrollmentResult{Participant: existing}, nil
+}
+
+// studyQuestionnaires returns every questionnaire of a study, reading all pages of the listing.
+func (r *Rescheduler) studyQuestionnaires(studyID string) ([]models.Questionnaire, error) {
+	var questionnaires []models.Questionnaire
+	filter := store.QuestionnaireFilter{Limit: store.MaxPageSize}
+	for {
+		page, err := r.stores.Questionnaires.ListByStudy(studyID, filter)
+		if err != nil {
+			return nil, fmt.Errorf("listing questionnaires: %w", err)
+		}
+		questionnaires = append(questionnaires, page.Questionnaires...)
+		if page.NextCursor == "" {
+			return questionnaires, nil
+		}
+		filter.Cursor = page.NextCursor
+	}
+}
@@ -0,0 +1,266 @@
+// File: ./internals/rescheduler/enrollment_test.go
+
+package rescheduler
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"rescheduler/internals/clock"
+	"rescheduler/internals/idgen"
+	"rescheduler/internals/models"
+	"rescheduler/internals/store"
+	"rescheduler/internals/storetest/memstore"
+	"rescheduler/internals/timestamp"
+)
+
+type enrollmentFixture struct {
+	rescheduler  *Rescheduler
+	participants *memstore.ParticipantStore
+	schedules    *memstore.ScheduledQuestionnaireStore
+	protocols    *memstore.StudyProtocolStore
+	studies      *memstore.StudyStore
+	queue        *recordingQueue
+	now          time.Time
+}
+
+func newEnrollmentFixture(t *testing.T) *enrollmentFixture {
+	t.Helper()
+
+	questionnaires := memstore.NewQuestionnaireStore(
+		models.Questionnaire{ID: "mood", StudyID: "Study5", Name: "Mood", HoursBetweenAttempts: 24},
+		models.Questionnaire{ID: "sleep", StudyID: "Study5", Name: "Sleep", HoursBetweenAttempts: 24},
+		models.Questionnaire{ID: "pain", StudyID: "Study6", Name: "Pain", HoursBetweenAttempts: 24},
+	)
+	results := memstore.NewQuestionnaireResultStore()
+	schedules := memstore.NewScheduledQuestionnaireStore(questionnaires).WithResults(results)
+	participants := memstore.NewParticipantStore().WithSchedules(schedules)
+	schedules.WithParticipants(participants)
+	protocols := memstore.NewStudyProtocolStore()
+	studies := memstore.NewStudyStore(models.Study{ID: "Study5", Name: "Study 5", Status: models.StudyActive, TimeZone: "Europe/Paris", HoursBetweenAttempts: 24})
+	queue := &recordingQueue{}
+	now := time.Date(2023, 12, 5, 9, 0, 0, 0, time.UTC)
+
+	r, err := New(Stores{
+		Questionnaires:          questionnaires,
+		ScheduledQuestionnaires: schedules,
+		Participants:            participants,
+		QuestionnaireResults:    results,
+		Protocols:               protocols,
+		Studies:                 studies,
+	}, queue, clock.NewFake(now), idgen.NewSequence("id"))
+	if err != nil {
+		t.Fatalf("Error creating rescheduler: %v", err)
+	}
+
+	return &enrollmentFixture{rescheduler: r, participants: participants, schedules: schedules, protocols: protocols, studies: studies, queue: queue, now: now}
+}
+
+func TestEnroll_SkipsQuestionnairesTheProtocolSchedules(t *testing.T) {
+	f := newEnrollmentFixture(t)
+	f.protocols.Add(models.StudyProtocol{StudyID: "Study5", Definition: `{"links": [{"after": "mood", "schedule": "sleep", "in_hours": 48}]}`})
+
+	result, err := f.rescheduler.Enroll(context.Background(), &models.Participant{ID: "p1", StudyID: "Study5"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Created) != 1 || result.Created[0].QuestionnaireID != "mood" {
+		t.Fatalf("Unexpected schedules.\nGot: %+v\nExpected: only mood", result.Created)
+	}
+}
+
+func TestEnroll_CreatesInitialSchedules(t *testing.T) {
+	f := newEnrollmentFixture(t)
+
+	result, err := f.rescheduler.Enroll(context.Background(), &models.Participant{ID: "p1", StudyID: "Study5", TimeZone: "Europe/London"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !result.Enrolled || result.Participant.Status != models.ParticipantActive || !result.Participant.EnrolledAt.Equal(f.now) {
+		t.Fatalf("Unexpected participant.\nGot: %+v, enrolled %v", result.Participant, result.Enrolled)
+	}
+	if len(result.Created) != 2 || len(result.Existing) != 0 {
+		t.Fatalf("Unexpected schedules.\nGot: created %+v, existing %+v\nExpected: 2 created", result.Created, result.Existing)
+	}
+	for i, questionnaireID := range []string{"mood", "sleep"} {
+		schedule := result.Created[i]
+		if schedule.QuestionnaireID != questionnaireID || schedule.Status != models.ScheduledQuestionnairePending || !schedule.ScheduledAt.Equal(f.now) {
+			t.Fatalf("Unexpected initial schedule.\nGot: %+v\nExpected: %s pending at %v", schedule, questionnaireID, f.now)
+		}
+	}
+	if len(f.queue.newSchedule) != 2 || f.queue.newSchedule[0] != result.Created[0].ID+"/p1" {
+		t.Fatalf("Unexpected new schedule messages.\nGot: %v", f.queue.newSchedule)
+	}
+	if _, err := f.participants.FindParticipantByID("p1"); err != nil {
+		t.Fatalf("Expected the participant to be stored: %v", err)
+	}
+}
+
+func TestEnroll_Retry(t *testing.T) {
+	f := newEnrollmentFixture(t)
+	participant := models.Participant{ID: "p1", StudyID: "Study5"}
+
+	first, err := f.rescheduler.Enroll(context.Background(), &participant)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The participant completes the mood questionnaire for good before enrollment is retried.
+	mood := first.Created[0]
+	mood.Status = models.ScheduledQuestionnaireCompleted
+	if err := f.schedules.Update(&mood); err != nil {
+		t.Fatalf("Error completing schedule: %v", err)
+	}
+
+	second, err := f.rescheduler.Enroll(context.Background(), &participant)
+	if err != nil {
+		t.Fatalf("Unexpected error on retry: %v", err)
+	}
+	if second.Enrolled || len(second.Created) != 0 {
+		t.Fatalf("Expected nothing new on retry.\nGot: enrolled %v, created %+v", second.Enrolled, second.Created)
+	}
+	if len(second.Existing) != 1 || second.Existing[0].ID != first.Created[1].ID {
+		t.Fatalf("Unexpected existing schedules.\nGot: %+v\nExpected: %s", second.Existing, first.Created[1].ID)
+	}
+	if len(f.schedules.All()) != 2 {
+		t.Fatalf("Unexpected number of schedules after a retry.\nGot: %d\nExpected: 2", len(f.schedules.All()))
+	}
+	expected := []string{first.Created[0].ID + "/p1", first.Created[1].ID + "/p1", first.Created[1].ID + "/p1"}
+	if len(f.queue.newSchedule) != 3 || f.queue.newSchedule[2] != expected[2] {
+		t.Fatalf("Unexpected new schedule messages.\nGot: %v\nExpected: %v", f.queue.newSchedule, expected)
+	}
+}
+
+func TestEnroll_RetryAfterCompletion(t *testing.T) {
+	f := newEnrollmentFixture(t)
+	participant := models.Participant{ID: "p1", StudyID: "Study5"}
+
+	first, err := f.rescheduler.Enroll(context.Background(), &participant)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Completing mood creates its next attempt, announced by HandleCompletion.
+	completion, err := f.rescheduler.HandleCompletion(context.Background(), &models.QuestionnaireCompletedEvent{
+		UserID:               "p1",
+		QuestionnaireID:      "mood",
+		RemainingCompletions: 3,
+		Answers:              answers,
+	})
+	if err != nil || completion.NextSchedule == nil {
+		t.Fatalf("Unexpected completion.\nGot: %+v, %v", completion, err)
+	}
+	announced := len(f.queue.newSchedule)
+
+	second, err := f.rescheduler.Enroll(context.Background(), &participant)
+	if err != nil {
+		t.Fatalf("Unexpected error on retry: %v", err)
+	}
+	if len(second.Existing) != 1 || second.Existing[0].ID != first.Created[1].ID {
+		t.Fatalf("Unexpected existing schedules.\nGot: %+v\nExpected: %s", second.Existing, first.Created[1].ID)
+	}
+	expected := []string{first.Created[1].ID + "/p1"}
+	if got := f.queue.newSchedule[announced:]; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Unexpected new schedule messages on retry.\nGot: %v\nExpected: %v", got, expected)
+	}
+}
+
+func TestEnroll_Rejected(t *testing.T) {
+	tests := []struct {
+		name        string
+		seed        *models.Participant
+		closed      bool
+		ended       bool
+		participant *models.Participant
+		expected    error
+	}{
+		{name: "Missing ID", participant: &models.Participant{StudyID: "Study5"}, expected: ErrInvalidEnrollment},
+		{name: "Missing study", participant: &models.Participant{ID: "p1"}, expected: ErrInvalidEnrollment},
+		{name: "Invalid time zone", participant: &models.Participant{ID: "p1", StudyID: "Study5", TimeZone: "Nowhere"}, expected: store.ErrInvalid},
+		{
+			name:        "Enrolled in another study",
+			seed:        &models.Participant{ID: "p1", StudyID: "Study6", Status: models.ParticipantActive},
+			participant: &models.Participant{ID: "p1", StudyID: "Study5"},
+			expected:    store.ErrConflict,
+		},
+		{
+			name:        "Withdrawn",
+			seed:        &models.Participant{ID: "p1", StudyID: "Study5", Status: models.ParticipantWithdrawn},
+			participant: &models.Participant{ID: "p1", StudyID: "Study5"},
+			expected:    store.ErrConflict,
+		},
+		{name: "Study not in the store", participant: &models.Participant{ID: "p1", StudyID: "Study7"}, expected: store.ErrNotFound},
+		{name: "Closed study", closed: true, participant: &models.Participant{ID: "p1", StudyID: "Study5"}, expected: ErrStudyClosed},
+		{name: "Study past its end date", ended: true, participant: &models.Participant{ID: "p1", StudyID: "Study5"}, expected: ErrStudyClosed},
+		{
+			name:        "Participation ending before the enrollment",
+			participant: &models.Participant{ID: "p1", StudyID: "Study5", ParticipationEndsAt: timestamp.NewNullTimeStamp(timestamp.TimeStamp{Time: time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)})},
+			expected:    store.ErrInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newEnrollmentFixture(t)
+			if tt.seed != nil {
+				f.participants.Add(*tt.seed)
+			}
+			if tt.closed {
+				f.studies.Add(models.Study{ID: "Study5", Name: "Study 5", Status: models.StudyClosed, TimeZone: "UTC", HoursBetweenAttempts: 24})
+			}
+			if tt.ended {
+				endsAt := timestamp.NewNullTimeStamp(timestamp.TimeStamp{Time: f.now})
+				f.studies.Add(models.Study{ID: "Study5", Name: "Study 5", Status: models.StudyActive, EndsAt: endsAt, TimeZone: "UTC", HoursBetweenAttempts: 24})
+			}
+
+			result, err := f.rescheduler.Enroll(context.Background(), tt.participant)
+			if !errors.Is(err, tt.expected) {
+				t.Fatalf("Unexpected error.\nGot: %+v, %v\nExpected: %v", result, err, tt.expected)
+			}
+			if len(f.schedules.All()) != 0 || len(f.queue.newSchedule) != 0 {
+				t.Fatalf("Expected no schedules or messages.\nGot: %+v, %v", f.schedules.All(), f.queue.newSchedule)
+			}
+		})
+	}
+}
+
+func TestEnroll_StudyTimeZone(t *testing.T) {
+	tests := []struct {
+		name        string
+		participant *models.Participant
+		expected    string
+	}{
+		{name: "Time zone of the study", participant: &models.Participant{ID: "p1", StudyID: "Study5"}, expected: "Europe/Paris"},
+		{name: "Own time zone", participant: &models.Participant{ID: "p1", StudyID: "Study5", TimeZone: "Asia/Tokyo"}, expected: "Asia/Tokyo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newEnrollmentFixture(t)
+
+			result, err := f.rescheduler.Enroll(context.Background(), tt.participant)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Participant.TimeZone != tt.expected {
+				t.Fatalf("Unexpected time zone.\nGot: %s\nExpected: %s", result.Participant.TimeZone, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEnroll_KeepsEnrollmentTime(t *testing.T) {
+	f := newEnrollmentFixture(t)
+	enrolledAt := timestamp.TimeStamp{Time: f.now.Add(-time.Hour)}
+
+	result, err := f.rescheduler.Enroll(context.Background(), &models.Participant{ID: "p1", StudyID: "Study5", EnrolledAt: enrolledAt})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !result.Created[0].ScheduledAt.Equal(enrolledAt.Time) {
+		t.Fatalf("Unexpected initial schedule time.\nGot: %v\nExpected: %v", result.Created[0].ScheduledAt, enrolledAt)
+	}
+}
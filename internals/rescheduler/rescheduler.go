@@ -0,0 +1,493 @@
+// Package rescheduler contains the business logic that reacts to questionnaire completion events.
+//
+// A Rescheduler receives all of its collaborators (stores, the SQS handler, a clock and an ID generator)
+// through its constructor so that every entry point (Lambda, HTTP, CLI) shares the same logic and the
+// logic itself can be unit tested with in-memory fakes.
+package rescheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"rescheduler/internals/clock"
+	"rescheduler/internals/idgen"
+	"rescheduler/internals/models"
+	"rescheduler/internals/protocol"
+	"rescheduler/internals/questions"
+	"rescheduler/internals/sqs"
+	"rescheduler/internals/store"
+	"rescheduler/internals/timestamp"
+)
+
+// ErrInvalidEvent is returned when a completion event is missing the fields needed to process it.
+var ErrInvalidEvent = errors.New("invalid questionnaire completed event")
+
+// ErrStudyClosed is returned when a completion or an enrollment concerns a study that is closed.
+var ErrStudyClosed = errors.New("study is closed")
+
+// ErrParticipantInactive is returned when a completion comes from a participant who is paused, withdrew or completed the study.
+var ErrParticipantInactive = errors.New("participant is not active")
+
+// Stores groups the store interfaces the Rescheduler depends on.
+type Stores struct {
+	Questionnaires          store.QuestionnaireStoreInterface
+	ScheduledQuestionnaires store.ScheduledQuestionnaireStoreInterface
+	Participants            store.ParticipantStoreInterface
+	QuestionnaireResults    store.QuestionnaireResultStoreInterface
+	Protocols               store.StudyProtocolStoreInterface
+	Studies                 store.StudyStoreInterface
+}
+
+// Rescheduler processes questionnaire completion events.
+type Rescheduler struct {
+	stores Stores
+	queue  sqs.SQS
+	clock  clock.Clock
+	ids    idgen.Generator
+}
+
+// Result describes what HandleCompletion did for a single completion event.
+type Result struct {
+	// CompletedSchedule is the pending schedule that was marked as completed.
+	CompletedSchedule *models.ScheduledQuestionnaire
+	// QuestionnaireResult is the result record created for the completion.
+	QuestionnaireResult *models.QuestionnaireResult
+	// NextSchedule is the follow-up schedule, nil when the series is finished.
+	NextSchedule *models.ScheduledQuestionnaire
+	// SeriesCompleted reports that no further attempts remain and the completion message was sent.
+	SeriesCompleted bool
+	// Stopped reports that a rule of the questionnaire ended the series, SeriesCompleted is then set too.
+	Stopped bool
+	// Ended reports that the next attempt would have been due after the end of the study or of the participation
+	// of the participant, so the series was completed instead. SeriesCompleted is then set too.
+	Ended bool
+	// FollowUps are the schedules of other questionnaires created by the rules of the questionnaire
+	// and the protocol of its study.
+	FollowUps []models.ScheduledQuestionnaire
+	// LegacyDefinition is the error of questions.Parse for a definition of the questionnaire stored before definitions
+	// had a format. The answers were then accepted as they are, without scores or rules. It is nil otherwise.
+	LegacyDefinition error
+	// SkippedFollowUps explain the follow-ups left out because the questionnaire they schedule is the questionnaire
+	// itself, was deleted or belongs to another study. The rest of the completion is handled as usual.
+	SkippedFollowUps []error
+	// Duplicate reports that another delivery of the same completion was handled first, so nothing was recorded or sent.
+	// When that delivery had recorded the event, only QuestionnaireResult is set, to its result. When it completed
+	// the schedule concurrently, only CompletedSchedule is set, to the schedule as this delivery read it.
+	Duplicate bool
+}
+
+// New creates a Rescheduler.
+// A nil clock defaults to clock.System and a nil ID generator defaults to idgen.UUID.
+// A nil Protocols store defaults to one holding no protocol, so that no study links its questionnaires.
+// Every other store is required, and New returns an error naming the first one missing.
+func New(stores Stores, queue sqs.SQS, c clock.Clock, ids idgen.Generator) (*Rescheduler, error) {
+	required := []struct {
+		name    string
+		missing bool
+	}{
+		{"Questionnaires", stores.Questionnaires == nil},
+		{"ScheduledQuestionnaires", stores.ScheduledQuestionnaires == nil},
+		{"Participants", stores.Participants == nil},
+		{"QuestionnaireResults", stores.QuestionnaireResults == nil},
+		{"Studies", stores.Studies == nil},
+	}
+	for _, s := range required {
+		if s.missing {
+			return nil, fmt.Errorf("creating rescheduler: the %s store is required", s.name)
+		}
+	}
+	if stores.Protocols == nil {
+		stores.Protocols = noProtocols{}
+	}
+	if c == nil {
+		c = clock.System{}
+	}
+	if ids == nil {
+		ids = idgen.UUID{}
+	}
+	return &Rescheduler{
+		stores: stores,
+		queue:  queue,
+		clock:  c,
+		ids:    ids,
+	}, nil
+}
+
+// noProtocols is the Protocols store of a Rescheduler created without one: no study has a protocol.
+type noProtocols struct{}
+
+func (noProtocols) FindByStudyID(studyID string) (*models.StudyProtocol, error) {
+	return nil, fmt.Errorf("study protocol %w with Study ID: %s", store.ErrNotFound, studyID)
+}
+
+func (noProtocols) Save(studyProtocol *models.StudyProtocol) error {
+	return fmt.Errorf("saving study protocol: the rescheduler has no Protocols store")
+}
+
+// HandleCompletion processes a questionnaire completion event.
+//
+// It looks up the questionnaire and the pending schedule for the participant, marks the schedule as completed
+// and records the questionnaire result with the answers of the event, stored verbatim, and the scores the
+// definition of the questionnaire declares. If there are remaining completions, or the questionnaire has no
+// maximum number of attempts, a new schedule is created HoursBetweenAttempts after the completion and a new
+// schedule message is sent. Otherwise the completion message is sent, with the scores of the result.
+//
+// The rules of the questionnaire, evaluated against the answers and scores, adapt this: they can schedule the
+// next attempt after another interval, stop the series early as if no attempts remained, and schedule other
+// questionnaires of the study, each with its own new schedule message. A follow-up scheduling the questionnaire
+// itself, a deleted questionnaire or one of another study is left out, and Result.SkippedFollowUps reports it.
+//
+// Nothing is scheduled after the end of the study, EndsAt, or after the end of the participation of the participant,
+// see participationEnd. When the next attempt would be due after it, the series is completed instead: the completion
+// message is sent, and the links of the protocol waiting for the end of the series are followed. Follow-ups due
+// after it are left out.
+//
+// The answers are validated, scored and evaluated against the version of the questionnaire the schedule was issued
+// against, which the result records. The schedules created are always issued against the current version.
+// A definition stored before definitions had a format, which questions.Parse rejects, is handled as an empty one
+// accepting any answers, and Result.LegacyDefinition reports it.
+//
+// When the event carries a study ID, only a questionnaire of that study is found: a questionnaire of another study
+// is not found, as if it did not exist. A completion of a questionnaire of a closed study is rejected with ErrStudyClosed
+// before anything is written.
+//
+// A completion from a participant who is paused, withdrew or completed the study is rejected with ErrParticipantInactive
+// before anything is written: the schedule stays pending, so a paused participant completes it once resumed.
+// When the participation has an end and a completion completes a series, however it does, the participant is marked
+// completed once they have no pending schedule left, in the same write as the completion.
+//
+// When the event carries no completion time the current time of the clock is used.
+// An event whose answers are missing, not a JSON object or larger than store.MaxAnswersSize, or do not match
+// the definition of the questionnaire, is rejected with ErrInvalidEvent before anything is written.
+// A mismatch wraps a *questions.AnswersError with the problem of every question.
+//
+// Completing the schedule, creating the result and creating the schedules that follow it are one write,
+// store.ScheduledQuestionnaireStoreInterface.Complete: when any of them fails none is made, the schedule stays pending
+// and a retry of the event completes it. The messages are only sent once the records are written.
+//
+// Completions are deduplicated by the ID of the event, which the result records. When a result already records it,
+// an earlier delivery handled the event: HandleCompletion returns a Result with Duplicate set and no error, so the
+// redelivery is acknowledged without completing the next attempt that delivery created. The schedule is also updated
+// with optimistic concurrency control. If it was changed between being read and being updated, another delivery of
+// the same completion got there first and the event is a duplicate too. An event without an ID is only recognised
+// in that race: redelivered after the first delivery, it completes the next attempt.
+//
+// Parameters:
+//   - ctx: A context.Context object.
+//   - event: A pointer to the models.QuestionnaireCompletedEvent containing the event data.
+//
+// Returns:
+//   - A Result describing the records created and the messages sent.
+//   - An error if the event is invalid or any store or queue operation fails.
+func (r *Rescheduler) HandleCompletion(ctx context.Context, event *models.QuestionnaireCompletedEvent) (*Result, error) {
+	if event == nil || event.QuestionnaireID == "" || event.UserID == "" {
+		return nil, ErrInvalidEvent
+	}
+	// The answers are checked before anything is written, an event the result store would reject must not complete its schedule.
+	if err := store.ValidateAnswers(event.Answers); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidEvent, err)
+	}
+	// A redelivered event must not complete the next attempt its first delivery created, which is now the pending one.
+	if event.ID != "" {
+		earlier, err := r.stores.QuestionnaireResults.FindByEventID(event.ID)
+		if err == nil {
+			return &Result{QuestionnaireResult: earlier, Duplicate: true}, nil
+		} else if !errors.Is(err, store.ErrNotFound) {
+			return nil, fmt.Errorf("finding questionnaire result: %w", err)
+		}
+	}
+
+	completedAt := event.CompletedAt
+	if completedAt.IsZero() {
+		completedAt = timestamp.TimeStamp{Time: r.clock.Now()}
+	}
+
+	questionnaire, err := r.findQuestionnaire(event)
+	if err != nil {
+		return nil, fmt.Errorf("finding questionnaire: %w", err)
+	}
+	study, err := r.openStudy(questionnaire.StudyID)
+	if err != nil {
+		return nil, err
+	}
+	participant, err := r.stores.Participants.FindParticipantByID(event.UserID)
+	if errors.Is(err, store.ErrNotFound) {
+		participant = nil
+	} else if err != nil {
+		return nil, fmt.Errorf("finding participant: %w", err)
+	}
+	if participant != nil && participant.Status != models.ParticipantActive {
+		return nil, fmt.Errorf("%w: participant %s is %s", ErrParticipantInactive, participant.ID, participant.Status)
+	}
+
+	schedule, err := r.stores.ScheduledQuestionnaires.FindScheduledQuestionnaireByQuestionnaireIDAndUserID(
+		event.QuestionnaireID, event.UserID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("finding schedule: %w", err)
+	}
+
+	// The answers were given to the version of the questionnaire the schedule was issued against, which
+	// may be older than the current one when the questionnaire was edited while the schedule was pending.
+	answered := questionnaire.Questions
+	if schedule.QuestionnaireVersion != questionnaire.Version {
+		version, err := r.stores.Questionnaires.FindVersion(questionnaire.ID, schedule.QuestionnaireVersion)
+		if err != nil {
+			return nil, fmt.Errorf("finding questionnaire version: %w", err)
+		}
+		answered = version.Questions
+	}
+	// The stores only write definitions questions.Parse accepts, so one it rejects was stored before definitions
+	// had a format. It accepts any answers, as questionnaires did then, and is reported in the result.
+	var legacyDefinition error
+	definition, err := questions.Parse(answered)
+	if err != nil {
+		legacyDefinition = fmt.Errorf("questionnaire %s version %d: %w", questionnaire.ID, schedule.QuestionnaireVersion, err)
+		definition = &questions.Definition{}
+	}
+	if err := definition.ValidateAnswers(event.Answers); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidEvent, err)
+	}
+	scores, err := definition.Score(event.Answers)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidEvent, err)
+	}
+	outcome, err := definition.Evaluate(event.Answers, scores)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidEvent, err)
+	}
+	// The next attempt is due as soon as a rule says or after HoursBetweenAttempts
+	hours := questionnaire.HoursBetweenAttempts
+	if outcome.RescheduleInHours > 0 {
+		hours = outcome.RescheduleInHours
+	}
+	end, ends := participationEnd(study, participant)
+	ended := ends && completedAt.Add(time.Duration(hours)*time.Hour).After(end)
+
+	// The series ends when a rule stops it, there are no remaining completions and max_attempts in the database
+	// is not NULL, or the next attempt would be due after the end of the participation
+	seriesCompleted := outcome.Stop || event.RemainingCompletions <= 0 && questionnaire.MaxAttempts.Valid || ended
+	// Once the participation has an end, every series the participant completes may be their last: a series that
+	// ended early, by a rule or its maximum attempts, is no different from one cut short by the end. The store only
+	// marks the participant completed when no schedule of theirs is pending any more.
+	endsParticipation := ends && seriesCompleted
+
+	studyProtocol, err := r.studyProtocol(questionnaire.StudyID)
+	if err != nil {
+		return nil, err
+	}
+	var next []questions.FollowUp
+	for _, followUp := range mergeFollowUps(outcome.FollowUps, studyProtocol.Next(questionnaire.ID, seriesCompleted)) {
+		if !ends || !completedAt.Add(time.Duration(followUp.InHours)*time.Hour).After(end) {
+			next = append(next, followUp)
+		}
+	}
+
+	// The questionnaires the rules and the protocol schedule are looked up before anything is written. The stores
+	// reject links to the questionnaire itself, to a missing one or to one of another study, but the questionnaire
+	// linked to may have been deleted since: such a follow-up is left out rather than failing every completion.
+	followUps := make(map[string]*models.Questionnaire, len(next))
+	var skipped []error
+	for _, followUp := range next {
+		if followUp.QuestionnaireID == questionnaire.ID {
+			skipped = append(skipped, fmt.Errorf("questionnaire %s schedules itself, its rules should reschedule it instead", questionnaire.ID))
+			continue
+		}
+		other, err := r.stores.Questionnaires.FindQuestionnaireByID(followUp.QuestionnaireID)
+		if errors.Is(err, store.ErrNotFound) {
+			skipped = append(skipped, fmt.Errorf("questionnaire %s schedules questionnaire %s: %w", questionnaire.ID, followUp.QuestionnaireID, err))
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("finding follow-up questionnaire: %w", err)
+		}
+		if other.StudyID != questionnaire.StudyID {
+			skipped = append(skipped, fmt.Errorf("questionnaire %s schedules questionnaire %s of another study", questionnaire.ID, other.ID))
+			continue
+		}
+		followUps[other.ID] = other
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// The schedule is completed together with the result and the schedules that follow it, so that a failure
+	// leaves the schedule pending for a retry rather than completed without its answers or its next attempt.
+	result := &Result{CompletedSchedule: schedule, LegacyDefinition: legacyDefinition, SkippedFollowUps: skipped}
+	schedule.Status = models.ScheduledQuestionnaireCompleted
+	completion := &store.Completion{
+		Schedule: schedule,
+		Result: &models.QuestionnaireResult{
+			ID:                      r.ids.NewID(),
+			Answers:                 event.Answers,
+			QuestionnaireID:         questionnaire.ID,
+			QuestionnaireVersion:    schedule.QuestionnaireVersion,
+			ParticipantID:           event.UserID,
+			QuestionnaireScheduleID: schedule.ID,
+			CompletedAt:             completedAt,
+			EventID:                 event.ID,
+			Scores:                  scores,
+		},
+		FollowUps:        r.followUpSchedules(event.UserID, completedAt, next, followUps),
+		EndParticipation: endsParticipation,
+	}
+	if !seriesCompleted {
+		// Create a new schedule for the same questionnaire
+		completion.Next = &models.ScheduledQuestionnaire{
+			ID:                   r.ids.NewID(),
+			QuestionnaireID:      questionnaire.ID,
+			QuestionnaireVersion: questionnaire.Version,
+			ParticipantID:        event.UserID,
+			ScheduledAt: timestamp.TimeStamp{
+				Time: completedAt.Add(time.Duration(hours) * time.Hour),
+			},
+			Status: models.ScheduledQuestionnairePending,
+		}
+	}
+
+	err = r.stores.ScheduledQuestionnaires.Complete(completion)
+	if errors.Is(err, store.ErrConflict) {
+		return &Result{CompletedSchedule: schedule, Duplicate: true}, nil
+	}
+	if errors.Is(err, store.ErrDuplicate) && event.ID != "" {
+		// A concurrent delivery of the event may have completed the next attempt, which this one read as pending.
+		if earlier, findErr := r.stores.QuestionnaireResults.FindByEventID(event.ID); findErr == nil {
+			return &Result{QuestionnaireResult: earlier, Duplicate: true}, nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("completing schedule: %w", err)
+	}
+	result.QuestionnaireResult = completion.Result
+	result.FollowUps = completion.FollowUps
+
+	// The messages are sent once the records they announce are committed
+	for _, followUp := range result.FollowUps {
+		if err := r.queue.SendNewScheduleMessage(followUp.ID, event.UserID); err != nil {
+			return nil, fmt.Errorf("sending new schedule message: %w", err)
+		}
+	}
+
+	if seriesCompleted {
+		if err := r.queue.SendCompletionMessage(event.UserID, scores); err != nil {
+			return nil, fmt.Errorf("sending completion message: %w", err)
+		}
+		result.SeriesCompleted = true
+		result.Stopped = outcome.Stop
+		result.Ended = ended
+		return result, nil
+	}
+
+	result.NextSchedule = completion.Next
+	if err := r.queue.SendNewScheduleMessage(completion.Next.ID, event.UserID); err != nil {
+		return nil, fmt.Errorf("sending new schedule message: %w", err)
+	}
+
+	return result, nil
+}
+
+// followUpSchedules returns a pending schedule for each questionnaire a rule or the protocol schedules, InHours after
+// the completion and against the current version of the questionnaire, found in questionnaires. Those not found
+// there were skipped and are left out. The store leaves out those whose questionnaire the participant already has
+// a pending schedule for, so a rule that matches again does not pile up schedules.
+func (r *Rescheduler) followUpSchedules(participantID string, completedAt timestamp.TimeStamp, followUps []questions.FollowUp, questionnaires map[string]*models.Questionnaire) []models.ScheduledQuestionnaire {
+	schedules := make([]models.ScheduledQuestionnaire, 0, len(followUps))
+	for _, followUp := range followUps {
+		questionnaire, ok := questionnaires[followUp.QuestionnaireID]
+		if !ok {
+			continue
+		}
+		schedules = append(schedules, models.ScheduledQuestionnaire{
+			ID:                   r.ids.NewID(),
+			QuestionnaireID:      followUp.QuestionnaireID,
+			QuestionnaireVersion: questionnaire.Version,
+			ParticipantID:        participantID,
+			ScheduledAt:          timestamp.TimeStamp{Time: completedAt.Add(time.Duration(followUp.InHours) * time.Hour)},
+			Status:               models.ScheduledQuestionnairePending,
+		})
+	}
+	return schedules
+}
+
+// participationEnd returns the time after which nothing is scheduled for a participant: the earliest of the end
+// of the study and the end of the participation of the participant. The participation ends at ParticipationEndsAt
+// or, without it, ParticipationDays of the study after the enrollment. The participant may be nil,
+// when not in the participant store. ok is false when neither ends.
+func participationEnd(study *models.Study, participant *models.Participant) (end time.Time, ok bool) {
+	earliest := func(t time.Time) {
+		if !ok || t.Before(end) {
+			end, ok = t, true
+		}
+	}
+
+	if study.EndsAt.Valid {
+		earliest(study.EndsAt.TimeStamp.Time)
+	}
+	if participant == nil {
+		return end, ok
+	}
+	if participant.ParticipationEndsAt.Valid {
+		earliest(participant.ParticipationEndsAt.TimeStamp.Time)
+	} else if study.ParticipationDays.Valid && !participant.EnrolledAt.IsZero() {
+		earliest(participant.EnrolledAt.AddDate(0, 0, int(study.ParticipationDays.Int64)))
+	}
+	return end, ok
+}
+
+// findQuestionnaire finds the questionnaire of a completion event, within the study of the event when it has one.
+func (r *Rescheduler) findQuestionnaire(event *models.QuestionnaireCompletedEvent) (*models.Questionnaire, error) {
+	if event.StudyID == "" {
+		return r.stores.Questionnaires.FindQuestionnaireByID(event.QuestionnaireID)
+	}
+	return r.stores.Questionnaires.FindQuestionnaireByIDAndStudyID(event.QuestionnaireID, event.StudyID)
+}
+
+// openStudy returns a study, or an error wrapping ErrStudyClosed when it is closed.
+// A study missing from the study store is an error wrapping store.ErrNotFound: every questionnaire
+// and participant references a study, so a missing one is never treated as open.
+func (r *Rescheduler) openStudy(studyID string) (*models.Study, error) {
+	study, err := r.stores.Studies.FindStudyByID(studyID)
+	if err != nil {
+		return nil, fmt.Errorf("finding study: %w", err)
+	}
+
+	if study.Status == models.StudyClosed {
+		return nil, fmt.Errorf("%w: study %s", ErrStudyClosed, studyID)
+	}
+	return study, nil
+}
+
+// studyProtocol returns the parsed protocol of a study, an empty protocol linking nothing when the study has none.
+func (r *Rescheduler) studyProtocol(studyID string) (*protocol.Protocol, error) {
+	studyProtocol, err := r.stores.Protocols.FindByStudyID(studyID)
+	if errors.Is(err, store.ErrNotFound) {
+		return &protocol.Protocol{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("finding study protocol: %w", err)
+	}
+
+	parsed, err := protocol.Parse(studyProtocol.Definition)
+	if err != nil {
+		return nil, fmt.Errorf("study %s: %w", studyID, err)
+	}
+	return parsed, nil
+}
+
+// mergeFollowUps returns the follow-ups of the rules followed by those of the protocol, each questionnaire once.
+// A questionnaire both schedule is scheduled when the rule says.
+func mergeFollowUps(rules, linked []questions.FollowUp) []questions.FollowUp {
+	merged := append([]questions.FollowUp(nil), rules...)
+	scheduled := make(map[string]bool, len(rules))
+	for _, followUp := range rules {
+		scheduled[followUp.QuestionnaireID] = true
+	}
+	for _, followUp := range linked {
+		if !scheduled[followUp.QuestionnaireID] {
+			scheduled[followUp.QuestionnaireID] = true
+			merged = append(merged, followUp)
+		}
+	}
+	return merged
+}
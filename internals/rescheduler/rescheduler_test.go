@@ -0,0 +1,1166 @@
+// File: ./internals/rescheduler/rescheduler_test.go
+
+package rescheduler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"rescheduler/internals/clock"
+	"rescheduler/internals/database"
+	"rescheduler/internals/dialect"
+	"rescheduler/internals/idgen"
+	"rescheduler/internals/migrate"
+	"rescheduler/internals/models"
+	"rescheduler/internals/questions"
+	"rescheduler/internals/store"
+	"rescheduler/internals/storetest/memstore"
+	"rescheduler/internals/timestamp"
+)
+
+// answers are the answers of the completion events of the tests.
+var answers = json.RawMessage(`{"mood": 4, "notes": "tired"}`)
+
+// recordingQueue is an sqs.SQS fake that records every message sent.
+type recordingQueue struct {
+	mu          sync.Mutex
+	newSchedule []string
+	completion  []string
+	scores      []map[string]float64
+	err         error
+}
+
+func (q *recordingQueue) SendNewScheduleMessage(scheduleID string, participantID string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.newSchedule = append(q.newSchedule, scheduleID+"/"+participantID)
+	return q.err
+}
+
+func (q *recordingQueue) SendCompletionMessage(participantID string, scores map[string]float64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.completion = append(q.completion, participantID)
+	q.scores = append(q.scores, scores)
+	return q.err
+}
+
+type fixture struct {
+	rescheduler    *Rescheduler
+	questionnaires *memstore.QuestionnaireStore
+	schedules      *memstore.ScheduledQuestionnaireStore
+	results        *memstore.QuestionnaireResultStore
+	protocols      *memstore.StudyProtocolStore
+	studies        *memstore.StudyStore
+	participants   *memstore.ParticipantStore
+	queue          *recordingQueue
+	clock          *clock.Fake
+}
+
+func newFixture(t *testing.T, questionnaire models.Questionnaire) *fixture {
+	t.Helper()
+
+	questionnaires := memstore.NewQuestionnaireStore(questionnaire)
+	results := memstore.NewQuestionnaireResultStore()
+	schedules := memstore.NewScheduledQuestionnaireStore(questionnaires).WithResults(results)
+	protocols := memstore.NewStudyProtocolStore()
+	studies := memstore.NewStudyStore(models.Study{ID: "Study5", Name: "Study 5", Status: models.StudyActive, TimeZone: "UTC", HoursBetweenAttempts: 24})
+	participants := memstore.NewParticipantStore().WithSchedules(schedules)
+	schedules.WithParticipants(participants)
+	queue := &recordingQueue{}
+	fakeClock := clock.NewFake(time.Date(2023, 12, 5, 0, 0, 0, 0, time.UTC))
+
+	pending := models.ScheduledQuestionnaire{
+		ID:              "schedule-1",
+		QuestionnaireID: questionnaire.ID,
+		ParticipantID:   "p1",
+		ScheduledAt:     timestamp.TimeStamp{Time: time.Date(2023, 12, 3, 2, 0, 0, 0, time.UTC)},
+		Status:          models.ScheduledQuestionnairePending,
+	}
+	if err := schedules.Create(&pending); err != nil {
+		t.Fatalf("Error seeding schedule: %v", err)
+	}
+
+	r, err := New(Stores{
+		Questionnaires:          questionnaires,
+		ScheduledQuestionnaires: schedules,
+		Participants:            participants,
+		QuestionnaireResults:    results,
+		Protocols:               protocols,
+		Studies:                 studies,
+	}, queue, fakeClock, idgen.NewSequence("id"))
+	if err != nil {
+		t.Fatalf("Error creating rescheduler: %v", err)
+	}
+
+	return &fixture{
+		rescheduler:    r,
+		questionnaires: questionnaires,
+		schedules:      schedules,
+		results:        results,
+		protocols:      protocols,
+		studies:        studies,
+		participants:   participants,
+		queue:          queue,
+		clock:          fakeClock,
+	}
+}
+
+func TestNew_Stores(t *testing.T) {
+	stores := func(change func(stores *Stores)) Stores {
+		questionnaires := memstore.NewQuestionnaireStore()
+		stores := Stores{
+			Questionnaires:          questionnaires,
+			ScheduledQuestionnaires: memstore.NewScheduledQuestionnaireStore(questionnaires),
+			Participants:            memstore.NewParticipantStore(),
+			QuestionnaireResults:    memstore.NewQuestionnaireResultStore(),
+			Protocols:               memstore.NewStudyProtocolStore(),
+			Studies:                 memstore.NewStudyStore(),
+		}
+		change(&stores)
+		return stores
+	}
+
+	tests := []struct {
+		name    string
+		stores  Stores
+		wantErr bool
+	}{
+		{name: "Every store", stores: stores(func(stores *Stores) {})},
+		{name: "No protocol store", stores: stores(func(stores *Stores) { stores.Protocols = nil })},
+		{name: "No study store", stores: stores(func(stores *Stores) { stores.Studies = nil }), wantErr: true},
+		{name: "No result store", stores: stores(func(stores *Stores) { stores.QuestionnaireResults = nil }), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := New(tt.stores, &recordingQueue{}, nil, nil)
+			if tt.wantErr != (err != nil) || tt.wantErr != (r == nil) {
+				t.Fatalf("Unexpected result.\nGot: %v, %v\nExpected an error: %v", r, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestHandleCompletion_WithoutProtocols checks that a Rescheduler created without a protocol store
+// handles completions as if no study had a protocol.
+func TestHandleCompletion_WithoutProtocols(t *testing.T) {
+	f := newFixture(t, models.Questionnaire{ID: "q1", StudyID: "Study5", HoursBetweenAttempts: 24})
+	r, err := New(Stores{
+		Questionnaires:          f.questionnaires,
+		ScheduledQuestionnaires: f.schedules,
+		Participants:            f.participants,
+		QuestionnaireResults:    f.results,
+		Studies:                 f.studies,
+	}, f.queue, f.clock, idgen.NewSequence("id"))
+	if err != nil {
+		t.Fatalf("Error creating rescheduler: %v", err)
+	}
+
+	result, err := r.HandleCompletion(context.Background(), &models.QuestionnaireCompletedEvent{
+		UserID:          "p1",
+		QuestionnaireID: "q1",
+		CompletedAt:     timestamp.TimeStamp{Time: time.Date(2023, 12, 4, 2, 0, 0, 0, time.UTC)},
+		Answers:         answers,
+	})
+	if err != nil || result.NextSchedule == nil || len(result.FollowUps) != 0 {
+		t.Fatalf("Expected the next attempt and no follow-ups.\nGot: %+v, %v", result, err)
+	}
+}
+
+func TestHandleCompletion(t *testing.T) {
+	completedAt := timestamp.TimeStamp{Time: time.Date(2023, 12, 4, 2, 11, 0, 0, time.UTC)}
+
+	tests := []struct {
+		name          string
+		maxAttempts   sql.NullInt64
+		remaining     int
+		completedAt   timestamp.TimeStamp
+		wantNext      *timestamp.TimeStamp
+		wantCompleted bool
+	}{
+		{
+			name:        "Remaining completions schedule the next attempt",
+			maxAttempts: sql.NullInt64{Int64: 3, Valid: true},
+			remaining:   2,
+			completedAt: completedAt,
+			wantNext:    &timestamp.TimeStamp{Time: completedAt.Add(24 * time.Hour)},
+		},
+		{
+			name:        "Unlimited attempts schedule the next attempt",
+			remaining:   0,
+			completedAt: completedAt,
+			wantNext:    &timestamp.TimeStamp{Time: completedAt.Add(24 * time.Hour)},
+		},
+		{
+			name:          "Last attempt sends the completion message",
+			maxAttempts:   sql.NullInt64{Int64: 3, Valid: true},
+			remaining:     0,
+			completedAt:   completedAt,
+			wantCompleted: true,
+		},
+		{
+			name:      "Missing completion time falls back to the clock",
+			remaining: 1,
+			wantNext:  &timestamp.TimeStamp{Time: time.Date(2023, 12, 6, 0, 0, 0, 0, time.UTC)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, models.Questionnaire{ID: "q1", StudyID: "Study5", MaxAttempts: tt.maxAttempts, HoursBetweenAttempts: 24})
+
+			result, err := f.rescheduler.HandleCompletion(context.Background(), &models.QuestionnaireCompletedEvent{
+				ID:                   "event-1",
+				UserID:               "p1",
+				StudyID:              "Study5",
+				QuestionnaireID:      "q1",
+				CompletedAt:          tt.completedAt,
+				RemainingCompletions: tt.remaining,
+				Answers:              answers,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if result.CompletedSchedule.ID != "schedule-1" || result.CompletedSchedule.Status != models.ScheduledQuestionnaireCompleted {
+				t.Fatalf("Unexpected completed schedule: %+v", result.CompletedSchedule)
+			}
+			if got := f.results.All(); len(got) != 1 || got[0].QuestionnaireScheduleID != "schedule-1" || string(got[0].Answers) != string(answers) {
+				t.Fatalf("Unexpected questionnaire results: %+v", got)
+			}
+			if result.SeriesCompleted != tt.wantCompleted {
+				t.Fatalf("Unexpected SeriesCompleted.\nGot: %v\nExpected: %v", result.SeriesCompleted, tt.wantCompleted)
+			}
+
+			if tt.wantNext == nil {
+				if result.NextSchedule != nil {
+					t.Fatalf("Expected no next schedule, got %+v", result.NextSchedule)
+				}
+				if !reflect.DeepEqual(f.queue.completion, []string{"p1"}) || len(f.queue.newSchedule) != 0 {
+					t.Fatalf("Unexpected messages: new schedule %v, completion %v", f.queue.newSchedule, f.queue.completion)
+				}
+				return
+			}
+
+			if !result.NextSchedule.ScheduledAt.Equal(tt.wantNext.Time) {
+				t.Fatalf("Unexpected next schedule time.\nGot: %v\nExpected: %v", result.NextSchedule.ScheduledAt, tt.wantNext)
+			}
+			pending, err := f.schedules.FindScheduledQuestionnaireByQuestionnaireIDAndUserID("q1", "p1")
+			if err != nil || pending.ID != result.NextSchedule.ID {
+				t.Fatalf("Expected the next schedule to be pending.\nGot: %+v, %v", pending, err)
+			}
+			if !reflect.DeepEqual(f.queue.newSchedule, []string{result.NextSchedule.ID + "/p1"}) || len(f.queue.completion) != 0 {
+				t.Fatalf("Unexpected messages: new schedule %v, completion %v", f.queue.newSchedule, f.queue.completion)
+			}
+		})
+	}
+}
+
+func TestHandleCompletion_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		event   *models.QuestionnaireCompletedEvent
+		invalid bool
+	}{
+		{name: "Nil event", event: nil, invalid: true},
+		{name: "Missing questionnaire", event: &models.QuestionnaireCompletedEvent{UserID: "p1", Answers: answers}, invalid: true},
+		{name: "Unknown questionnaire", event: &models.QuestionnaireCompletedEvent{UserID: "p1", QuestionnaireID: "q2", Answers: answers}},
+		{name: "No pending schedule", event: &models.QuestionnaireCompletedEvent{UserID: "p2", QuestionnaireID: "q1", Answers: answers}},
+		{name: "Missing answers", event: &models.QuestionnaireCompletedEvent{UserID: "p1", QuestionnaireID: "q1"}, invalid: true},
+		{name: "Answers not JSON", event: &models.QuestionnaireCompletedEvent{UserID: "p1", QuestionnaireID: "q1", Answers: json.RawMessage(`mood=4`)}, invalid: true},
+		{name: "Answers too large", event: &models.QuestionnaireCompletedEvent{UserID: "p1", QuestionnaireID: "q1", Answers: json.RawMessage(`{"notes": "` + strings.Repeat("a", store.MaxAnswersSize) + `"}`)}, invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, models.Questionnaire{ID: "q1", StudyID: "Study5", HoursBetweenAttempts: 24})
+
+			_, err := f.rescheduler.HandleCompletion(context.Background(), tt.event)
+			if err == nil {
+				t.Fatalf("Expected an error")
+			}
+			if tt.invalid != errors.Is(err, ErrInvalidEvent) {
+				t.Fatalf("Unexpected error.\nGot: %v\nExpected ErrInvalidEvent: %v", err, tt.invalid)
+			}
+			if schedule, err := f.schedules.FindScheduledQuestionnaireByQuestionnaireIDAndUserID("q1", "p1"); err != nil || schedule.Status != models.ScheduledQuestionnairePending {
+				t.Fatalf("Expected the schedule to stay pending.\nGot: %+v, %v", schedule, err)
+			}
+			if len(f.results.All()) != 0 || len(f.queue.newSchedule)+len(f.queue.completion) != 0 {
+				t.Fatalf("Expected no side effects for a rejected event")
+			}
+		})
+	}
+}
+
+func TestHandleCompletion_AnswersMismatch(t *testing.T) {
+	f := newFixture(t, models.Questionnaire{
+		ID:                   "q1",
+		StudyID:              "Study5",
+		Questions:            `{"questions": [{"id": "mood", "type": "numeric", "required": true, "min": 0, "max": 3}]}`,
+		HoursBetweenAttempts: 24,
+	})
+
+	_, err := f.rescheduler.HandleCompletion(context.Background(), &models.QuestionnaireCompletedEvent{UserID: "p1", QuestionnaireID: "q1", Answers: answers})
+	var answersErr *questions.AnswersError
+	if !errors.Is(err, ErrInvalidEvent) || !errors.As(err, &answersErr) {
+		t.Fatalf("Expected an invalid event with answer errors.\nGot: %v", err)
+	}
+	expected := []questions.AnswerError{
+		{QuestionID: "mood", Message: "4 is more than the maximum of 3"},
+		{QuestionID: "notes", Message: "not a question of the questionnaire"},
+	}
+	if !reflect.DeepEqual(answersErr.Errors, expected) {
+		t.Fatalf("Unexpected answer errors.\nGot: %+v\nExpected: %+v", answersErr.Errors, expected)
+	}
+	if len(f.results.All()) != 0 || len(f.queue.newSchedule)+len(f.queue.completion) != 0 {
+		t.Fatalf("Expected no side effects for rejected answers")
+	}
+
+	if _, err := f.rescheduler.HandleCompletion(context.Background(), &models.QuestionnaireCompletedEvent{UserID: "p1", QuestionnaireID: "q1", Answers: json.RawMessage(`{"mood": 3}`)}); err != nil {
+		t.Fatalf("Unexpected error for matching answers: %v", err)
+	}
+}
+
+func TestHandleCompletion_LegacyDefinition(t *testing.T) {
+	tests := []struct {
+		name       string
+		questions  string
+		wantLegacy bool
+	}{
+		{name: "Definition", questions: `{"questions": [{"id": "mood", "type": "numeric"}, {"id": "notes", "type": "text"}]}`},
+		{name: "Empty definition", questions: `{}`},
+		{name: "Array of questions", questions: `["How do you feel?", "Anything else?"]`, wantLegacy: true},
+		{name: "Object of other fields", questions: `{"mood": "How do you feel?"}`, wantLegacy: true},
+		{name: "Not JSON", questions: `How do you feel?`, wantLegacy: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, models.Questionnaire{ID: "q1", StudyID: "Study5", Questions: tt.questions, HoursBetweenAttempts: 24})
+
+			result, err := f.rescheduler.HandleCompletion(context.Background(), &models.QuestionnaireCompletedEvent{
+				UserID:          "p1",
+				QuestionnaireID: "q1",
+				CompletedAt:     timestamp.TimeStamp{Time: time.Date(2023, 12, 4, 2, 0, 0, 0, time.UTC)},
+				Answers:         answers,
+			})
+			if err != nil || result.NextSchedule == nil || len(f.results.All()) != 1 {
+				t.Fatalf("Expected the answers to be accepted.\nGot: %+v, %v", result, err)
+			}
+			if (result.LegacyDefinition != nil) != tt.wantLegacy || tt.wantLegacy && !errors.Is(result.LegacyDefinition, questions.ErrInvalidDefinition) {
+				t.Fatalf("Unexpected legacy definition.\nGot: %v\nExpected: %v", result.LegacyDefinition, tt.wantLegacy)
+			}
+		})
+	}
+}
+
+func TestHandleCompletion_Scores(t *testing.T) {
+	f := newFixture(t, models.Questionnaire{
+		ID:      "q1",
+		StudyID: "Study5",
+		Questions: `{
+			"questions": [
+				{"id": "interest", "type": "single_choice", "options": [{"value": "never", "score": 0}, {"value": "often", "score": 2}]},
+				{"id": "mood", "type": "numeric", "min": 0, "max": 10},
+				{"id": "notes", "type": "text"}
+			],
+			"scores": [
+				{"id": "total", "questions": ["interest", "mood"]},
+				{"id": "mean", "questions": ["interest", "mood"], "method": "mean"}
+			]
+		}`,
+		MaxAttempts:          sql.NullInt64{Int64: 1, Valid: true},
+		HoursBetweenAttempts: 24,
+	})
+
+	result, err := f.rescheduler.HandleCompletion(context.Background(), &models.QuestionnaireCompletedEvent{
+		UserID:          "p1",
+		QuestionnaireID: "q1",
+		Answers:         json.RawMessage(`{"interest": "often", "mood": 7, "notes": "tired"}`),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := map[string]float64{"total": 9, "mean": 4.5}
+	if !reflect.DeepEqual(result.QuestionnaireResult.Scores, expected) {
+		t.Fatalf("Unexpected scores.\nGot: %v\nExpected: %v", result.QuestionnaireResult.Scores, expected)
+	}
+	if got := f.results.All(); len(got) != 1 || !reflect.DeepEqual(got[0].Scores, expected) {
+		t.Fatalf("Unexpected stored result: %+v", got)
+	}
+	if !reflect.DeepEqual(f.queue.scores, []map[string]float64{expected}) {
+		t.Fatalf("Unexpected completion message scores.\nGot: %v\nExpected: %v", f.queue.scores, expected)
+	}
+}
+
+func TestHandleCompletion_Rules(t *testing.T) {
+	completedAt := timestamp.TimeStamp{Time: time.Date(2023, 12, 4, 2, 0, 0, 0, time.UTC)}
+	definition := `{
+		"questions": [
+			{"id": "mood", "type": "numeric", "min": 0, "max": 27},
+			{"id": "harm", "type": "single_choice", "options": [{"value": "yes"}, {"value": "no"}]}
+		],
+		"scores": [{"id": "total", "questions": ["mood"]}],
+		"rules": [
+			{"when": {"score": "total", "op": ">=", "value": 15}, "then": {"reschedule_in_hours": 24}},
+			{"when": {"answer": "harm", "op": "=", "value": "yes"}, "then": {"schedule": "%s", "schedule_in_hours": 1}},
+			{"when": {"score": "total", "op": "<", "value": 2}, "then": {"stop": true}}
+		]
+	}`
+
+	tests := []struct {
+		name          string
+		followUp      string
+		answers       string
+		wantNextIn    time.Duration
+		wantFollowUp  bool
+		wantCompleted bool
+		wantSkipped   bool
+	}{
+		{name: "No rule holds", followUp: "q2", answers: `{"mood": 8, "harm": "no"}`, wantNextIn: 168 * time.Hour},
+		{name: "High score reschedules sooner", followUp: "q2", answers: `{"mood": 15}`, wantNextIn: 24 * time.Hour},
+		{name: "Answer schedules a follow-up", followUp: "q2", answers: `{"mood": 8, "harm": "yes"}`, wantNextIn: 168 * time.Hour, wantFollowUp: true},
+		{name: "Low score stops the series", followUp: "q2", answers: `{"mood": 1}`, wantCompleted: true},
+		{name: "Follow-up of another study", followUp: "q3", answers: `{"mood": 8, "harm": "yes"}`, wantNextIn: 168 * time.Hour, wantSkipped: true},
+		{name: "Unknown follow-up", followUp: "q4", answers: `{"mood": 8, "harm": "yes"}`, wantNextIn: 168 * time.Hour, wantSkipped: true},
+		{name: "Deleted follow-up", followUp: "q5", answers: `{"mood": 8, "harm": "yes"}`, wantNextIn: 168 * time.Hour, wantSkipped: true},
+		{name: "Follow-up of itself", followUp: "q1", answers: `{"mood": 8, "harm": "yes"}`, wantNextIn: 168 * time.Hour, wantSkipped: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, models.Questionnaire{ID: "q1", StudyID: "Study5", Questions: fmt.Sprintf(definition, tt.followUp), HoursBetweenAttempts: 168})
+			f.questionnaires.Add(models.Questionnaire{ID: "q2", StudyID: "Study5", HoursBetweenAttempts: 24})
+			f.questionnaires.Add(models.Questionnaire{ID: "q3", StudyID: "Study6", HoursBetweenAttempts: 24})
+			f.questionnaires.Add(models.Questionnaire{ID: "q5", StudyID: "Study5", HoursBetweenAttempts: 24, DeletedAt: timestamp.NewNullTimeStamp(completedAt)})
+
+			result, err := f.rescheduler.HandleCompletion(context.Background(), &models.QuestionnaireCompletedEvent{
+				UserID:               "p1",
+				QuestionnaireID:      "q1",
+				CompletedAt:          completedAt,
+				RemainingCompletions: 3,
+				Answers:              json.RawMessage(tt.answers),
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			// A misconfigured rule leaves its follow-up out, the rest of the completion is handled.
+			if (len(result.SkippedFollowUps) == 1) != tt.wantSkipped || len(result.SkippedFollowUps) > 1 {
+				t.Fatalf("Unexpected skipped follow-ups.\nGot: %v\nExpected skipped: %v", result.SkippedFollowUps, tt.wantSkipped)
+			}
+
+			if result.SeriesCompleted != tt.wantCompleted || result.Stopped != tt.wantCompleted {
+				t.Fatalf("Unexpected end of series.\nGot: completed %v, stopped %v\nExpected: %v", result.SeriesCompleted, result.Stopped, tt.wantCompleted)
+			}
+			if tt.wantCompleted {
+				if result.NextSchedule != nil || !reflect.DeepEqual(f.queue.completion, []string{"p1"}) {
+					t.Fatalf("Expected the series to stop.\nGot: next %+v, completion %v", result.NextSchedule, f.queue.completion)
+				}
+			} else if result.NextSchedule == nil || !result.NextSchedule.ScheduledAt.Equal(completedAt.Add(tt.wantNextIn)) {
+				t.Fatalf("Unexpected next schedule.\nGot: %+v\nExpected at: %v", result.NextSchedule, completedAt.Add(tt.wantNextIn))
+			}
+
+			if !tt.wantFollowUp {
+				if len(result.FollowUps) != 0 {
+					t.Fatalf("Expected no follow-ups, got %+v", result.FollowUps)
+				}
+				return
+			}
+			followUp, err := f.schedules.FindScheduledQuestionnaireByQuestionnaireIDAndUserID("q2", "p1")
+			if err != nil || len(result.FollowUps) != 1 || result.FollowUps[0].ID != followUp.ID || !followUp.ScheduledAt.Equal(completedAt.Add(time.Hour)) {
+				t.Fatalf("Unexpected follow-up.\nGot: %+v, stored %+v, %v", result.FollowUps, followUp, err)
+			}
+			if !reflect.DeepEqual(f.queue.newSchedule, []string{followUp.ID + "/p1", result.NextSchedule.ID + "/p1"}) {
+				t.Fatalf("Unexpected new schedule messages: %v", f.queue.newSchedule)
+			}
+		})
+	}
+}
+
+func TestHandleCompletion_Protocol(t *testing.T) {
+	completedAt := timestamp.TimeStamp{Time: time.Date(2023, 12, 4, 2, 0, 0, 0, time.UTC)}
+	protocol := `{
+		"links": [
+			{"after": "q1", "schedule": "q2", "in_hours": 48},
+			{"after": "q1", "on": "series_completed", "schedule": "%s", "in_hours": 24}
+		]
+	}`
+
+	tests := []struct {
+		name          string
+		final         string
+		questions     string
+		remaining     int
+		wantFollowUps map[string]time.Duration
+		wantSkipped   bool
+	}{
+		{name: "Completion", final: "q3", remaining: 2, wantFollowUps: map[string]time.Duration{"q2": 48 * time.Hour}},
+		{name: "End of the series", final: "q3", remaining: 0, wantFollowUps: map[string]time.Duration{"q2": 48 * time.Hour, "q3": 24 * time.Hour}},
+		{
+			name:          "A rule scheduling the same questionnaire wins",
+			final:         "q3",
+			questions:     `{"questions": [{"id": "urgent", "type": "text"}], "rules": [{"when": {"answer": "urgent", "op": "=", "value": "yes"}, "then": {"schedule": "q2", "schedule_in_hours": 1}}]}`,
+			remaining:     2,
+			wantFollowUps: map[string]time.Duration{"q2": time.Hour},
+		},
+		{name: "Questionnaire of another study", final: "q4", remaining: 0, wantFollowUps: map[string]time.Duration{"q2": 48 * time.Hour}, wantSkipped: true},
+		{name: "Deleted questionnaire", final: "q5", remaining: 0, wantFollowUps: map[string]time.Duration{"q2": 48 * time.Hour}, wantSkipped: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, models.Questionnaire{
+				ID:                   "q1",
+				StudyID:              "Study5",
+				Questions:            tt.questions,
+				MaxAttempts:          sql.NullInt64{Int64: 3, Valid: true},
+				HoursBetweenAttempts: 168,
+			})
+			f.questionnaires.Add(models.Questionnaire{ID: "q2", StudyID: "Study5", HoursBetweenAttempts: 24})
+			f.questionnaires.Add(models.Questionnaire{ID: "q3", StudyID: "Study5", HoursBetweenAttempts: 24})
+			f.questionnaires.Add(models.Questionnaire{ID: "q4", StudyID: "Study6", HoursBetweenAttempts: 24})
+			f.questionnaires.Add(models.Questionnaire{ID: "q5", StudyID: "Study5", HoursBetweenAttempts: 24, DeletedAt: timestamp.NewNullTimeStamp(completedAt)})
+			f.protocols.Add(models.StudyProtocol{StudyID: "Study5", Definition: fmt.Sprintf(protocol, tt.final)})
+
+			answers := `{}`
+			if tt.questions != "" {
+				answers = `{"urgent": "yes"}`
+			}
+			result, err := f.rescheduler.HandleCompletion(context.Background(), &models.QuestionnaireCompletedEvent{
+				UserID:               "p1",
+				QuestionnaireID:      "q1",
+				CompletedAt:          completedAt,
+				RemainingCompletions: tt.remaining,
+				Answers:              json.RawMessage(answers),
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if (len(result.SkippedFollowUps) != 0) != tt.wantSkipped {
+				t.Fatalf("Unexpected skipped follow-ups.\nGot: %v\nExpected skipped: %v", result.SkippedFollowUps, tt.wantSkipped)
+			}
+
+			got := make(map[string]time.Duration, len(result.FollowUps))
+			for _, followUp := range result.FollowUps {
+				got[followUp.QuestionnaireID] = followUp.ScheduledAt.Sub(completedAt.Time)
+			}
+			if !reflect.DeepEqual(got, tt.wantFollowUps) {
+				t.Fatalf("Unexpected follow-ups.\nGot: %v\nExpected: %v", got, tt.wantFollowUps)
+			}
+			if result.SeriesCompleted != (tt.remaining == 0) {
+				t.Fatalf("Unexpected end of series.\nGot: %v\nExpected: %v", result.SeriesCompleted, tt.remaining == 0)
+			}
+		})
+	}
+}
+
+func TestHandleCompletion_PendingFollowUp(t *testing.T) {
+	f := newFixture(t, models.Questionnaire{
+		ID:                   "q1",
+		StudyID:              "Study5",
+		Questions:            `{"questions": [{"id": "harm", "type": "text"}], "rules": [{"when": {"answer": "harm", "op": "=", "value": "yes"}, "then": {"schedule": "q2"}}]}`,
+		HoursBetweenAttempts: 24,
+	})
+	f.questionnaires.Add(models.Questionnaire{ID: "q2", StudyID: "Study5", HoursBetweenAttempts: 24})
+	pending := models.ScheduledQuestionnaire{
+		ID:              "follow-up-1",
+		QuestionnaireID: "q2",
+		ParticipantID:   "p1",
+		ScheduledAt:     timestamp.TimeStamp{Time: time.Date(2023, 12, 6, 0, 0, 0, 0, time.UTC)},
+		Status:          models.ScheduledQuestionnairePending,
+	}
+	if err := f.schedules.Create(&pending); err != nil {
+		t.Fatalf("Error seeding schedule: %v", err)
+	}
+
+	result, err := f.rescheduler.HandleCompletion(context.Background(), &models.QuestionnaireCompletedEvent{
+		UserID:          "p1",
+		QuestionnaireID: "q1",
+		CompletedAt:     timestamp.TimeStamp{Time: time.Date(2023, 12, 4, 2, 0, 0, 0, time.UTC)},
+		Answers:         json.RawMessage(`{"harm": "yes"}`),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.FollowUps) != 0 || len(f.queue.newSchedule) != 1 {
+		t.Fatalf("Expected the pending follow-up to be left as it is.\nGot: %+v, messages %v", result.FollowUps, f.queue.newSchedule)
+	}
+	if stored, _ := f.schedules.FindScheduledQuestionnaireByQuestionnaireIDAndUserID("q2", "p1"); stored.ID != pending.ID {
+		t.Fatalf("Unexpected pending follow-up.\nGot: %v\nExpected: %v", stored.ID, pending.ID)
+	}
+}
+
+func TestHandleCompletion_Study(t *testing.T) {
+	tests := []struct {
+		name               string
+		questionnaireStudy string
+		eventStudy         string
+		status             models.StudyStatus
+		expected           error
+	}{
+		{name: "Questionnaire of the study of the event", questionnaireStudy: "Study5", eventStudy: "Study5", status: models.StudyActive},
+		{name: "Event without a study", questionnaireStudy: "Study5", status: models.StudyActive},
+		{name: "Study not in the store", questionnaireStudy: "Study7", eventStudy: "Study7", status: models.StudyActive, expected: store.ErrNotFound},
+		{name: "Questionnaire of another study", questionnaireStudy: "Study5", eventStudy: "Study6", status: models.StudyActive, expected: store.ErrNotFound},
+		{name: "Closed study", questionnaireStudy: "Study5", eventStudy: "Study5", status: models.StudyClosed, expected: ErrStudyClosed},
+		{name: "Closed study, event without a study", questionnaireStudy: "Study5", status: models.StudyClosed, expected: ErrStudyClosed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, models.Questionnaire{ID: "q1", StudyID: tt.questionnaireStudy, HoursBetweenAttempts: 24})
+			f.studies.Add(models.Study{ID: "Study5", Name: "Study 5", Status: tt.status, TimeZone: "UTC", HoursBetweenAttempts: 24})
+
+			result, err := f.rescheduler.HandleCompletion(context.Background(), &models.QuestionnaireCompletedEvent{
+				UserID:          "p1",
+				StudyID:         tt.eventStudy,
+				QuestionnaireID: "q1",
+				CompletedAt:     timestamp.TimeStamp{Time: time.Date(2023, 12, 4, 2, 0, 0, 0, time.UTC)},
+				Answers:         answers,
+			})
+			if !errors.Is(err, tt.expected) {
+				t.Fatalf("Unexpected error.\nGot: %+v, %v\nExpected: %v", result, err, tt.expected)
+			}
+			if tt.expected != nil && (len(f.results.All()) != 0 || len(f.queue.newSchedule)+len(f.queue.completion) != 0) {
+				t.Fatalf("Expected a rejected completion to write nothing.\nGot: %d results, messages %v %v", len(f.results.All()), f.queue.newSchedule, f.queue.completion)
+			}
+		})
+	}
+}
+
+func TestHandleCompletion_ParticipationEnd(t *testing.T) {
+	completedAt := time.Date(2023, 12, 4, 2, 0, 0, 0, time.UTC)
+	at := func(t time.Time) timestamp.NullTimeStamp {
+		return timestamp.NewNullTimeStamp(timestamp.TimeStamp{Time: t})
+	}
+	enrolledAt := timestamp.TimeStamp{Time: time.Date(2023, 11, 4, 0, 0, 0, 0, time.UTC)}
+
+	tests := []struct {
+		name        string
+		study       models.Study
+		participant *models.Participant
+		wantEnded   bool
+	}{
+		{name: "No end"},
+		{name: "Study ends after the next attempt", study: models.Study{EndsAt: at(completedAt.AddDate(0, 0, 7))}},
+		{name: "Next attempt due when the study ends", study: models.Study{EndsAt: at(completedAt.Add(24 * time.Hour))}},
+		{name: "Study ends before the next attempt", study: models.Study{EndsAt: at(completedAt.Add(12 * time.Hour))}, wantEnded: true},
+		{
+			name:        "Participation ends before the next attempt",
+			participant: &models.Participant{EnrolledAt: enrolledAt, ParticipationEndsAt: at(completedAt.Add(time.Hour))},
+			wantEnded:   true,
+		},
+		{
+			name:        "Participation window of the study ends before the next attempt",
+			study:       models.Study{ParticipationDays: sql.NullInt64{Int64: 30, Valid: true}},
+			participant: &models.Participant{EnrolledAt: enrolledAt},
+			wantEnded:   true,
+		},
+		{
+			name:        "Participation of the participant overrides the window of the study",
+			study:       models.Study{ParticipationDays: sql.NullInt64{Int64: 30, Valid: true}},
+			participant: &models.Participant{EnrolledAt: enrolledAt, ParticipationEndsAt: at(completedAt.AddDate(0, 1, 0))},
+		},
+		{
+			name:        "Earliest of the study and the participation",
+			study:       models.Study{EndsAt: at(completedAt.Add(time.Hour))},
+			participant: &models.Participant{EnrolledAt: enrolledAt, ParticipationEndsAt: at(completedAt.AddDate(0, 1, 0))},
+			wantEnded:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, models.Questionnaire{ID: "q1", StudyID: "Study5", HoursBetweenAttempts: 24})
+			study := tt.study
+			study.ID, study.Name, study.Status, study.TimeZone, study.HoursBetweenAttempts = "Study5", "Study 5", models.StudyActive, "UTC", 24
+			f.studies.Add(study)
+			if tt.participant != nil {
+				participant := *tt.participant
+				participant.ID, participant.StudyID, participant.Status, participant.TimeZone = "p1", "Study5", models.ParticipantActive, "UTC"
+				f.participants.Add(participant)
+			}
+
+			result, err := f.rescheduler.HandleCompletion(context.Background(), &models.QuestionnaireCompletedEvent{
+				UserID:               "p1",
+				QuestionnaireID:      "q1",
+				CompletedAt:          timestamp.TimeStamp{Time: completedAt},
+				RemainingCompletions: 3,
+				Answers:              answers,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Ended != tt.wantEnded || result.SeriesCompleted != tt.wantEnded || (result.NextSchedule == nil) != tt.wantEnded {
+				t.Fatalf("Unexpected outcome.\nGot: ended %v, series completed %v, next %+v\nExpected: ended %v", result.Ended, result.SeriesCompleted, result.NextSchedule, tt.wantEnded)
+			}
+			if tt.wantEnded && (!reflect.DeepEqual(f.queue.completion, []string{"p1"}) || len(f.queue.newSchedule) != 0) {
+				t.Fatalf("Expected only the completion message.\nGot: new schedule %v, completion %v", f.queue.newSchedule, f.queue.completion)
+			}
+			if tt.participant != nil {
+				expected := models.ParticipantStatus(models.ParticipantActive)
+				if tt.wantEnded {
+					expected = models.ParticipantCompleted
+				}
+				if participant, _ := f.participants.FindParticipantByID("p1"); participant.Status != expected {
+					t.Fatalf("Unexpected participant status.\nGot: %v\nExpected: %v", participant.Status, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleCompletion_InactiveParticipant(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   models.ParticipantStatus
+		expected error
+	}{
+		{name: "Active", status: models.ParticipantActive},
+		{name: "Paused", status: models.ParticipantPaused, expected: ErrParticipantInactive},
+		{name: "Withdrawn", status: models.ParticipantWithdrawn, expected: ErrParticipantInactive},
+		{name: "Completed", status: models.ParticipantCompleted, expected: ErrParticipantInactive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, models.Questionnaire{ID: "q1", StudyID: "Study5", HoursBetweenAttempts: 24})
+			f.participants.Add(models.Participant{ID: "p1", StudyID: "Study5", Status: tt.status, TimeZone: "UTC"})
+
+			_, err := f.rescheduler.HandleCompletion(context.Background(), &models.QuestionnaireCompletedEvent{
+				UserID:          "p1",
+				QuestionnaireID: "q1",
+				Answers:         answers,
+			})
+			if !errors.Is(err, tt.expected) {
+				t.Fatalf("Unexpected error.\nGot: %v\nExpected: %v", err, tt.expected)
+			}
+			if tt.expected == nil {
+				return
+			}
+			// Nothing is written, the schedule stays pending for when the participant is resumed.
+			if schedule, err := f.schedules.FindScheduledQuestionnaireByQuestionnaireIDAndUserID("q1", "p1"); err != nil || schedule.ID != "schedule-1" {
+				t.Fatalf("Expected the schedule to stay pending.\nGot: %+v, %v", schedule, err)
+			}
+			if len(f.results.All()) != 0 || len(f.queue.newSchedule) != 0 || len(f.queue.completion) != 0 {
+				t.Fatalf("Expected nothing to be written or sent.\nGot: %d results, new schedule %v, completion %v", len(f.results.All()), f.queue.newSchedule, f.queue.completion)
+			}
+		})
+	}
+}
+
+func TestHandleCompletion_ParticipationEndLastSeries(t *testing.T) {
+	completedAt := time.Date(2023, 12, 4, 2, 0, 0, 0, time.UTC)
+	enrolledAt := timestamp.TimeStamp{Time: time.Date(2023, 11, 4, 0, 0, 0, 0, time.UTC)}
+
+	tests := []struct {
+		name          string
+		participation timestamp.NullTimeStamp
+		expected      models.ParticipantStatus
+	}{
+		{name: "Participation with an end", participation: timestamp.NewNullTimeStamp(timestamp.TimeStamp{Time: completedAt.Add(30 * time.Hour)}), expected: models.ParticipantCompleted},
+		{name: "Participation without an end", expected: models.ParticipantActive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, models.Questionnaire{ID: "q1", StudyID: "Study5", HoursBetweenAttempts: 48})
+			f.questionnaires.Add(models.Questionnaire{ID: "q2", StudyID: "Study5", MaxAttempts: sql.NullInt64{Int64: 2, Valid: true}, HoursBetweenAttempts: 12})
+			f.participants.Add(models.Participant{ID: "p1", StudyID: "Study5", EnrolledAt: enrolledAt, Status: models.ParticipantActive, TimeZone: "UTC", ParticipationEndsAt: tt.participation})
+			pending := models.ScheduledQuestionnaire{ID: "schedule-2", QuestionnaireID: "q2", ParticipantID: "p1", ScheduledAt: enrolledAt, Status: models.ScheduledQuestionnairePending}
+			if err := f.schedules.Create(&pending); err != nil {
+				t.Fatalf("Error seeding schedule: %v", err)
+			}
+
+			// With an end, the next attempt of q1 would be due after it, which ends its series while q2 is still
+			// pending: the participant stays active.
+			first, err := f.rescheduler.HandleCompletion(context.Background(), &models.QuestionnaireCompletedEvent{
+				UserID:               "p1",
+				QuestionnaireID:      "q1",
+				CompletedAt:          timestamp.TimeStamp{Time: completedAt},
+				RemainingCompletions: 3,
+				Answers:              answers,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if participant, _ := f.participants.FindParticipantByID("p1"); participant.Status != models.ParticipantActive {
+				t.Fatalf("Expected the participant to stay active with q2 pending.\nGot: %v, ended %v", participant.Status, first.Ended)
+			}
+
+			// The last attempt of q2 completes its series, its next attempt would still have been due before the end.
+			last, err := f.rescheduler.HandleCompletion(context.Background(), &models.QuestionnaireCompletedEvent{
+				UserID:               "p1",
+				QuestionnaireID:      "q2",
+				CompletedAt:          timestamp.TimeStamp{Time: completedAt.Add(time.Hour)},
+				RemainingCompletions: 0,
+				Answers:              answers,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !last.SeriesCompleted || last.Ended {
+				t.Fatalf("Expected q2 to complete its series without reaching the end.\nGot: completed %v, ended %v", last.SeriesCompleted, last.Ended)
+			}
+			if participant, _ := f.participants.FindParticipantByID("p1"); participant.Status != tt.expected {
+				t.Fatalf("Unexpected participant status.\nGot: %v\nExpected: %v", participant.Status, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHandleCompletion_ParticipationEndFollowUps(t *testing.T) {
+	completedAt := time.Date(2023, 12, 4, 2, 0, 0, 0, time.UTC)
+	f := newFixture(t, models.Questionnaire{ID: "q1", StudyID: "Study5", HoursBetweenAttempts: 24})
+	f.questionnaires.Add(models.Questionnaire{ID: "q2", StudyID: "Study5", HoursBetweenAttempts: 24})
+	f.questionnaires.Add(models.Questionnaire{ID: "exit", StudyID: "Study5", HoursBetweenAttempts: 24})
+	f.studies.Add(models.Study{
+		ID:                   "Study5",
+		Name:                 "Study 5",
+		Status:               models.StudyActive,
+		EndsAt:               timestamp.NewNullTimeStamp(timestamp.TimeStamp{Time: completedAt.Add(6 * time.Hour)}),
+		TimeZone:             "UTC",
+		HoursBetweenAttempts: 24,
+	})
+	// The end of the study ends the series, which the exit questionnaire waits for. The other follow-up would be due too late.
+	f.protocols.Add(models.StudyProtocol{StudyID: "Study5", Definition: `{"links": [
+		{"after": "q1", "schedule": "q2", "in_hours": 48},
+		{"after": "q1", "on": "series_completed", "schedule": "exit", "in_hours": 1}
+	]}`})
+
+	result, err := f.rescheduler.HandleCompletion(context.Background(), &models.QuestionnaireCompletedEvent{
+		UserID:               "p1",
+		QuestionnaireID:      "q1",
+		CompletedAt:          timestamp.TimeStamp{Time: completedAt},
+		RemainingCompletions: 3,
+		Answers:              answers,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !result.Ended || len(result.FollowUps) != 1 || result.FollowUps[0].QuestionnaireID != "exit" {
+		t.Fatalf("Unexpected follow-ups.\nGot: ended %v, %+v\nExpected: only exit", result.Ended, result.FollowUps)
+	}
+}
+
+func TestHandleCompletion_Versions(t *testing.T) {
+	completedAt := timestamp.TimeStamp{Time: time.Date(2023, 12, 4, 2, 0, 0, 0, time.UTC)}
+	first := models.Questionnaire{
+		ID:                   "q1",
+		StudyID:              "Study5",
+		Questions:            `{"questions": [{"id": "mood", "type": "numeric", "required": true}], "scores": [{"id": "total", "questions": ["mood"]}]}`,
+		HoursBetweenAttempts: 168,
+	}
+	// The questionnaire is edited while the participant's schedule of version 1 is pending.
+	second := first
+	second.Version = 2
+	second.Questions = `{"questions": [{"id": "sleep", "type": "text", "required": true}]}`
+	second.HoursBetweenAttempts = 24
+
+	tests := []struct {
+		name    string
+		answers string
+		wantErr error
+	}{
+		{name: "Answers to the version scheduled", answers: `{"mood": 4}`},
+		{name: "Answers to the current version", answers: `{"sleep": "well"}`, wantErr: questions.ErrInvalidAnswers},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, first)
+			f.questionnaires.Add(second)
+
+			result, err := f.rescheduler.HandleCompletion(context.Background(), &models.QuestionnaireCompletedEvent{
+				UserID:               "p1",
+				QuestionnaireID:      "q1",
+				CompletedAt:          completedAt,
+				RemainingCompletions: 3,
+				Answers:              json.RawMessage(tt.answers),
+			})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) || len(f.results.All()) != 0 {
+					t.Fatalf("Unexpected outcome.\nGot: %v, %d results\nExpected: %v", err, len(f.results.All()), tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			// The result is pinned to the version answered, and scored with its rules.
+			if result.QuestionnaireResult.QuestionnaireVersion != 1 || result.QuestionnaireResult.Scores["total"] != 4 {
+				t.Fatalf("Unexpected result.\nGot: %+v\nExpected: version 1 with a total of 4", result.QuestionnaireResult)
+			}
+			// The next attempt is issued against the current version, with its settings.
+			next := result.NextSchedule
+			if next.QuestionnaireVersion != 2 || !next.ScheduledAt.Equal(completedAt.Add(24*time.Hour)) {
+				t.Fatalf("Unexpected next schedule.\nGot: %+v\nExpected: version 2 at %v", next, completedAt.Add(24*time.Hour))
+			}
+		})
+	}
+}
+
+func TestHandleCompletion_QueueError(t *testing.T) {
+	f := newFixture(t, models.Questionnaire{ID: "q1", StudyID: "Study5", HoursBetweenAttempts: 24})
+	f.queue.err = errors.New("queue unavailable")
+
+	_, err := f.rescheduler.HandleCompletion(context.Background(), &models.QuestionnaireCompletedEvent{UserID: "p1", QuestionnaireID: "q1", RemainingCompletions: 1, Answers: answers})
+	if !errors.Is(err, f.queue.err) {
+		t.Fatalf("Expected the queue error to be wrapped.\nGot: %v", err)
+	}
+}
+
+func TestHandleCompletion_DeterministicWithFakeClock(t *testing.T) {
+	f := newFixture(t, models.Questionnaire{ID: "q1", StudyID: "Study5", HoursBetweenAttempts: 24})
+	event := &models.QuestionnaireCompletedEvent{UserID: "p1", QuestionnaireID: "q1", RemainingCompletions: 2, Answers: answers}
+
+	first, err := f.rescheduler.HandleCompletion(context.Background(), event)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	f.clock.Advance(30 * time.Hour)
+	second, err := f.rescheduler.HandleCompletion(context.Background(), event)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if first.QuestionnaireResult.ID != "id-1" || first.NextSchedule.ID != "id-2" || second.NextSchedule.ID != "id-4" {
+		t.Fatalf("Unexpected generated IDs: %s, %s, %s", first.QuestionnaireResult.ID, first.NextSchedule.ID, second.NextSchedule.ID)
+	}
+	if second.CompletedSchedule.ID != first.NextSchedule.ID {
+		t.Fatalf("Expected the second completion to complete the first follow-up.\nGot: %s\nExpected: %s", second.CompletedSchedule.ID, first.NextSchedule.ID)
+	}
+
+	expected := time.Date(2023, 12, 7, 6, 0, 0, 0, time.UTC)
+	if !second.NextSchedule.ScheduledAt.Equal(expected) {
+		t.Fatalf("Unexpected next schedule time.\nGot: %v\nExpected: %v", second.NextSchedule.ScheduledAt, expected)
+	}
+}
+
+// barrierSchedules holds every lookup of a pending schedule until the expected number of lookups has been made,
+// so that concurrent completions all read the schedule before any of them updates it.
+type barrierSchedules struct {
+	*memstore.ScheduledQuestionnaireStore
+	found sync.WaitGroup
+}
+
+func (b *barrierSchedules) FindScheduledQuestionnaireByQuestionnaireIDAndUserID(questionnaireID string, userID string) (*models.ScheduledQuestionnaire, error) {
+	schedule, err := b.ScheduledQuestionnaireStore.FindScheduledQuestionnaireByQuestionnaireIDAndUserID(questionnaireID, userID)
+	b.found.Done()
+	b.found.Wait()
+	return schedule, err
+}
+
+func TestHandleCompletion_ConcurrentDuplicates(t *testing.T) {
+	const deliveries = 5
+
+	f := newFixture(t, models.Questionnaire{ID: "q1", StudyID: "Study5", HoursBetweenAttempts: 24})
+	schedules := &barrierSchedules{ScheduledQuestionnaireStore: f.schedules}
+	schedules.found.Add(deliveries)
+	f.rescheduler.stores.ScheduledQuestionnaires = schedules
+
+	results := make([]*Result, deliveries)
+	errs := make([]error, deliveries)
+	var wg sync.WaitGroup
+	for i := 0; i < deliveries; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = f.rescheduler.HandleCompletion(context.Background(), &models.QuestionnaireCompletedEvent{UserID: "p1", QuestionnaireID: "q1", RemainingCompletions: 1, Answers: answers})
+		}(i)
+	}
+	wg.Wait()
+
+	duplicates := 0
+	for i := range results {
+		if errs[i] != nil {
+			t.Fatalf("Unexpected error: %v", errs[i])
+		}
+		if results[i].Duplicate {
+			duplicates++
+		}
+	}
+	if duplicates != deliveries-1 {
+		t.Fatalf("Unexpected number of duplicates.\nGot: %d\nExpected: %d", duplicates, deliveries-1)
+	}
+	if len(f.results.All()) != 1 || len(f.queue.newSchedule) != 1 || len(f.schedules.All()) != 2 {
+		t.Fatalf("Expected a single result, follow-up and message.\nGot: %d results, %d schedules, messages %v", len(f.results.All()), len(f.schedules.All()), f.queue.newSchedule)
+	}
+}
+
+// TestHandleCompletion_Redelivered delivers the same event twice, the second time after the first delivery completed.
+func TestHandleCompletion_Redelivered(t *testing.T) {
+	tests := []struct {
+		name          string
+		eventID       string
+		wantDuplicate bool
+		results       int
+	}{
+		{name: "Event with an ID", eventID: "event-1", wantDuplicate: true, results: 1},
+		// Without an ID the redelivery cannot be told from the next completion, and completes the next attempt.
+		{name: "Event without an ID", results: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, models.Questionnaire{ID: "q1", StudyID: "Study5", HoursBetweenAttempts: 24})
+			event := &models.QuestionnaireCompletedEvent{
+				ID:                   tt.eventID,
+				UserID:               "p1",
+				QuestionnaireID:      "q1",
+				CompletedAt:          timestamp.TimeStamp{Time: time.Date(2023, 12, 4, 2, 0, 0, 0, time.UTC)},
+				RemainingCompletions: 3,
+				Answers:              answers,
+			}
+
+			first, err := f.rescheduler.HandleCompletion(context.Background(), event)
+			if err != nil || first.Duplicate {
+				t.Fatalf("Unexpected first delivery.\nGot: %+v, %v", first, err)
+			}
+			second, err := f.rescheduler.HandleCompletion(context.Background(), event)
+			if err != nil || second.Duplicate != tt.wantDuplicate {
+				t.Fatalf("Unexpected second delivery.\nGot: %+v, %v\nExpected duplicate: %v", second, err, tt.wantDuplicate)
+			}
+			if len(f.results.All()) != tt.results || len(f.queue.newSchedule) != tt.results {
+				t.Fatalf("Unexpected results and messages.\nGot: %d results, messages %v\nExpected: %d of each", len(f.results.All()), f.queue.newSchedule, tt.results)
+			}
+			if !tt.wantDuplicate {
+				return
+			}
+			if second.QuestionnaireResult == nil || second.QuestionnaireResult.ID != first.QuestionnaireResult.ID || second.CompletedSchedule != nil {
+				t.Fatalf("Expected the result of the first delivery.\nGot: %+v\nExpected: %+v", second, first.QuestionnaireResult)
+			}
+			if pending, err := f.schedules.FindScheduledQuestionnaireByQuestionnaireIDAndUserID("q1", "p1"); err != nil || pending.ID != first.NextSchedule.ID {
+				t.Fatalf("Expected the next attempt to stay pending.\nGot: %+v, %v\nExpected: %s", pending, err, first.NextSchedule.ID)
+			}
+		})
+	}
+}
+
+// TestHandleCompletion_FailedWrite fails the result of a completion, which must leave the schedule pending
+// and nothing else written, so that a retry of the event completes it.
+func TestHandleCompletion_FailedWrite(t *testing.T) {
+	f := newFixture(t, models.Questionnaire{ID: "q1", StudyID: "Study5", HoursBetweenAttempts: 24})
+	// The first ID generated goes to the result, which then collides with this one.
+	if err := f.results.Create(&models.QuestionnaireResult{ID: "id-1", Answers: answers}); err != nil {
+		t.Fatalf("Error seeding result: %v", err)
+	}
+	event := &models.QuestionnaireCompletedEvent{
+		ID:                   "event-1",
+		UserID:               "p1",
+		QuestionnaireID:      "q1",
+		CompletedAt:          timestamp.TimeStamp{Time: time.Date(2023, 12, 4, 2, 0, 0, 0, time.UTC)},
+		RemainingCompletions: 3,
+		Answers:              answers,
+	}
+
+	if result, err := f.rescheduler.HandleCompletion(context.Background(), event); !errors.Is(err, store.ErrDuplicate) {
+		t.Fatalf("Expected the result to fail.\nGot: %+v, %v", result, err)
+	}
+	if all := f.schedules.All(); len(all) != 1 || all[0].Status != models.ScheduledQuestionnairePending || len(f.results.All()) != 1 || len(f.queue.newSchedule) != 0 {
+		t.Fatalf("Expected nothing written or sent.\nGot: %+v, %d results, messages %v", all, len(f.results.All()), f.queue.newSchedule)
+	}
+
+	retried, err := f.rescheduler.HandleCompletion(context.Background(), event)
+	if err != nil || retried.Duplicate || retried.CompletedSchedule.ID != "schedule-1" || retried.NextSchedule == nil {
+		t.Fatalf("Expected the retry to complete the schedule.\nGot: %+v, %v", retried, err)
+	}
+	if len(f.results.All()) != 2 || len(f.queue.newSchedule) != 1 {
+		t.Fatalf("Unexpected results and messages after the retry.\nGot: %d results, messages %v", len(f.results.All()), f.queue.newSchedule)
+	}
+}
+
+// TestHandleCompletion_SQLite runs a series of completions against the SQL stores on a SQLite file,
+// covering the queries and constraints the in-memory stores do not.
+func TestHandleCompletion_SQLite(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.Open(ctx, dialect.SQLite, database.DatabaseConnection{Database: filepath.Join(t.TempDir(), "rescheduler.db")})
+	if err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	defer db.Close()
+
+	migrator, err := migrate.New(db, dialect.SQLite)
+	if err != nil {
+		t.Fatalf("Error loading migrations: %v", err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Error migrating database: %v", err)
+	}
+
+	for _, statement := range []string{
+		"INSERT INTO participants (id, name) VALUES ('p1', 'Ada')",
+		"INSERT INTO studies (id, name) VALUES ('Study5', 'Study 5')",
+		`INSERT INTO questionnaires (id, study_id, name, questions, max_attempts, hours_between_attempts) VALUES ('q1', 'Study5', 'Mood', '{}', 2, 24)`,
+		`INSERT INTO questionnaire_versions (questionnaire_id, version, questions, max_attempts, hours_between_attempts, created_at) VALUES ('q1', 1, '{}', 2, 24, '2023-12-01 00:00:00')`,
+		"INSERT INTO scheduled_questionnaires (id, questionnaire_id, participant_id, scheduled_at, status) VALUES ('schedule-1', 'q1', 'p1', '2023-12-03 02:00:00', 'pending')",
+	} {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("Error seeding database: %v", err)
+		}
+	}
+
+	withDialect := store.WithDialect(dialect.SQLite)
+	queue := &recordingQueue{}
+	r, err := New(Stores{
+		Questionnaires:          store.NewQuestionnaireStore(db, withDialect),
+		ScheduledQuestionnaires: store.NewScheduledQuestionnaireStore(db, withDialect),
+		Participants:            store.NewParticipantStore(db, withDialect),
+		QuestionnaireResults:    store.NewQuestionnaireResultStore(db, withDialect),
+		Protocols:               store.NewStudyProtocolStore(db, withDialect),
+		Studies:                 store.NewStudyStore(db, withDialect),
+	}, queue, clock.NewFake(time.Date(2023, 12, 5, 0, 0, 0, 0, time.UTC)), idgen.NewSequence("id"))
+	if err != nil {
+		t.Fatalf("Error creating rescheduler: %v", err)
+	}
+
+	completedAt := timestamp.TimeStamp{Time: time.Date(2023, 12, 4, 2, 11, 0, 0, time.UTC)}
+	event := &models.QuestionnaireCompletedEvent{ID: "event-1", UserID: "p1", StudyID: "Study5", QuestionnaireID: "q1", CompletedAt: completedAt, RemainingCompletions: 1, Answers: answers}
+	first, err := r.HandleCompletion(ctx, event)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if first.NextSchedule == nil || !first.NextSchedule.ScheduledAt.Equal(completedAt.Add(24*time.Hour)) {
+		t.Fatalf("Unexpected next schedule: %+v", first.NextSchedule)
+	}
+
+	redelivered, err := r.HandleCompletion(ctx, event)
+	if err != nil || !redelivered.Duplicate || redelivered.QuestionnaireResult.ID != first.QuestionnaireResult.ID {
+		t.Fatalf("Expected the redelivered event to be a duplicate of the first delivery, got %+v, %v", redelivered, err)
+	}
+
+	second, err := r.HandleCompletion(ctx, &models.QuestionnaireCompletedEvent{UserID: "p1", StudyID: "Study5", QuestionnaireID: "q1", RemainingCompletions: 0, Answers: answers})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !second.SeriesCompleted || second.CompletedSchedule.ID != first.NextSchedule.ID {
+		t.Fatalf("Expected the follow-up to complete the series, got %+v", second)
+	}
+
+	var results, pending int
+	if err := db.QueryRow("SELECT COUNT(*) FROM questionnaire_results").Scan(&results); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM scheduled_questionnaires WHERE status = 'pending'").Scan(&pending); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if results != 2 || pending != 0 {
+		t.Fatalf("Unexpected database state.\nGot: %d results, %d pending\nExpected: 2 results, 0 pending", results, pending)
+	}
+	if !reflect.DeepEqual(queue.completion, []string{"p1"}) || len(queue.newSchedule) != 1 {
+		t.Fatalf("Unexpected messages: new schedule %v, completion %v", queue.newSchedule, queue.completion)
+	}
+}
@@ -3,6 +3,8 @@
 package sqs
 
 import (
+	"encoding/json"
+
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/sqs"
@@ -11,20 +13,27 @@ import (
 // SQS defines methods for interacting with Amazon SQS.
 type SQS interface {
 	SendNewScheduleMessage(scheduleID string, participantID string) error
-	SendCompletionMessage(participantID string) error
+	SendCompletionMessage(participantID string, scores map[string]float64) error
 }
 
 // SQSHandler is an implementation of the SQS interface.
 type SQSHandler struct {
-	queueURL string
-	region   string
+	newScheduleQueueURL string
+	completionQueueURL  string
+	region              string
 }
 
-// NewSQSHandler creates a new instance of SQSImpl.
+// NewSQSHandler creates a new instance of SQSImpl sending every message to the same queue.
 func NewSQSHandler(queueURL, region string) *SQSHandler {
+	return NewSQSHandlerWithQueues(queueURL, queueURL, region)
+}
+
+// NewSQSHandlerWithQueues creates a new instance of SQSImpl sending new schedule and completion messages to separate queues.
+func NewSQSHandlerWithQueues(newScheduleQueueURL, completionQueueURL, region string) *SQSHandler {
 	return &SQSHandler{
-		queueURL: queueURL,
-		region:   region,
+		newScheduleQueueURL: newScheduleQueueURL,
+		completionQueueURL:  completionQueueURL,
+		region:              region,
 	}
 }
 
@@ -44,7 +53,7 @@ func (s *SQSHandler) SendNewScheduleMessage(scheduleID string, participantID str
 
 	_, err = svc.SendMessage(&sqs.SendMessageInput{
 		MessageBody:  aws.String(message),
-		QueueUrl:     &s.queueURL,
+		QueueUrl:     &s.newScheduleQueueURL,
 		DelaySeconds: aws.Int64(0),
 	})
 
@@ -53,7 +62,8 @@ func (s *SQSHandler) SendNewScheduleMessage(scheduleID string, participantID str
 
 // SendCompletionMessage sends a message to SQS indicating that the user has completed all scheduled questionnaires.
 // The actual message structure is not known at this stage, so a simple text message is sent.
-func (s *SQSHandler) SendCompletionMessage(participantID string) error {
+// The scores of the last result, when there are any, follow the text as a JSON object keyed by score ID.
+func (s *SQSHandler) SendCompletionMessage(participantID string, scores map[string]float64) error {
 	sess, err := session.NewSession(&aws.Config{
 		Region: aws.String(s.region),
 	})
@@ -64,10 +74,17 @@ func (s *SQSHandler) SendCompletionMessage(participantID string) error {
 	svc := sqs.New(sess)
 
 	message := "User " + participantID + " has completed all scheduled questionnaires."
+	if len(scores) > 0 {
+		b, err := json.Marshal(scores)
+		if err != nil {
+			return err
+		}
+		message += " Scores: " + string(b)
+	}
 
 	_, err = svc.SendMessage(&sqs.SendMessageInput{
 		MessageBody:  aws.String(message),
-		QueueUrl:     &s.queueURL,
+		QueueUrl:     &s.completionQueueURL,
 		DelaySeconds: aws.Int64(0),
 	})
 
@@ -0,0 +1,370 @@
+// Package store provides functionality to interact with the database for the rescheduler application.
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"rescheduler/internals/models"
+)
+
+// table describes the columns a store reads into and writes from a model.
+// Every query lists its columns explicitly, so adding a column in a migration cannot shift the positional Scan.
+// The first column is the ID.
+type table struct {
+	name    string
+	columns []string
+	// version names the column used for optimistic concurrency control, empty when the table has none.
+	version string
+	// deleted names the column set when a record is soft deleted, empty when records are deleted for good.
+	// Updates never write it and never match deleted records.
+	deleted string
+}
+
+// selectFrom returns "SELECT <columns> FROM <table>", to be followed by a WHERE clause.
+func (t table) selectFrom() string {
+	return "SELECT " + strings.Join(t.columns, ", ") + " FROM " + t.name
+}
+
+// insert returns an INSERT statement with one placeholder per column.
+func (t table) insert() string {
+	return "INSERT INTO " + t.name + " (" + strings.Join(t.columns, ", ") + ") VALUES (" + placeholders(len(t.columns)) + ")"
+}
+
+// update returns an UPDATE statement setting every column but the ID, matched by the ID.
+// With a version column the version is incremented and must still match the one read,
+// so a stale update changes no row. With a deleted column, deleted records are not matched.
+// Its arguments are built by updateArgs.
+func (t table) update() string {
+	assignments := make([]string, 0, len(t.columns))
+	for _, column := range t.columns[1:] {
+		if column != t.version && column != t.deleted {
+			assignments = append(assignments, column+" = ?")
+		}
+	}
+	where := t.columns[0] + " = ?"
+	if t.version != "" {
+		assignments = append(assignments, t.version+" = "+t.version+" + 1")
+		where += " AND " + t.version + " = ?"
+	}
+	return "UPDATE " + t.name + " SET " + strings.Join(assignments, ", ") + " WHERE " + where + t.notDeleted()
+}
+
+// notDeleted returns the condition, starting with " AND ", excluding soft deleted records,
+// empty when the table has no deleted column.
+func (t table) notDeleted() string {
+	if t.deleted == "" {
+		return ""
+	}
+	return " AND " + t.deleted + " IS NULL"
+}
+
+// updateArgs reorders the values of a record, given in column order, into the arguments of update.
+func (t table) updateArgs(values []interface{}) []interface{} {
+	args := make([]interface{}, 0, len(values))
+	var version interface{}
+	for i, column := range t.columns[1:] {
+		if column == t.version {
+			version = values[i+1]
+			continue
+		}
+		if column == t.deleted {
+			continue
+		}
+		args = append(args, values[i+1])
+	}
+	args = append(args, values[0])
+	if t.version != "" {
+		args = append(args, version)
+	}
+	return args
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// nullString scans a nullable text column into a string, NULL becoming the empty string.
+type nullString struct {
+	s *string
+}
+
+func (n nullString) Scan(value interface{}) error {
+	var ns sql.NullString
+	if err := ns.Scan(value); err != nil {
+		return err
+	}
+	*n.s = ns.String
+	return nil
+}
+
+// rawJSON scans a JSON column into a json.RawMessage. Drivers return JSON as text or bytes,
+// and the bytes are copied as the driver may reuse them.
+type rawJSON struct {
+	m *json.RawMessage
+}
+
+func (r rawJSON) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		*r.m = append(json.RawMessage(nil), v...)
+	case string:
+		*r.m = json.RawMessage(v)
+	default:
+		return fmt.Errorf("scanning JSON: unsupported type %T", value)
+	}
+	return nil
+}
+
+// jsonValue scans a nullable JSON column into the value v points to, NULL leaving it unchanged.
+type jsonValue struct {
+	v interface{}
+}
+
+func (j jsonValue) Scan(value interface{}) error {
+	var raw json.RawMessage
+	if value == nil {
+		return nil
+	}
+	if err := (rawJSON{&raw}).Scan(value); err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, j.v)
+}
+
+// scoresValue converts scores to the value written to a nullable JSON column, NULL when there are none.
+func scoresValue(scores map[string]float64) interface{} {
+	if len(scores) == 0 {
+		return nil
+	}
+	b, err := json.Marshal(scores)
+	if err != nil {
+		// Only NaN and infinities fail, which questions.Definition.Score never returns.
+		return nil
+	}
+	return string(b)
+}
+
+// emptyToNull converts a string to the value written to a nullable text column, NULL for the empty string.
+func emptyToNull(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// scanner is satisfied by *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// The column lists below are the single source of truth for each table: the fields and values
+// functions next to them must return one entry per column, in the same order.
+
+var studiesTable = table{
+	name:    "studies",
+	columns: []string{"id", "name", "status", "starts_at", "ends_at", "time_zone", "hours_between_attempts", "max_attempts", "participation_days"},
+}
+
+func studyFields(study *models.Study) []interface{} {
+	return []interface{}{
+		&study.ID,
+		&study.Name,
+		&study.Status,
+		&study.StartsAt,
+		&study.EndsAt,
+		&study.TimeZone,
+		&study.HoursBetweenAttempts,
+		&study.MaxAttempts,
+		&study.ParticipationDays,
+	}
+}
+
+func studyValues(study *models.Study) []interface{} {
+	return []interface{}{
+		study.ID,
+		study.Name,
+		string(study.Status),
+		study.StartsAt,
+		study.EndsAt,
+		study.TimeZone,
+		study.HoursBetweenAttempts,
+		study.MaxAttempts,
+		study.ParticipationDays,
+	}
+}
+
+var participantsTable = table{
+	name:    "participants",
+	columns: []string{"id", "name", "study_id", "enrolled_at", "status", "time_zone", "participation_ends_at"},
+}
+
+func participantFields(participant *models.Participant) []interface{} {
+	return []interface{}{
+		&participant.ID,
+		&participant.Name,
+		nullString{&participant.StudyID},
+		&participant.EnrolledAt,
+		&participant.Status,
+		&participant.TimeZone,
+		&participant.ParticipationEndsAt,
+	}
+}
+
+func participantValues(participant *models.Participant) []interface{} {
+	return []interface{}{
+		participant.ID,
+		participant.Name,
+		emptyToNull(participant.StudyID),
+		participant.EnrolledAt,
+		string(participant.Status),
+		participant.TimeZone,
+		participant.ParticipationEndsAt,
+	}
+}
+
+var questionnairesTable = table{
+	name:    "questionnaires",
+	columns: []string{"id", "study_id", "name", "questions", "max_attempts", "hours_between_attempts", "deleted_at", "current_version"},
+	deleted: "deleted_at",
+}
+
+func questionnaireFields(questionnaire *models.Questionnaire) []interface{} {
+	return []interface{}{
+		&questionnaire.ID,
+		&questionnaire.StudyID,
+		&questionnaire.Name,
+		&questionnaire.Questions,
+		&questionnaire.MaxAttempts,
+		&questionnaire.HoursBetweenAttempts,
+		&questionnaire.DeletedAt,
+		&questionnaire.Version,
+	}
+}
+
+func questionnaireValues(questionnaire *models.Questionnaire) []interface{} {
+	return []interface{}{
+		questionnaire.ID,
+		questionnaire.StudyID,
+		questionnaire.Name,
+		questionnaire.Questions,
+		questionnaire.MaxAttempts,
+		questionnaire.HoursBetweenAttempts,
+		questionnaire.DeletedAt,
+		questionnaire.Version,
+	}
+}
+
+// questionnaireVersionsTable is keyed by questionnaire_id and version, its rows are only ever inserted.
+var questionnaireVersionsTable = table{
+	name:    "questionnaire_versions",
+	columns: []string{"questionnaire_id", "version", "questions", "max_attempts", "hours_between_attempts", "created_at"},
+}
+
+func questionnaireVersionFields(version *models.QuestionnaireVersion) []interface{} {
+	return []interface{}{
+		&version.QuestionnaireID,
+		&version.Version,
+		&version.Questions,
+		&version.MaxAttempts,
+		&version.HoursBetweenAttempts,
+		&version.CreatedAt,
+	}
+}
+
+func questionnaireVersionValues(version *models.QuestionnaireVersion) []interface{} {
+	return []interface{}{
+		version.QuestionnaireID,
+		version.Version,
+		version.Questions,
+		version.MaxAttempts,
+		version.HoursBetweenAttempts,
+		version.CreatedAt,
+	}
+}
+
+var studyProtocolsTable = table{
+	name:    "study_protocols",
+	columns: []string{"study_id", "definition", "updated_at"},
+}
+
+func studyProtocolFields(protocol *models.StudyProtocol) []interface{} {
+	return []interface{}{
+		&protocol.StudyID,
+		&protocol.Definition,
+		&protocol.UpdatedAt,
+	}
+}
+
+func studyProtocolValues(protocol *models.StudyProtocol) []interface{} {
+	return []interface{}{
+		protocol.StudyID,
+		protocol.Definition,
+		protocol.UpdatedAt,
+	}
+}
+
+var scheduledQuestionnairesTable = table{
+	name:    "scheduled_questionnaires",
+	columns: []string{"id", "questionnaire_id", "participant_id", "scheduled_at", "status", "version", "questionnaire_version"},
+	version: "version",
+}
+
+func scheduledQuestionnaireFields(scheduledQuestionnaire *models.ScheduledQuestionnaire) []interface{} {
+	return []interface{}{
+		&scheduledQuestionnaire.ID,
+		&scheduledQuestionnaire.QuestionnaireID,
+		&scheduledQuestionnaire.ParticipantID,
+		&scheduledQuestionnaire.ScheduledAt,
+		&scheduledQuestionnaire.Status,
+		&scheduledQuestionnaire.Version,
+		&scheduledQuestionnaire.QuestionnaireVersion,
+	}
+}
+
+func scheduledQuestionnaireValues(scheduledQuestionnaire *models.ScheduledQuestionnaire) []interface{} {
+	return []interface{}{
+		scheduledQuestionnaire.ID,
+		scheduledQuestionnaire.QuestionnaireID,
+		scheduledQuestionnaire.ParticipantID,
+		scheduledQuestionnaire.ScheduledAt,
+		string(scheduledQuestionnaire.Status),
+		scheduledQuestionnaire.Version,
+		scheduledQuestionnaire.QuestionnaireVersion,
+	}
+}
+
+var questionnaireResultsTable = table{
+	name:    "questionnaire_results",
+	columns: []string{"id", "answers", "questionnaire_id", "participant_id", "questionnaire_schedule_id", "completed_at", "scores", "questionnaire_version", "event_id"},
+}
+
+func questionnaireResultFields(result *models.QuestionnaireResult) []interface{} {
+	return []interface{}{
+		&result.ID,
+		rawJSON{&result.Answers},
+		&result.QuestionnaireID,
+		&result.ParticipantID,
+		&result.QuestionnaireScheduleID,
+		&result.CompletedAt,
+		jsonValue{&result.Scores},
+		&result.QuestionnaireVersion,
+		nullString{&result.EventID},
+	}
+}
+
+func questionnaireResultValues(result *models.QuestionnaireResult) []interface{} {
+	return []interface{}{
+		result.ID,
+		string(result.Answers),
+		result.QuestionnaireID,
+		result.ParticipantID,
+		result.QuestionnaireScheduleID,
+		result.CompletedAt,
+		scoresValue(result.Scores),
+		result.QuestionnaireVersion,
+		emptyToNull(result.EventID),
+	}
+}
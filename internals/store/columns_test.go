@@ -0,0 +1,160 @@
+// File: ./internals/store/columns_test.go
+
+package store
+
+import (
+	"database/sql"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"rescheduler/internals/dialect"
+	"rescheduler/internals/models"
+	"rescheduler/internals/timestamp"
+)
+
+// modelTables pairs every table with the number of fields scanned into its model.
+var modelTables = []struct {
+	table  table
+	fields int
+	values int
+}{
+	{
+		table:  studiesTable,
+		fields: len(studyFields(&models.Study{})),
+		values: len(studyValues(&models.Study{})),
+	},
+	{
+		table:  participantsTable,
+		fields: len(participantFields(&models.Participant{})),
+		values: len(participantValues(&models.Participant{})),
+	},
+	{
+		table:  questionnairesTable,
+		fields: len(questionnaireFields(&models.Questionnaire{})),
+		values: len(questionnaireValues(&models.Questionnaire{})),
+	},
+	{
+		table:  questionnaireVersionsTable,
+		fields: len(questionnaireVersionFields(&models.QuestionnaireVersion{})),
+		values: len(questionnaireVersionValues(&models.QuestionnaireVersion{})),
+	},
+	{
+		table:  studyProtocolsTable,
+		fields: len(studyProtocolFields(&models.StudyProtocol{})),
+		values: len(studyProtocolValues(&models.StudyProtocol{})),
+	},
+	{
+		table:  scheduledQuestionnairesTable,
+		fields: len(scheduledQuestionnaireFields(&models.ScheduledQuestionnaire{})),
+		values: len(scheduledQuestionnaireValues(&models.ScheduledQuestionnaire{})),
+	},
+	{
+		table:  questionnaireResultsTable,
+		fields: len(questionnaireResultFields(&models.QuestionnaireResult{})),
+		values: len(questionnaireResultValues(&models.QuestionnaireResult{})),
+	},
+}
+
+func TestColumns_MatchModelFields(t *testing.T) {
+	for _, tt := range modelTables {
+		t.Run(tt.table.name, func(t *testing.T) {
+			if tt.fields != len(tt.table.columns) {
+				t.Fatalf("Scan fields do not match the columns.\nGot: %d\nExpected: %d", tt.fields, len(tt.table.columns))
+			}
+			if tt.values != 0 && tt.values != len(tt.table.columns) {
+				t.Fatalf("Insert values do not match the columns.\nGot: %d\nExpected: %d", tt.values, len(tt.table.columns))
+			}
+		})
+	}
+}
+
+func TestTable_Queries(t *testing.T) {
+	tests := []struct {
+		name     string
+		got      string
+		expected string
+	}{
+		{
+			name:     "Select",
+			got:      participantsTable.selectFrom(),
+			expected: "SELECT id, name, study_id, enrolled_at, status, time_zone, participation_ends_at FROM participants",
+		},
+		{
+			name:     "Insert",
+			got:      scheduledQuestionnairesTable.insert(),
+			expected: "INSERT INTO scheduled_questionnaires (id, questionnaire_id, participant_id, scheduled_at, status, version, questionnaire_version) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		},
+		{
+			name:     "Update",
+			got:      scheduledQuestionnairesTable.update(),
+			expected: "UPDATE scheduled_questionnaires SET questionnaire_id = ?, participant_id = ?, scheduled_at = ?, status = ?, questionnaire_version = ?, version = version + 1 WHERE id = ? AND version = ?",
+		},
+		{
+			name:     "Update with soft delete",
+			got:      questionnairesTable.update(),
+			expected: "UPDATE questionnaires SET study_id = ?, name = ?, questions = ?, max_attempts = ?, hours_between_attempts = ?, current_version = ? WHERE id = ? AND deleted_at IS NULL",
+		},
+		{
+			name:     "Update without version",
+			got:      participantsTable.update(),
+			expected: "UPDATE participants SET name = ?, study_id = ?, enrolled_at = ?, status = ?, time_zone = ?, participation_ends_at = ? WHERE id = ?",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.expected {
+				t.Fatalf("Unexpected query.\nGot: %s\nExpected: %s", tt.got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTable_UpdateArgs(t *testing.T) {
+	schedule := &models.ScheduledQuestionnaire{ID: "s1", QuestionnaireID: "q1", QuestionnaireVersion: 2, ParticipantID: "p1", Status: models.ScheduledQuestionnairePending, Version: 3}
+
+	got := scheduledQuestionnairesTable.updateArgs(scheduledQuestionnaireValues(schedule))
+	expected := []interface{}{"q1", "p1", timestamp.TimeStamp{}, "pending", 2, "s1", int64(3)}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Unexpected arguments.\nGot: %v\nExpected: %v", got, expected)
+	}
+}
+
+// TestColumns_MatchLiveSchema compares each column list with the columns of the migrated table,
+// so a migration adding a column fails here until the model and its column list are updated.
+func TestColumns_MatchLiveSchema(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, d dialect.Dialect, db *sql.DB) {
+		for _, tt := range modelTables {
+			schema := queryColumns(t, db, "SELECT * FROM "+tt.table.name+" WHERE 1 = 0")
+			selected := queryColumns(t, db, tt.table.selectFrom()+" WHERE 1 = 0")
+
+			expected := append([]string(nil), tt.table.columns...)
+			sort.Strings(schema)
+			sort.Strings(expected)
+			if strings.Join(schema, ",") != strings.Join(expected, ",") {
+				t.Fatalf("Column list of %s does not match the schema.\nGot: %v\nExpected: %v", tt.table.name, expected, schema)
+			}
+			if strings.Join(selected, ",") != strings.Join(tt.table.columns, ",") {
+				t.Fatalf("Selected columns of %s are not in Scan order.\nGot: %v\nExpected: %v", tt.table.name, selected, tt.table.columns)
+			}
+		}
+	})
+}
+
+func queryColumns(t *testing.T, db *sql.DB, query string) []string {
+	t.Helper()
+
+	rows, err := db.Query(query)
+	if err != nil {
+		t.Fatalf("Error running %q: %v", query, err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		t.Fatalf("Error reading columns of %q: %v", query, err)
+	}
+	return columns
+}
@@ -0,0 +1,98 @@
+// Package store provides functionality to interact with the database for the rescheduler application.
+package store
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Errors returned by the stores, wrapped with %w so callers match them with errors.Is.
+// The in-memory stores in storetest/memstore return the same errors.
+var (
+	// ErrNotFound is returned when the requested record does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when an update is based on a stale version of a record:
+	// the record was changed after it was read.
+	ErrConflict = errors.New("conflicting update")
+	// ErrDuplicate is returned when a record violates a primary key or unique constraint,
+	// such as a second pending schedule for the same participant and questionnaire.
+	ErrDuplicate = errors.New("duplicate record")
+	// ErrInvalid is returned when a record fails validation before it is written.
+	ErrInvalid = errors.New("invalid record")
+	// ErrUnavailable is returned when the database cannot be reached or is temporarily refusing work.
+	// Retrying later may succeed.
+	ErrUnavailable = errors.New("database unavailable")
+)
+
+// MySQL server error numbers classified by classifyError.
+const (
+	mysqlDuplicateEntry     = 1062
+	mysqlTooManyConnections = 1040
+	mysqlServerShutdown     = 1053
+	mysqlLockWaitTimeout    = 1205
+)
+
+// classifyError wraps an error returned by the database driver in ErrDuplicate or ErrUnavailable
+// when it is one of those conditions, keeping the driver error in the chain. Other errors are returned unchanged.
+func classifyError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case isDuplicate(err):
+		return fmt.Errorf("%w: %w", ErrDuplicate, err)
+	case isUnavailable(err):
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	default:
+		return err
+	}
+}
+
+func isDuplicate(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	var pqErr *pq.Error
+	var sqliteErr *sqlite.Error
+	switch {
+	case errors.As(err, &mysqlErr):
+		return mysqlErr.Number == mysqlDuplicateEntry
+	case errors.As(err, &pqErr):
+		return pqErr.Code == "23505" // unique_violation
+	case errors.As(err, &sqliteErr):
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+	}
+	return false
+}
+
+func isUnavailable(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.Is(err, mysql.ErrInvalidConn) {
+		return true
+	}
+
+	var netErr net.Error
+	var mysqlErr *mysql.MySQLError
+	var pqErr *pq.Error
+	var sqliteErr *sqlite.Error
+	switch {
+	case errors.As(err, &netErr):
+		return true
+	case errors.As(err, &mysqlErr):
+		switch mysqlErr.Number {
+		case mysqlTooManyConnections, mysqlServerShutdown, mysqlLockWaitTimeout:
+			return true
+		}
+	case errors.As(err, &pqErr):
+		// Class 08 is connection exception, 53300 too_many_connections and 57P0x the server shutting down.
+		return pqErr.Code.Class() == "08" || pqErr.Code == "53300" || pqErr.Code.Class() == "57" && pqErr.Code != "57014"
+	case errors.As(err, &sqliteErr):
+		code := sqliteErr.Code() & 0xff
+		return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+	}
+	return false
+}
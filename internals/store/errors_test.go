@@ -0,0 +1,54 @@
+// File: ./internals/store/errors_test.go
+
+package store
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{name: "MySQL duplicate entry", err: &mysql.MySQLError{Number: 1062}, expected: ErrDuplicate},
+		{name: "MySQL too many connections", err: &mysql.MySQLError{Number: 1040}, expected: ErrUnavailable},
+		{name: "MySQL invalid connection", err: mysql.ErrInvalidConn, expected: ErrUnavailable},
+		{name: "PostgreSQL unique violation", err: &pq.Error{Code: "23505"}, expected: ErrDuplicate},
+		{name: "PostgreSQL connection failure", err: &pq.Error{Code: "08006"}, expected: ErrUnavailable},
+		{name: "PostgreSQL shutting down", err: &pq.Error{Code: "57P01"}, expected: ErrUnavailable},
+		{name: "Bad connection", err: fmt.Errorf("query: %w", driver.ErrBadConn), expected: ErrUnavailable},
+		{name: "Connection done", err: sql.ErrConnDone, expected: ErrUnavailable},
+		{name: "Network", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, expected: ErrUnavailable},
+		{name: "MySQL foreign key", err: &mysql.MySQLError{Number: 1452}},
+		{name: "PostgreSQL query canceled", err: &pq.Error{Code: "57014"}},
+		{name: "Other", err: errors.New("syntax error")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classifyError(tt.err)
+			if !errors.Is(got, tt.err) {
+				t.Fatalf("Driver error lost.\nGot: %v\nExpected: %v", got, tt.err)
+			}
+			for _, sentinel := range []error{ErrDuplicate, ErrUnavailable} {
+				if errors.Is(got, sentinel) != (sentinel == tt.expected) {
+					t.Fatalf("Unexpected classification.\nGot: %v\nExpected: %v", got, tt.expected)
+				}
+			}
+		})
+	}
+
+	if classifyError(nil) != nil {
+		t.Fatalf("Expected nil for a nil error")
+	}
+}
@@ -0,0 +1,59 @@
+// Package store provides functionality to interact with the database for the rescheduler application.
+package store
+
+import (
+	"rescheduler/internals/clock"
+	"rescheduler/internals/dialect"
+	"rescheduler/internals/idgen"
+)
+
+// Option configures the optional collaborators of a store.
+type Option func(*Options)
+
+// Options holds the collaborators of a store after all Option values have been applied.
+type Options struct {
+	// Clock is used for timestamps the store fills in itself.
+	Clock clock.Clock
+	// IDs is used for records created without an ID.
+	IDs idgen.Generator
+	// Dialect is the database the queries are written for.
+	Dialect dialect.Dialect
+}
+
+// WithClock sets the clock used for timestamps the store fills in itself.
+// The default is clock.System.
+func WithClock(c clock.Clock) Option {
+	return func(o *Options) {
+		o.Clock = c
+	}
+}
+
+// WithIDGenerator sets the generator used for records created without an ID.
+// The default is idgen.UUID.
+func WithIDGenerator(g idgen.Generator) Option {
+	return func(o *Options) {
+		o.IDs = g
+	}
+}
+
+// WithDialect sets the database the queries are written for.
+// The default is dialect.MySQL.
+func WithDialect(d dialect.Dialect) Option {
+	return func(o *Options) {
+		o.Dialect = d
+	}
+}
+
+// NewOptions applies opts over the defaults.
+// It is exported so that other implementations of the store interfaces, such as memstore, honour the same options.
+func NewOptions(opts ...Option) Options {
+	o := Options{
+		Clock:   clock.System{},
+		IDs:     idgen.UUID{},
+		Dialect: dialect.MySQL,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
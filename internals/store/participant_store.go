@@ -4,43 +4,207 @@ package store
 import (
 	"database/sql"
 	"fmt"
+	"strings"
+	"time"
+	// The time zone database is embedded so that participant time zones are validated the same way
+	// on hosts without zoneinfo files, such as the Lambda runtime.
+	_ "time/tzdata"
 
 	"rescheduler/internals/models"
+	"rescheduler/internals/timestamp"
 )
 
 // ParticipantStoreInterface defines the methods expected for participant-related database operations.
+//
+// A participant is enrolled active and moves between statuses with Pause, Resume and Withdraw:
+//
+//	active ⇄ paused, active or paused → withdrawn
+//
+// ScheduledQuestionnaireStoreInterface.Complete moves an active participant to completed when their
+// participation ends. Withdrawn and completed are final. A change that is not allowed from the current status returns ErrConflict.
 type ParticipantStoreInterface interface {
 	FindParticipantByID(participantID string) (*models.Participant, error)
+	Enroll(participant *models.Participant) error
+	Pause(participantID string) error
+	Resume(participantID string) error
+	Withdraw(participantID string) error
 }
 
 // ParticipantStore implements ParticipantStoreInterface and is responsible for handling participant-related database operations.
 type ParticipantStore struct {
-	db *sql.DB
+	db   *sql.DB
+	opts Options
 }
 
-// NewParticipantStore creates a new ParticipantStore instance with the given SQL database connection.
-func NewParticipantStore(db *sql.DB) *ParticipantStore {
-	return &ParticipantStore{db: db}
+// NewParticipantStore creates a new ParticipantStore instance with the given SQL database connection and options.
+func NewParticipantStore(db *sql.DB, opts ...Option) *ParticipantStore {
+	return &ParticipantStore{db: db, opts: NewOptions(opts...)}
+}
+
+// querier is satisfied by *sql.DB and *sql.Tx, so the same queries run inside and outside a transaction.
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// ValidateParticipant checks a participant before it is enrolled, returning an error wrapping ErrInvalid
+// that lists every problem found. It is exported so that other implementations of ParticipantStoreInterface
+// validate the same way.
+//
+// A participant needs a study and a time zone known to the time zone database; "UTC" is valid.
+// The end of their participation, when set, is after their enrollment.
+func ValidateParticipant(participant *models.Participant) error {
+	var problems []string
+	if strings.TrimSpace(participant.StudyID) == "" {
+		problems = append(problems, "study ID is required")
+	}
+	if !knownTimeZone(participant.TimeZone) {
+		problems = append(problems, fmt.Sprintf("unknown time zone %q", participant.TimeZone))
+	}
+	if participant.ParticipationEndsAt.Valid && !participant.ParticipationEndsAt.TimeStamp.After(participant.EnrolledAt.Time) {
+		problems = append(problems, "participation must end after the enrollment")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: participant %s: %s", ErrInvalid, participant.ID, strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// knownTimeZone reports whether name is a zone of the time zone database.
+// LoadLocation also accepts "" and "Local", which name no zone in particular.
+func knownTimeZone(name string) bool {
+	_, err := time.LoadLocation(name)
+	return err == nil && name != "" && name != "Local"
 }
 
 // FindParticipantByID retrieves a participant by their ID.
 // It returns a Participant instance if found, or nil if no participant is found.
 // An error is returned if there is an issue with the database query.
-// So far this function is unused but I creqted it for the sake of consistency in maintaining the store concept.
 func (ps *ParticipantStore) FindParticipantByID(participantID string) (*models.Participant, error) {
-	query := "SELECT * FROM participants WHERE id = ?"
-	row := ps.db.QueryRow(query, participantID)
+	return ps.find(ps.db, participantID)
+}
+
+func (ps *ParticipantStore) find(q querier, participantID string) (*models.Participant, error) {
+	query := participantsTable.selectFrom() + " WHERE id = ?"
+	row := q.QueryRow(ps.opts.Dialect.Rebind(query), participantID)
 
 	var participant models.Participant
-	err := row.Scan(
-		&participant.ID,
-		&participant.Name,
-	)
+	err := row.Scan(participantFields(&participant)...)
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("participant not found with ID: %s", participantID)
+		return nil, fmt.Errorf("participant %w with ID: %s", ErrNotFound, participantID)
 	} else if err != nil {
-		return nil, err
+		return nil, classifyError(err)
 	}
 
 	return &participant, nil
 }
+
+// Enroll inserts a new participant into the "participants" table, active from the time of enrollment.
+//
+// Parameters:
+//   - participant: A pointer to a Participant struct with the study, name and time zone of the participant.
+//
+// Returns:
+//   - error: ErrInvalid when the participant fails ValidateParticipant, ErrDuplicate when the ID is taken,
+//     or another error of the database operation.
+//
+// If the participant has no ID, one is generated with the store's ID generator. An empty time zone is "UTC"
+// and a zero EnrolledAt is the current time of the store's clock. The status is set to active.
+// All of them are set on the struct.
+func (ps *ParticipantStore) Enroll(participant *models.Participant) error {
+	PrepareEnrollment(participant, ps.opts)
+	if err := ValidateParticipant(participant); err != nil {
+		return err
+	}
+
+	_, err := ps.db.Exec(ps.opts.Dialect.Rebind(participantsTable.insert()), participantValues(participant)...)
+	return classifyError(err)
+}
+
+// PrepareEnrollment fills in the defaults of a participant about to be enrolled, as described on Enroll.
+// It is exported so that other implementations of ParticipantStoreInterface enroll the same way.
+func PrepareEnrollment(participant *models.Participant, opts Options) {
+	if participant.ID == "" {
+		participant.ID = opts.IDs.NewID()
+	}
+	if participant.TimeZone == "" {
+		participant.TimeZone = "UTC"
+	}
+	if participant.EnrolledAt.IsZero() {
+		participant.EnrolledAt = timestamp.TimeStamp{Time: opts.Clock.Now()}
+	}
+	participant.Status = models.ParticipantActive
+}
+
+// Pause suspends an active participant. Their pending schedules are kept.
+//
+// Returns:
+//   - error: ErrNotFound for an unknown participant, ErrConflict when the participant is not active,
+//     or another error of the database operation.
+func (ps *ParticipantStore) Pause(participantID string) error {
+	return ps.setStatus(ps.db, participantID, models.ParticipantPaused, models.ParticipantActive)
+}
+
+// Resume reactivates a paused participant.
+//
+// Returns:
+//   - error: ErrNotFound for an unknown participant, ErrConflict when the participant is not paused,
+//     or another error of the database operation.
+func (ps *ParticipantStore) Resume(participantID string) error {
+	return ps.setStatus(ps.db, participantID, models.ParticipantActive, models.ParticipantPaused)
+}
+
+// Withdraw withdraws an active or paused participant from their study and cancels all of their pending
+// scheduled questionnaires, in a single transaction. Cancelling a schedule increments its version, so a completion
+// racing the withdrawal fails with ErrConflict rather than scheduling a follow-up.
+//
+// Returns:
+//   - error: ErrNotFound for an unknown participant, ErrConflict when the participant already withdrew
+//     or completed the study, or another error of the database operation.
+func (ps *ParticipantStore) Withdraw(participantID string) error {
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return classifyError(err)
+	}
+	defer tx.Rollback()
+
+	if err := ps.setStatus(tx, participantID, models.ParticipantWithdrawn, models.ParticipantActive, models.ParticipantPaused); err != nil {
+		return err
+	}
+
+	query := "UPDATE " + scheduledQuestionnairesTable.name + " SET status = ?, version = version + 1 WHERE participant_id = ? AND status = ?"
+	if _, err := tx.Exec(ps.opts.Dialect.Rebind(query), models.ScheduledQuestionnaireCancelled, participantID, models.ScheduledQuestionnairePending); err != nil {
+		return classifyError(err)
+	}
+
+	return classifyError(tx.Commit())
+}
+
+// setStatus moves a participant to the status to, provided their current status is one of from.
+// The condition is part of the UPDATE, so two concurrent changes cannot both apply.
+func (ps *ParticipantStore) setStatus(q querier, participantID string, to models.ParticipantStatus, from ...models.ParticipantStatus) error {
+	query := "UPDATE " + participantsTable.name + " SET status = ? WHERE id = ? AND status IN (" + placeholders(len(from)) + ")"
+	args := []interface{}{string(to), participantID}
+	for _, status := range from {
+		args = append(args, string(status))
+	}
+
+	res, err := q.Exec(ps.opts.Dialect.Rebind(query), args...)
+	if err != nil {
+		return classifyError(err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return classifyError(err)
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	participant, err := ps.find(q, participantID)
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: participant %s is %s and cannot become %s", ErrConflict, participantID, participant.Status, to)
+}
@@ -0,0 +1,133 @@
+// File: ./internals/store/participant_store_test.go
+
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"rescheduler/internals/clock"
+	"rescheduler/internals/dialect"
+	"rescheduler/internals/models"
+	"rescheduler/internals/timestamp"
+)
+
+func TestValidateParticipant(t *testing.T) {
+	tests := []struct {
+		name        string
+		participant models.Participant
+		wantErr     bool
+	}{
+		{name: "Valid", participant: models.Participant{StudyID: "Study5", TimeZone: "Europe/London"}},
+		{name: "UTC", participant: models.Participant{StudyID: "Study5", TimeZone: "UTC"}},
+		{name: "No study", participant: models.Participant{TimeZone: "UTC"}, wantErr: true},
+		{name: "Unknown time zone", participant: models.Participant{StudyID: "Study5", TimeZone: "Mars/Olympus"}, wantErr: true},
+		{name: "Local time zone", participant: models.Participant{StudyID: "Study5", TimeZone: "Local"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateParticipant(&tt.participant)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("Unexpected validation result.\nGot: %v\nExpected an error: %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalid) {
+				t.Fatalf("Expected ErrInvalid.\nGot: %v", err)
+			}
+		})
+	}
+}
+
+func TestParticipantStore_Enrollment(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, d dialect.Dialect, db *sql.DB) {
+		seed(t, d, db)
+
+		now := time.Date(2023, 12, 5, 9, 30, 0, 0, time.UTC)
+		participants := NewParticipantStore(db, WithDialect(d), WithClock(clock.NewFake(now)))
+		schedules := NewScheduledQuestionnaireStore(db, WithDialect(d))
+
+		// Participants created before enrollment existed have no study and are active.
+		legacy, err := participants.FindParticipantByID("p1")
+		if err != nil || legacy.StudyID != "" || legacy.Status != models.ParticipantActive || legacy.TimeZone != "UTC" {
+			t.Fatalf("Unexpected participant created before enrollment.\nGot: %+v, %v", legacy, err)
+		}
+
+		endsAt := timestamp.NewNullTimeStamp(timestamp.TimeStamp{Time: now.AddDate(0, 0, 84)})
+		participant := &models.Participant{ID: "p2", Name: "Grace", StudyID: "Study5", TimeZone: "America/New_York", ParticipationEndsAt: endsAt}
+		if err := participants.Enroll(participant); err != nil {
+			t.Fatalf("Error enrolling participant: %v", err)
+		}
+		found, err := participants.FindParticipantByID("p2")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if found.StudyID != "Study5" || found.Status != models.ParticipantActive || found.TimeZone != "America/New_York" || !found.EnrolledAt.Equal(now) ||
+			!found.ParticipationEndsAt.Valid || !found.ParticipationEndsAt.TimeStamp.Equal(endsAt.TimeStamp.Time) {
+			t.Fatalf("Unexpected enrolled participant.\nGot: %+v", found)
+		}
+		if err := participants.Enroll(&models.Participant{ID: "p2", StudyID: "Study5"}); !errors.Is(err, ErrDuplicate) {
+			t.Fatalf("Expected a duplicate enrolling twice.\nGot: %v", err)
+		}
+		if err := participants.Enroll(&models.Participant{StudyID: "Study5", TimeZone: "Nowhere"}); !errors.Is(err, ErrInvalid) {
+			t.Fatalf("Expected an invalid participant.\nGot: %v", err)
+		}
+
+		pending := &models.ScheduledQuestionnaire{QuestionnaireID: "q1", ParticipantID: "p2", ScheduledAt: timestamp.TimeStamp{Time: now}, Status: models.ScheduledQuestionnairePending}
+		other := &models.ScheduledQuestionnaire{QuestionnaireID: "q1", ParticipantID: "p1", ScheduledAt: timestamp.TimeStamp{Time: now}, Status: models.ScheduledQuestionnairePending}
+		for _, schedule := range []*models.ScheduledQuestionnaire{pending, other} {
+			if err := schedules.Create(schedule); err != nil {
+				t.Fatalf("Error creating schedule: %v", err)
+			}
+		}
+
+		steps := []struct {
+			name     string
+			change   func(participantID string) error
+			expected error
+			status   models.ParticipantStatus
+		}{
+			{name: "Resume active", change: participants.Resume, expected: ErrConflict, status: models.ParticipantActive},
+			{name: "Pause", change: participants.Pause, status: models.ParticipantPaused},
+			{name: "Pause paused", change: participants.Pause, expected: ErrConflict, status: models.ParticipantPaused},
+			{name: "Resume", change: participants.Resume, status: models.ParticipantActive},
+			{name: "Pause again", change: participants.Pause, status: models.ParticipantPaused},
+			{name: "Withdraw paused", change: participants.Withdraw, status: models.ParticipantWithdrawn},
+			{name: "Withdraw withdrawn", change: participants.Withdraw, expected: ErrConflict, status: models.ParticipantWithdrawn},
+			{name: "Resume withdrawn", change: participants.Resume, expected: ErrConflict, status: models.ParticipantWithdrawn},
+		}
+		for _, step := range steps {
+			if err := step.change("p2"); !errors.Is(err, step.expected) {
+				t.Fatalf("%s: unexpected error.\nGot: %v\nExpected: %v", step.name, err, step.expected)
+			}
+			found, err := participants.FindParticipantByID("p2")
+			if err != nil || found.Status != step.status {
+				t.Fatalf("%s: unexpected status.\nGot: %+v, %v\nExpected: %s", step.name, found, err, step.status)
+			}
+		}
+		if err := participants.Pause("unknown"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Expected not found pausing an unknown participant.\nGot: %v", err)
+		}
+
+		// Withdrawal cancelled the pending schedule of p2 only.
+		if _, err := schedules.FindScheduledQuestionnaireByQuestionnaireIDAndUserID("q1", "p2"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Expected no pending schedule after withdrawal.\nGot: %v", err)
+		}
+		var status string
+		var version int64
+		if err := db.QueryRow(d.Rebind("SELECT status, version FROM scheduled_questionnaires WHERE id = ?"), pending.ID).Scan(&status, &version); err != nil {
+			t.Fatalf("Error reading cancelled schedule: %v", err)
+		}
+		if status != models.ScheduledQuestionnaireCancelled || version != 2 {
+			t.Fatalf("Unexpected cancelled schedule.\nGot: %s, version %d", status, version)
+		}
+		if _, err := schedules.FindScheduledQuestionnaireByQuestionnaireIDAndUserID("q1", "p1"); err != nil {
+			t.Fatalf("Expected the schedule of another participant to stay pending: %v", err)
+		}
+		listed, err := schedules.ListByParticipant("p2")
+		if err != nil || len(listed) != 1 || listed[0].ID != pending.ID || listed[0].Status != models.ScheduledQuestionnaireCancelled {
+			t.Fatalf("Unexpected schedules of the withdrawn participant.\nGot: %+v, %v", listed, err)
+		}
+	})
+}
@@ -2,24 +2,53 @@
 package store
 
 import (
+	"bytes"
 	"database/sql"
+	"encoding/json"
+	"fmt"
+
 	"rescheduler/internals/models"
-	"time"
+	"rescheduler/internals/timestamp"
 )
 
+// MaxAnswersSize is the largest answers payload, in bytes, a questionnaire result may store.
+const MaxAnswersSize = 64 << 10
+
 // QuestionnaireResultStoreInterface defines the methods expected for questionnaire result-related database operations.
 type QuestionnaireResultStoreInterface interface {
+	FindByEventID(eventID string) (*models.QuestionnaireResult, error)
 	Create(result *models.QuestionnaireResult) error
 }
 
 // QuestionnaireResultStore implements QuestionnaireResultStoreInterface and is responsible for handling questionnaire result-related database operations.
 type QuestionnaireResultStore struct {
-	db *sql.DB
+	db   *sql.DB
+	opts Options
+}
+
+// NewQuestionnaireResultStore creates a new QuestionnaireResultStore instance with the given SQL database connection and options.
+func NewQuestionnaireResultStore(db *sql.DB, opts ...Option) *QuestionnaireResultStore {
+	return &QuestionnaireResultStore{db: db, opts: NewOptions(opts...)}
 }
 
-// NewQuestionnaireResultStore creates a new QuestionnaireResultStore instance with the given SQL database connection.
-func NewQuestionnaireResultStore(db *sql.DB) *QuestionnaireResultStore {
-	return &QuestionnaireResultStore{db: db}
+// FindByEventID retrieves the result recording the completion event with the given ID.
+//
+// Returns:
+//   - *models.QuestionnaireResult: The result found.
+//   - error: ErrNotFound when no result records the event, or another error of the database query.
+func (qrs *QuestionnaireResultStore) FindByEventID(eventID string) (*models.QuestionnaireResult, error) {
+	query := questionnaireResultsTable.selectFrom() + " WHERE event_id = ?"
+	row := qrs.db.QueryRow(qrs.opts.Dialect.Rebind(query), eventID)
+
+	var result models.QuestionnaireResult
+	err := row.Scan(questionnaireResultFields(&result)...)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("questionnaire result %w with Event ID: %s", ErrNotFound, eventID)
+	} else if err != nil {
+		return nil, classifyError(err)
+	}
+
+	return &result, nil
 }
 
 // Create inserts a new questionnaire result record into the database.
@@ -31,20 +60,59 @@ func NewQuestionnaireResultStore(db *sql.DB) *QuestionnaireResultStore {
 //   - result: A pointer to a QuestionnaireResult struct containing the data to be inserted.
 //
 // Returns:
-//   - error: An error indicating the success or failure of the database operation.
+//   - error: ErrInvalid when the answers fail ValidateAnswers, ErrDuplicate when the ID is taken or another
+//     result records the same event, or another error of the database operation.
 //
 // Database Table Schema:
 //   - Table Name: questionnaire_results
 //   - Columns:
 //   - id (string): Unique identifier for the questionnaire result.
-//   - answers (string): JSON-encoded answers provided by the participant.
+//   - answers (string): JSON object of the answers provided by the participant, written as received.
 //   - questionnaire_id (string): Identifier of the associated questionnaire.
 //   - participant_id (string): Identifier of the participant who completed the questionnaire.
 //   - questionnaire_schedule_id (string): Identifier of the associated scheduled questionnaire.
-//   - completed_at (string): Timestamp indicating when the questionnaire was completed in RFC3339 format.
+//   - completed_at (string): Timestamp indicating when the questionnaire was completed, written as timestamp.DBLayout in UTC.
+//   - event_id (string): ID of the completion event recorded, NULL when the event had none. Unique.
+//
+// If the result has no ID, one is generated with the store's ID generator. If it has no completion time,
+// the current time of the store's clock is used. Both are set on the struct.
 func (qrs *QuestionnaireResultStore) Create(result *models.QuestionnaireResult) error {
-	query := "INSERT INTO questionnaire_results (id, answers, questionnaire_id, participant_id, questionnaire_schedule_id, completed_at) VALUES (?, ?, ?, ?, ?, ?)"
+	return createResult(qrs.db, qrs.opts, result)
+}
+
+// createResult runs QuestionnaireResultStore.Create with q, so that ScheduledQuestionnaireStore.Complete
+// creates the result of a completion in its transaction.
+func createResult(q querier, opts Options, result *models.QuestionnaireResult) error {
+	if result.ID == "" {
+		result.ID = opts.IDs.NewID()
+	}
+	if result.CompletedAt.IsZero() {
+		result.CompletedAt = timestamp.TimeStamp{Time: opts.Clock.Now()}
+	}
+	if err := ValidateAnswers(result.Answers); err != nil {
+		return err
+	}
 
-	_, err := qrs.db.Exec(query, result.ID, result.Answers, result.QuestionnaireID, result.ParticipantID, result.QuestionnaireScheduleID, result.CompletedAt.Format(time.RFC3339))
-	return err
+	_, err := q.Exec(opts.Dialect.Rebind(questionnaireResultsTable.insert()), questionnaireResultValues(result)...)
+	return classifyError(err)
+}
+
+// ValidateAnswers checks the answers of a questionnaire result before it is written, returning an error wrapping
+// ErrInvalid that says what is wrong. It is exported so that callers can reject answers before acting on them,
+// and other implementations of QuestionnaireResultStoreInterface validate the same way.
+//
+// Answers must be a JSON object of at most MaxAnswersSize bytes.
+func ValidateAnswers(answers json.RawMessage) error {
+	trimmed := bytes.TrimSpace(answers)
+	switch {
+	case len(trimmed) == 0:
+		return fmt.Errorf("%w: answers are required", ErrInvalid)
+	case len(answers) > MaxAnswersSize:
+		return fmt.Errorf("%w: answers are %d bytes, more than the maximum of %d", ErrInvalid, len(answers), MaxAnswersSize)
+	case !json.Valid(trimmed):
+		return fmt.Errorf("%w: answers are not valid JSON", ErrInvalid)
+	case trimmed[0] != '{':
+		return fmt.Errorf("%w: answers must be a JSON object", ErrInvalid)
+	}
+	return nil
 }
@@ -0,0 +1,42 @@
+// File: ./internals/store/questionnaire_results_store_test.go
+
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateAnswers(t *testing.T) {
+	tests := []struct {
+		name    string
+		answers json.RawMessage
+		wantErr bool
+	}{
+		{name: "Object", answers: json.RawMessage(`{"mood": 4, "notes": "tired"}`)},
+		{name: "Empty object", answers: json.RawMessage(`{}`)},
+		{name: "Surrounding whitespace", answers: json.RawMessage(" {\"mood\": 4}\n")},
+		{name: "Largest allowed", answers: json.RawMessage(`{"notes": "` + strings.Repeat("a", MaxAnswersSize-13) + `"}`)},
+		{name: "Missing", answers: nil, wantErr: true},
+		{name: "Blank", answers: json.RawMessage("  "), wantErr: true},
+		{name: "Not JSON", answers: json.RawMessage(`mood=4`), wantErr: true},
+		{name: "Truncated", answers: json.RawMessage(`{"mood": 4`), wantErr: true},
+		{name: "Array", answers: json.RawMessage(`[4]`), wantErr: true},
+		{name: "Null", answers: json.RawMessage(`null`), wantErr: true},
+		{name: "Too large", answers: json.RawMessage(`{"notes": "` + strings.Repeat("a", MaxAnswersSize) + `"}`), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAnswers(tt.answers)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("Unexpected validation result.\nGot: %v\nExpected an error: %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalid) {
+				t.Fatalf("Expected ErrInvalid.\nGot: %v", err)
+			}
+		})
+	}
+}
@@ -3,25 +3,200 @@ package store
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"reflect"
+	"strings"
 
 	"rescheduler/internals/models"
+	"rescheduler/internals/questions"
+	"rescheduler/internals/timestamp"
 )
 
 // QuestionnaireStoreInterface defines the methods expected for questionnaire-related database operations.
+// Deleted questionnaires are never returned or updated, their versions are still found.
 type QuestionnaireStoreInterface interface {
 	FindQuestionnaireByIDAndStudyID(questionnaireID, studyID string) (*models.Questionnaire, error)
 	FindQuestionnaireByID(questionnaireID string) (*models.Questionnaire, error)
+	FindVersion(questionnaireID string, version int) (*models.QuestionnaireVersion, error)
+	ListByStudy(studyID string, filter QuestionnaireFilter) (*QuestionnairePage, error)
+	Create(questionnaire *models.Questionnaire) error
+	Update(questionnaire *models.Questionnaire) error
+	Delete(questionnaireID string) error
+}
+
+// Page sizes of ListByStudy.
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+// QuestionnaireFilter selects and paginates the questionnaires listed by ListByStudy.
+type QuestionnaireFilter struct {
+	// Name keeps only the questionnaires whose name contains it, ignoring case. Empty keeps all.
+	Name string
+	// Cursor continues a listing after the page that returned it as NextCursor. Empty starts with the first page.
+	Cursor string
+	// Limit is the maximum number of questionnaires on a page: DefaultPageSize when not positive, at most MaxPageSize.
+	Limit int
+}
+
+// PageSize returns the number of questionnaires on a full page, Limit bounded by the page size constants.
+func (f QuestionnaireFilter) PageSize() int {
+	switch {
+	case f.Limit <= 0:
+		return DefaultPageSize
+	case f.Limit > MaxPageSize:
+		return MaxPageSize
+	default:
+		return f.Limit
+	}
+}
+
+// QuestionnairePage is one page of questionnaires, ordered by ID.
+type QuestionnairePage struct {
+	Questionnaires []models.Questionnaire
+	// NextCursor is the Cursor of the next page, empty on the last page.
+	// It is the ID of the last questionnaire on this page, but callers should treat it as opaque.
+	NextCursor string
+}
+
+// maxQuestionnaireName is the length of the name column.
+const maxQuestionnaireName = 128
+
+// ValidateQuestionnaire checks a questionnaire before it is written, returning an error wrapping ErrInvalid
+// that lists every problem found. It is exported so that other implementations of QuestionnaireStoreInterface
+// validate the same way.
+//
+// A questionnaire needs a study and a name of at most 128 characters, and its questions must be a definition
+// questions.Parse accepts, whose rules do not schedule the questionnaire itself: they reschedule it instead.
+// MaxAttempts, when set, is at least 1: NULL means the questionnaire is rescheduled until the participant is done.
+// HoursBetweenAttempts is at least 1, a follow-up is never scheduled at the time of the completion.
+func ValidateQuestionnaire(questionnaire *models.Questionnaire) error {
+	var problems []string
+	if strings.TrimSpace(questionnaire.StudyID) == "" {
+		problems = append(problems, "study ID is required")
+	}
+	if strings.TrimSpace(questionnaire.Name) == "" {
+		problems = append(problems, "name is required")
+	} else if len(questionnaire.Name) > maxQuestionnaireName {
+		problems = append(problems, fmt.Sprintf("name is longer than %d characters", maxQuestionnaireName))
+	}
+	if definition, err := questions.Parse(questionnaire.Questions); err != nil {
+		problems = append(problems, err.Error())
+	} else {
+		for i, rule := range definition.Rules {
+			if rule.Then.Schedule != "" && rule.Then.Schedule == questionnaire.ID {
+				problems = append(problems, fmt.Sprintf("rule #%d schedules the questionnaire itself", i+1))
+			}
+		}
+	}
+	if questionnaire.MaxAttempts.Valid && questionnaire.MaxAttempts.Int64 < 1 {
+		problems = append(problems, fmt.Sprintf("max attempts must be at least 1, got %d", questionnaire.MaxAttempts.Int64))
+	}
+	if questionnaire.HoursBetweenAttempts < 1 {
+		problems = append(problems, fmt.Sprintf("hours between attempts must be at least 1, got %d", questionnaire.HoursBetweenAttempts))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: questionnaire %s: %s", ErrInvalid, questionnaire.ID, strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// ValidateQuestionnaireLinks checks the questionnaires the rules of a valid questionnaire schedule, returning
+// an error wrapping ErrInvalid that lists every one that does not exist, was deleted or belongs to another study.
+// It is exported so that other implementations of QuestionnaireStoreInterface validate the same way.
+//
+// Parameters:
+//   - questionnaire: A questionnaire that passed ValidateQuestionnaire.
+//   - find: Looks a questionnaire up like QuestionnaireStoreInterface.FindQuestionnaireByID.
+//
+// Returns:
+//   - error: ErrInvalid for a rule scheduling a questionnaire it cannot, or the error of find other than ErrNotFound.
+func ValidateQuestionnaireLinks(questionnaire *models.Questionnaire, find func(questionnaireID string) (*models.Questionnaire, error)) error {
+	definition, err := questions.Parse(questionnaire.Questions)
+	if err != nil {
+		return fmt.Errorf("%w: questionnaire %s: %v", ErrInvalid, questionnaire.ID, err)
+	}
+	var scheduled []string
+	for _, rule := range definition.Rules {
+		if rule.Then.Schedule != "" {
+			scheduled = append(scheduled, rule.Then.Schedule)
+		}
+	}
+	return validateLinks("questionnaire "+questionnaire.ID, questionnaire.StudyID, scheduled, find)
+}
+
+// validateLinks checks that every questionnaire in questionnaireIDs exists, is not deleted and belongs to the study,
+// returning an error wrapping ErrInvalid that names the owner of the links and lists every one that does not.
+func validateLinks(owner, studyID string, questionnaireIDs []string, find func(questionnaireID string) (*models.Questionnaire, error)) error {
+	var problems []string
+	checked := make(map[string]bool, len(questionnaireIDs))
+	for _, questionnaireID := range questionnaireIDs {
+		if checked[questionnaireID] {
+			continue
+		}
+		checked[questionnaireID] = true
+
+		linked, err := find(questionnaireID)
+		if errors.Is(err, ErrNotFound) {
+			problems = append(problems, fmt.Sprintf("questionnaire %s does not exist or was deleted", questionnaireID))
+		} else if err != nil {
+			return err
+		} else if linked.StudyID != studyID {
+			problems = append(problems, fmt.Sprintf("questionnaire %s belongs to study %s", questionnaireID, linked.StudyID))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s: %s", ErrInvalid, owner, strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// VersionOf returns the version of the settings of a questionnaire numbered questionnaire.Version, created at createdAt.
+func VersionOf(questionnaire *models.Questionnaire, createdAt timestamp.TimeStamp) models.QuestionnaireVersion {
+	return models.QuestionnaireVersion{
+		QuestionnaireID:      questionnaire.ID,
+		Version:              questionnaire.Version,
+		Questions:            questionnaire.Questions,
+		MaxAttempts:          questionnaire.MaxAttempts,
+		HoursBetweenAttempts: questionnaire.HoursBetweenAttempts,
+		CreatedAt:            createdAt,
+	}
+}
+
+// ChangesVersion reports whether writing questionnaire over its current version changes its questions, rules
+// or attempts, so that Update must create a new version. It is exported so that other implementations
+// of QuestionnaireStoreInterface version the same way.
+//
+// Questions are compared as JSON values: the JSON columns of MySQL and PostgreSQL do not keep the text
+// of a definition as written, so the same definition read back must not count as a change.
+func ChangesVersion(current *models.QuestionnaireVersion, questionnaire *models.Questionnaire) bool {
+	return current.MaxAttempts != questionnaire.MaxAttempts ||
+		current.HoursBetweenAttempts != questionnaire.HoursBetweenAttempts ||
+		!sameJSON(current.Questions, questionnaire.Questions)
+}
+
+func sameJSON(a, b string) bool {
+	var va, vb interface{}
+	if json.Unmarshal([]byte(a), &va) != nil || json.Unmarshal([]byte(b), &vb) != nil {
+		return a == b
+	}
+	return reflect.DeepEqual(va, vb)
 }
 
 // QuestionnaireStore implements QuestionnaireStoreInterface and is responsible for handling questionnaire-related database operations.
 type QuestionnaireStore struct {
-	db *sql.DB
+	db   *sql.DB
+	opts Options
 }
 
-// NewQuestionnaireStore creates a new QuestionnaireStore instance with the given SQL database connection.
-func NewQuestionnaireStore(db *sql.DB) *QuestionnaireStore {
-	return &QuestionnaireStore{db: db}
+// NewQuestionnaireStore creates a new QuestionnaireStore instance with the given SQL database connection and options.
+func NewQuestionnaireStore(db *sql.DB, opts ...Option) *QuestionnaireStore {
+	return &QuestionnaireStore{db: db, opts: NewOptions(opts...)}
 }
 
 // FindQuestionnaireByID retrieves a questionnaire by its ID and Study ID.
@@ -29,23 +204,15 @@ func NewQuestionnaireStore(db *sql.DB) *QuestionnaireStore {
 // An error is returned if there is an issue with the database query.
 // The use of studyID seemed like a contraint in the task as these details come from the event data. This shouldn't be the case as the questionnaireID should be unique therefore the key.
 func (qs *QuestionnaireStore) FindQuestionnaireByIDAndStudyID(questionnaireID, studyID string) (*models.Questionnaire, error) {
-	query := "SELECT * FROM questionnaires WHERE id = ? AND study_id = ?"
-	row := qs.db.QueryRow(query, questionnaireID, studyID)
+	query := questionnairesTable.selectFrom() + " WHERE id = ? AND study_id = ?" + questionnairesTable.notDeleted()
+	row := qs.db.QueryRow(qs.opts.Dialect.Rebind(query), questionnaireID, studyID)
 
 	var questionnaire models.Questionnaire
-	err := row.Scan(
-		&questionnaire.ID,
-		&questionnaire.StudyID,
-		&questionnaire.Name,
-		&questionnaire.Questions,
-		&questionnaire.MaxAttempts,
-		&questionnaire.HoursBetweenAttempts,
-	)
+	err := row.Scan(questionnaireFields(&questionnaire)...)
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("questionnaire not found with ID: %s and Study ID: %s", questionnaireID, studyID)
+		return nil, fmt.Errorf("questionnaire %w with ID: %s and Study ID: %s", ErrNotFound, questionnaireID, studyID)
 	} else if err != nil {
-
-		return nil, err
+		return nil, classifyError(err)
 	}
 
 	return &questionnaire, nil
@@ -56,23 +223,265 @@ func (qs *QuestionnaireStore) FindQuestionnaireByIDAndStudyID(questionnaireID, s
 // An error is returned if there is an issue with the database query.
 // This version skips studyID as it doesn't identify the questionnaire
 func (qs *QuestionnaireStore) FindQuestionnaireByID(questionnaireID string) (*models.Questionnaire, error) {
-	query := "SELECT * FROM questionnaires WHERE id = ?"
-	row := qs.db.QueryRow(query, questionnaireID)
+	query := questionnairesTable.selectFrom() + " WHERE id = ?" + questionnairesTable.notDeleted()
+	row := qs.db.QueryRow(qs.opts.Dialect.Rebind(query), questionnaireID)
 
 	var questionnaire models.Questionnaire
-	err := row.Scan(
-		&questionnaire.ID,
-		&questionnaire.StudyID,
-		&questionnaire.Name,
-		&questionnaire.Questions,
-		&questionnaire.MaxAttempts,
-		&questionnaire.HoursBetweenAttempts,
-	)
+	err := row.Scan(questionnaireFields(&questionnaire)...)
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("questionnaire not found with ID: %s", questionnaireID)
+		return nil, fmt.Errorf("questionnaire %w with ID: %s", ErrNotFound, questionnaireID)
 	} else if err != nil {
-		return nil, fmt.Errorf("Something else is wrong %s", err)
+		return nil, classifyError(err)
 	}
 
 	return &questionnaire, nil
 }
+
+// FindVersion retrieves a version of a questionnaire, even of a deleted one,
+// so the results and schedules referencing it can always be read against it.
+//
+// Returns:
+//   - *models.QuestionnaireVersion: The version found.
+//   - error: ErrNotFound when the questionnaire has no such version, or another error of the database query.
+func (qs *QuestionnaireStore) FindVersion(questionnaireID string, version int) (*models.QuestionnaireVersion, error) {
+	query := questionnaireVersionsTable.selectFrom() + " WHERE questionnaire_id = ? AND version = ?"
+	row := qs.db.QueryRow(qs.opts.Dialect.Rebind(query), questionnaireID, version)
+
+	var questionnaireVersion models.QuestionnaireVersion
+	err := row.Scan(questionnaireVersionFields(&questionnaireVersion)...)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("questionnaire version %w with ID: %s and version: %d", ErrNotFound, questionnaireID, version)
+	} else if err != nil {
+		return nil, classifyError(err)
+	}
+
+	return &questionnaireVersion, nil
+}
+
+// ListByStudy returns a page of the questionnaires of a study, ordered by ID.
+// Pages are read with keyset pagination, so questionnaires created or deleted between two pages
+// never shift the listing: a page starts after the ID passed as the cursor.
+//
+// Parameters:
+//   - studyID: The study whose questionnaires are listed.
+//   - filter: The name filter, the cursor returned with the previous page and the page size.
+//
+// Returns:
+//   - *QuestionnairePage: The questionnaires of the page and the cursor of the next one.
+//   - error: An error indicating the failure of the database query.
+func (qs *QuestionnaireStore) ListByStudy(studyID string, filter QuestionnaireFilter) (*QuestionnairePage, error) {
+	query := questionnairesTable.selectFrom() + " WHERE study_id = ?" + questionnairesTable.notDeleted()
+	args := []interface{}{studyID}
+	if filter.Cursor != "" {
+		query += " AND id > ?"
+		args = append(args, filter.Cursor)
+	}
+	if filter.Name != "" {
+		query += " AND LOWER(name) LIKE ? ESCAPE '!'"
+		args = append(args, "%"+escapeLike(strings.ToLower(filter.Name))+"%")
+	}
+	// One more row than the page size tells whether there is a next page.
+	size := filter.PageSize()
+	query += fmt.Sprintf(" ORDER BY id LIMIT %d", size+1)
+
+	rows, err := qs.db.Query(qs.opts.Dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer rows.Close()
+
+	page := &QuestionnairePage{Questionnaires: []models.Questionnaire{}}
+	for rows.Next() {
+		var questionnaire models.Questionnaire
+		if err := rows.Scan(questionnaireFields(&questionnaire)...); err != nil {
+			return nil, classifyError(err)
+		}
+		page.Questionnaires = append(page.Questionnaires, questionnaire)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, classifyError(err)
+	}
+
+	if len(page.Questionnaires) > size {
+		page.Questionnaires = page.Questionnaires[:size]
+		page.NextCursor = page.Questionnaires[size-1].ID
+	}
+	return page, nil
+}
+
+// escapeLike escapes the LIKE wildcards in s with "!", the escape character given to every dialect.
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+// Create validates a questionnaire and inserts it into the "questionnaires" table, together with its version 1
+// in the "questionnaire_versions" table, in a single transaction.
+//
+// Parameters:
+//   - questionnaire: A pointer to a Questionnaire struct containing the data to be inserted.
+//
+// Returns:
+//   - error: ErrInvalid when the questionnaire fails ValidateQuestionnaire or ValidateQuestionnaireLinks, ErrDuplicate when the ID is taken,
+//     even by a deleted questionnaire, or another error of the database operation.
+//
+// If the questionnaire has no ID, one is generated with the store's ID generator and set on the struct.
+// DeletedAt is cleared, a questionnaire is never created deleted, and Version is set to 1.
+// A questionnaire without hours between attempts takes the scheduling defaults of its study, see ApplyStudyDefaults.
+// When the study is not in the "studies" table there are no defaults, and the questionnaire fails validation.
+func (qs *QuestionnaireStore) Create(questionnaire *models.Questionnaire) error {
+	if questionnaire.ID == "" {
+		questionnaire.ID = qs.opts.IDs.NewID()
+	}
+	if questionnaire.HoursBetweenAttempts == 0 && questionnaire.StudyID != "" {
+		studies := &StudyStore{db: qs.db, opts: qs.opts}
+		study, err := studies.FindStudyByID(questionnaire.StudyID)
+		if err == nil {
+			ApplyStudyDefaults(questionnaire, study)
+		} else if !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	questionnaire.DeletedAt = timestamp.NullTimeStamp{}
+	questionnaire.Version = 1
+	if err := ValidateQuestionnaire(questionnaire); err != nil {
+		return err
+	}
+	if err := ValidateQuestionnaireLinks(questionnaire, qs.FindQuestionnaireByID); err != nil {
+		return err
+	}
+
+	tx, err := qs.db.Begin()
+	if err != nil {
+		return classifyError(err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(qs.opts.Dialect.Rebind(questionnairesTable.insert()), questionnaireValues(questionnaire)...); err != nil {
+		return classifyError(err)
+	}
+	version := VersionOf(questionnaire, timestamp.TimeStamp{Time: qs.opts.Clock.Now()})
+	if _, err := tx.Exec(qs.opts.Dialect.Rebind(questionnaireVersionsTable.insert()), questionnaireVersionValues(&version)...); err != nil {
+		return classifyError(err)
+	}
+
+	return classifyError(tx.Commit())
+}
+
+// Update validates a questionnaire and writes every field but DeletedAt to the record with the same ID.
+// Schedules already created keep the time and the version they were given, the new settings apply to the next reschedule.
+//
+// When the update changes the questions, rules or attempts of the questionnaire, see ChangesVersion, a new version
+// is created and becomes the current one, in the same transaction. Otherwise, when only the name or the study
+// changes, the current version is kept. Either way Version is set on the struct to the current version.
+//
+// Parameters:
+//   - questionnaire: A pointer to a Questionnaire struct containing the updated data.
+//
+// Returns:
+//   - error: ErrInvalid when the questionnaire fails ValidateQuestionnaire or ValidateQuestionnaireLinks, ErrNotFound when no questionnaire
+//     has the ID or it was deleted, ErrConflict when a concurrent update created a version first,
+//     or another error of the database operation.
+func (qs *QuestionnaireStore) Update(questionnaire *models.Questionnaire) error {
+	if err := ValidateQuestionnaire(questionnaire); err != nil {
+		return err
+	}
+	if err := ValidateQuestionnaireLinks(questionnaire, qs.FindQuestionnaireByID); err != nil {
+		return err
+	}
+
+	tx, err := qs.db.Begin()
+	if err != nil {
+		return classifyError(err)
+	}
+	defer tx.Rollback()
+
+	current, err := qs.currentVersion(tx, questionnaire.ID)
+	if err != nil {
+		return err
+	}
+
+	updated := *questionnaire
+	updated.Version = current.Version
+	if ChangesVersion(current, &updated) {
+		updated.Version++
+		version := VersionOf(&updated, timestamp.TimeStamp{Time: qs.opts.Clock.Now()})
+		_, err := tx.Exec(qs.opts.Dialect.Rebind(questionnaireVersionsTable.insert()), questionnaireVersionValues(&version)...)
+		if err = classifyError(err); errors.Is(err, ErrDuplicate) {
+			return fmt.Errorf("%w: questionnaire %s version %d was created by another update", ErrConflict, updated.ID, version.Version)
+		} else if err != nil {
+			return err
+		}
+	}
+
+	// The current version must not have moved since it was read, or this update would point back to an older one.
+	query := questionnairesTable.update() + " AND current_version = ?"
+	args := append(questionnairesTable.updateArgs(questionnaireValues(&updated)), current.Version)
+	res, err := tx.Exec(qs.opts.Dialect.Rebind(query), args...)
+	if err != nil {
+		return classifyError(err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return classifyError(err)
+	}
+	if affected == 0 {
+		// MySQL counts changed rows rather than matched ones, so an update writing the stored values changes none.
+		stored, err := qs.currentVersion(tx, questionnaire.ID)
+		if err != nil {
+			return err
+		}
+		if stored.Version != current.Version {
+			return fmt.Errorf("%w: questionnaire %s was updated concurrently", ErrConflict, updated.ID)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return classifyError(err)
+	}
+	questionnaire.Version = updated.Version
+	return nil
+}
+
+// currentVersion reads the current version of a questionnaire that is not deleted.
+func (qs *QuestionnaireStore) currentVersion(q querier, questionnaireID string) (*models.QuestionnaireVersion, error) {
+	query := questionnaireVersionsTable.selectFrom() + " WHERE questionnaire_id = ? AND version = " +
+		"(SELECT current_version FROM " + questionnairesTable.name + " WHERE id = ?" + questionnairesTable.notDeleted() + ")"
+	row := q.QueryRow(qs.opts.Dialect.Rebind(query), questionnaireID, questionnaireID)
+
+	var version models.QuestionnaireVersion
+	err := row.Scan(questionnaireVersionFields(&version)...)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("questionnaire %w with ID: %s", ErrNotFound, questionnaireID)
+	} else if err != nil {
+		return nil, classifyError(err)
+	}
+	return &version, nil
+}
+
+// Delete soft deletes a questionnaire: the record is kept with deleted_at set to the current time
+// of the store's clock, and is no longer found, listed or updated.
+//
+// Parameters:
+//   - questionnaireID: The ID of the questionnaire to delete.
+//
+// Returns:
+//   - error: ErrNotFound when no questionnaire has the ID or it was already deleted,
+//     or another error of the database operation.
+func (qs *QuestionnaireStore) Delete(questionnaireID string) error {
+	query := "UPDATE " + questionnairesTable.name + " SET deleted_at = ? WHERE id = ?" + questionnairesTable.notDeleted()
+	deletedAt := timestamp.TimeStamp{Time: qs.opts.Clock.Now()}
+	res, err := qs.db.Exec(qs.opts.Dialect.Rebind(query), deletedAt, questionnaireID)
+	if err != nil {
+		return classifyError(err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return classifyError(err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("questionnaire %w with ID: %s", ErrNotFound, questionnaireID)
+	}
+	return nil
+}
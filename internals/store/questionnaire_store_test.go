@@ -0,0 +1,330 @@
+// File: ./internals/store/questionnaire_store_test.go
+
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"rescheduler/internals/dialect"
+	"rescheduler/internals/models"
+	"rescheduler/internals/timestamp"
+)
+
+func TestValidateQuestionnaire(t *testing.T) {
+	valid := models.Questionnaire{
+		ID:                   "q1",
+		StudyID:              "Study5",
+		Name:                 "Mood",
+		Questions:            `{"questions": []}`,
+		MaxAttempts:          sql.NullInt64{Int64: 3, Valid: true},
+		HoursBetweenAttempts: 24,
+	}
+
+	tests := []struct {
+		name    string
+		change  func(q *models.Questionnaire)
+		wantErr bool
+	}{
+		{name: "Valid", change: func(q *models.Questionnaire) {}},
+		{name: "Unlimited attempts", change: func(q *models.Questionnaire) { q.MaxAttempts = sql.NullInt64{} }},
+		{name: "No study", change: func(q *models.Questionnaire) { q.StudyID = "" }, wantErr: true},
+		{name: "No name", change: func(q *models.Questionnaire) { q.Name = " " }, wantErr: true},
+		{name: "Questions not JSON", change: func(q *models.Questionnaire) { q.Questions = "mood?" }, wantErr: true},
+		{name: "Questions defined", change: func(q *models.Questionnaire) {
+			q.Questions = `{"questions": [{"id": "mood", "type": "numeric", "min": 0, "max": 10}]}`
+		}},
+		{name: "Question of unknown type", change: func(q *models.Questionnaire) {
+			q.Questions = `{"questions": [{"id": "mood", "type": "slider"}]}`
+		}, wantErr: true},
+		{name: "Rule scheduling the questionnaire itself", change: func(q *models.Questionnaire) {
+			q.Questions = `{"questions": [{"id": "harm", "type": "text"}], "rules": [{"when": {"answer": "harm", "op": "=", "value": "yes"}, "then": {"schedule": "q1"}}]}`
+		}, wantErr: true},
+		{name: "Zero max attempts", change: func(q *models.Questionnaire) { q.MaxAttempts.Int64 = 0 }, wantErr: true},
+		{name: "Zero hours between attempts", change: func(q *models.Questionnaire) { q.HoursBetweenAttempts = 0 }, wantErr: true},
+		{name: "Negative hours between attempts", change: func(q *models.Questionnaire) { q.HoursBetweenAttempts = -24 }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			questionnaire := valid
+			tt.change(&questionnaire)
+
+			err := ValidateQuestionnaire(&questionnaire)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("Unexpected validation result.\nGot: %v\nExpected an error: %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalid) {
+				t.Fatalf("Expected ErrInvalid.\nGot: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateQuestionnaireLinks(t *testing.T) {
+	stored := map[string]models.Questionnaire{
+		"q2": {ID: "q2", StudyID: "Study5"},
+		"q3": {ID: "q3", StudyID: "Study6"},
+	}
+	lookupErr := errors.New("connection reset")
+	find := func(questionnaireID string) (*models.Questionnaire, error) {
+		if questionnaireID == "broken" {
+			return nil, lookupErr
+		}
+		questionnaire, ok := stored[questionnaireID]
+		if !ok {
+			return nil, fmt.Errorf("questionnaire %w with ID: %s", ErrNotFound, questionnaireID)
+		}
+		return &questionnaire, nil
+	}
+	rules := `{"questions": [{"id": "harm", "type": "text"}], "rules": [{"when": {"answer": "harm", "op": "=", "value": "yes"}, "then": {"schedule": "%s"}}]}`
+
+	tests := []struct {
+		name      string
+		questions string
+		expected  error
+	}{
+		{name: "No rules", questions: `{"questions": []}`},
+		{name: "Questionnaire of the study", questions: fmt.Sprintf(rules, "q2")},
+		{name: "Questionnaire of another study", questions: fmt.Sprintf(rules, "q3"), expected: ErrInvalid},
+		{name: "Missing or deleted questionnaire", questions: fmt.Sprintf(rules, "q4"), expected: ErrInvalid},
+		{name: "Lookup failure", questions: fmt.Sprintf(rules, "broken"), expected: lookupErr},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			questionnaire := &models.Questionnaire{ID: "q1", StudyID: "Study5", Questions: tt.questions}
+			if err := ValidateQuestionnaireLinks(questionnaire, find); !errors.Is(err, tt.expected) || (tt.expected == nil) != (err == nil) {
+				t.Fatalf("Unexpected validation result.\nGot: %v\nExpected: %v", err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	if got, expected := escapeLike("100%_done!"), "100!%!_done!!"; got != expected {
+		t.Fatalf("Unexpected pattern.\nGot: %s\nExpected: %s", got, expected)
+	}
+}
+
+func TestQuestionnaireStore_CRUD(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, d dialect.Dialect, db *sql.DB) {
+		seedStudies(t, d, db)
+		questionnaires := NewQuestionnaireStore(db, WithDialect(d))
+
+		for i, name := range []string{"Mood", "Sleep", "Mood 100%", "Evening mood", "Pain"} {
+			questionnaire := &models.Questionnaire{
+				ID:                   fmt.Sprintf("q%d", i+1),
+				StudyID:              "Study5",
+				Name:                 name,
+				Questions:            `{"questions": []}`,
+				HoursBetweenAttempts: 24,
+			}
+			if err := questionnaires.Create(questionnaire); err != nil {
+				t.Fatalf("Error creating questionnaire: %v", err)
+			}
+		}
+		other := &models.Questionnaire{StudyID: "Study6", Name: "Mood", Questions: `{}`, HoursBetweenAttempts: 12}
+		if err := questionnaires.Create(other); err != nil || other.ID == "" {
+			t.Fatalf("Error creating questionnaire with a generated ID: %v", err)
+		}
+		if err := questionnaires.Create(&models.Questionnaire{ID: "q1", StudyID: "Study5", Name: "Mood", Questions: `{}`, HoursBetweenAttempts: 24}); !errors.Is(err, ErrDuplicate) {
+			t.Fatalf("Expected a duplicate for a taken ID.\nGot: %v", err)
+		}
+		// Without hours between attempts, and no study to take them from.
+		if err := questionnaires.Create(&models.Questionnaire{StudyID: "Study9", Name: "Mood", Questions: `{}`}); !errors.Is(err, ErrInvalid) {
+			t.Fatalf("Expected an invalid questionnaire.\nGot: %v", err)
+		}
+
+		// Pages of two, and a name filter matching wildcards literally.
+		var ids []string
+		filter := QuestionnaireFilter{Limit: 2}
+		for pages := 0; ; pages++ {
+			page, err := questionnaires.ListByStudy("Study5", filter)
+			if err != nil {
+				t.Fatalf("Error listing questionnaires: %v", err)
+			}
+			for _, questionnaire := range page.Questionnaires {
+				ids = append(ids, questionnaire.ID)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			if pages > 3 {
+				t.Fatalf("Listing does not end, cursor: %s", page.NextCursor)
+			}
+			filter.Cursor = page.NextCursor
+		}
+		if fmt.Sprint(ids) != "[q1 q2 q3 q4 q5]" {
+			t.Fatalf("Unexpected listing.\nGot: %v\nExpected: [q1 q2 q3 q4 q5]", ids)
+		}
+
+		assertListed(t, questionnaires, QuestionnaireFilter{Name: "MOOD"}, "[q1 q3 q4]")
+		assertListed(t, questionnaires, QuestionnaireFilter{Name: "0%"}, "[q3]")
+
+		update := &models.Questionnaire{ID: "q2", StudyID: "Study5", Name: "Sleep quality", Questions: `{}`, MaxAttempts: sql.NullInt64{Int64: 5, Valid: true}, HoursBetweenAttempts: 48}
+		if err := questionnaires.Update(update); err != nil {
+			t.Fatalf("Error updating questionnaire: %v", err)
+		}
+		// Writing the stored values again is not a missing record, even where no row changes.
+		if err := questionnaires.Update(update); err != nil {
+			t.Fatalf("Error repeating an update: %v", err)
+		}
+		found, err := questionnaires.FindQuestionnaireByID("q2")
+		if err != nil || found.Name != "Sleep quality" || found.MaxAttempts.Int64 != 5 || found.HoursBetweenAttempts != 48 {
+			t.Fatalf("Unexpected questionnaire after an update.\nGot: %+v, %v", found, err)
+		}
+
+		if err := questionnaires.Delete("q1"); err != nil {
+			t.Fatalf("Error deleting questionnaire: %v", err)
+		}
+		if err := questionnaires.Delete("q1"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Expected not found deleting twice.\nGot: %v", err)
+		}
+		if _, err := questionnaires.FindQuestionnaireByID("q1"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Expected a deleted questionnaire not to be found.\nGot: %v", err)
+		}
+		deleted := &models.Questionnaire{ID: "q1", StudyID: "Study5", Name: "Mood", Questions: `{}`, HoursBetweenAttempts: 24}
+		if err := questionnaires.Update(deleted); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Expected not found updating a deleted questionnaire.\nGot: %v", err)
+		}
+		assertListed(t, questionnaires, QuestionnaireFilter{Name: "mood"}, "[q3 q4]")
+
+		var deletedAt timestamp.NullTimeStamp
+		if err := db.QueryRow(d.Rebind("SELECT deleted_at FROM questionnaires WHERE id = ?"), "q1").Scan(&deletedAt); err != nil || !deletedAt.Valid {
+			t.Fatalf("Expected the deleted questionnaire to be kept with its deletion time.\nGot: %v, %v", deletedAt, err)
+		}
+	})
+}
+
+func assertListed(t *testing.T, questionnaires *QuestionnaireStore, filter QuestionnaireFilter, expected string) {
+	t.Helper()
+
+	page, err := questionnaires.ListByStudy("Study5", filter)
+	if err != nil {
+		t.Fatalf("Error listing questionnaires: %v", err)
+	}
+	var ids []string
+	for _, questionnaire := range page.Questionnaires {
+		ids = append(ids, questionnaire.ID)
+	}
+	if fmt.Sprint(ids) != expected || page.NextCursor != "" {
+		t.Fatalf("Unexpected listing for %+v.\nGot: %v, cursor %q\nExpected: %s", filter, ids, page.NextCursor, expected)
+	}
+}
+
+func TestQuestionnaireStore_Links(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, d dialect.Dialect, db *sql.DB) {
+		seedStudies(t, d, db)
+		questionnaires := NewQuestionnaireStore(db, WithDialect(d))
+		for _, questionnaire := range []*models.Questionnaire{
+			{ID: "q2", StudyID: "Study5", Name: "Sleep", Questions: `{}`, HoursBetweenAttempts: 24},
+			{ID: "q3", StudyID: "Study6", Name: "Pain", Questions: `{}`, HoursBetweenAttempts: 24},
+			{ID: "q4", StudyID: "Study5", Name: "Retired", Questions: `{}`, HoursBetweenAttempts: 24},
+		} {
+			if err := questionnaires.Create(questionnaire); err != nil {
+				t.Fatalf("Error creating questionnaire: %v", err)
+			}
+		}
+		if err := questionnaires.Delete("q4"); err != nil {
+			t.Fatalf("Error deleting questionnaire: %v", err)
+		}
+
+		rules := `{"questions": [{"id": "harm", "type": "text"}], "rules": [{"when": {"answer": "harm", "op": "=", "value": "yes"}, "then": {"schedule": "%s"}}]}`
+		for _, tt := range []struct {
+			scheduled string
+			expected  error
+		}{
+			{scheduled: "q3", expected: ErrInvalid},
+			{scheduled: "q4", expected: ErrInvalid},
+			{scheduled: "q5", expected: ErrInvalid},
+			{scheduled: "q2"},
+		} {
+			questionnaire := &models.Questionnaire{ID: "q1", StudyID: "Study5", Name: "Mood", Questions: fmt.Sprintf(rules, tt.scheduled), HoursBetweenAttempts: 24}
+			if err := questionnaires.Create(questionnaire); !errors.Is(err, tt.expected) || (tt.expected == nil) != (err == nil) {
+				t.Fatalf("Unexpected result scheduling %s.\nGot: %v\nExpected: %v", tt.scheduled, err, tt.expected)
+			}
+		}
+
+		// Update checks the links the same way.
+		updated := &models.Questionnaire{ID: "q1", StudyID: "Study5", Name: "Mood", Questions: fmt.Sprintf(rules, "q3"), HoursBetweenAttempts: 24}
+		if err := questionnaires.Update(updated); !errors.Is(err, ErrInvalid) {
+			t.Fatalf("Expected an invalid update.\nGot: %v", err)
+		}
+	})
+}
+
+func TestQuestionnaireStore_Versions(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, d dialect.Dialect, db *sql.DB) {
+		seedStudies(t, d, db)
+		questionnaires := NewQuestionnaireStore(db, WithDialect(d))
+		schedules := NewScheduledQuestionnaireStore(db, WithDialect(d))
+		if _, err := db.Exec(d.Rebind("INSERT INTO participants (id, name) VALUES (?, ?)"), "p1", "Ada"); err != nil {
+			t.Fatalf("Error seeding participant: %v", err)
+		}
+
+		first := `{"questions": [{"id": "mood", "type": "numeric"}]}`
+		questionnaire := &models.Questionnaire{ID: "q1", StudyID: "Study5", Name: "Mood", Questions: first, HoursBetweenAttempts: 24, Version: 7}
+		if err := questionnaires.Create(questionnaire); err != nil || questionnaire.Version != 1 {
+			t.Fatalf("Expected a new questionnaire at version 1.\nGot: %d, %v", questionnaire.Version, err)
+		}
+		pending := &models.ScheduledQuestionnaire{
+			QuestionnaireID: "q1",
+			ParticipantID:   "p1",
+			ScheduledAt:     timestamp.TimeStamp{Time: time.Date(2023, 12, 5, 2, 11, 0, 0, time.UTC)},
+			Status:          models.ScheduledQuestionnairePending,
+		}
+		if err := schedules.Create(pending); err != nil || pending.QuestionnaireVersion != 1 {
+			t.Fatalf("Expected a schedule of the current version.\nGot: %d, %v", pending.QuestionnaireVersion, err)
+		}
+
+		tests := []struct {
+			name     string
+			update   models.Questionnaire
+			expected int
+		}{
+			{name: "Renaming keeps the version", update: models.Questionnaire{Name: "Daily mood", Questions: first, HoursBetweenAttempts: 24}, expected: 1},
+			{name: "Same questions written differently", update: models.Questionnaire{Name: "Daily mood", Questions: `{"questions":[{"type":"numeric","id":"mood"}]}`, HoursBetweenAttempts: 24}, expected: 1},
+			{name: "Changed questions", update: models.Questionnaire{Name: "Daily mood", Questions: `{"questions": [{"id": "sleep", "type": "text"}]}`, HoursBetweenAttempts: 24}, expected: 2},
+			{name: "Changed attempts", update: models.Questionnaire{Name: "Daily mood", Questions: `{"questions": [{"id": "sleep", "type": "text"}]}`, MaxAttempts: sql.NullInt64{Int64: 3, Valid: true}, HoursBetweenAttempts: 24}, expected: 3},
+			{name: "Changed interval", update: models.Questionnaire{Name: "Daily mood", Questions: `{"questions": [{"id": "sleep", "type": "text"}]}`, MaxAttempts: sql.NullInt64{Int64: 3, Valid: true}, HoursBetweenAttempts: 12}, expected: 4},
+		}
+
+		for _, tt := range tests {
+			update := tt.update
+			update.ID, update.StudyID = "q1", "Study5"
+			if err := questionnaires.Update(&update); err != nil {
+				t.Fatalf("%s: error updating questionnaire: %v", tt.name, err)
+			}
+			found, err := questionnaires.FindQuestionnaireByID("q1")
+			if err != nil || update.Version != tt.expected || found.Version != tt.expected {
+				t.Fatalf("%s: unexpected version.\nGot: %d, stored %+v, %v\nExpected: %d", tt.name, update.Version, found, err, tt.expected)
+			}
+		}
+
+		// Earlier versions are kept as they were, and found after the questionnaire is deleted.
+		if err := questionnaires.Delete("q1"); err != nil {
+			t.Fatalf("Error deleting questionnaire: %v", err)
+		}
+		version, err := questionnaires.FindVersion("q1", 1)
+		if err != nil || !sameJSON(version.Questions, first) || version.MaxAttempts.Valid || version.HoursBetweenAttempts != 24 {
+			t.Fatalf("Unexpected version 1.\nGot: %+v, %v", version, err)
+		}
+		version, err = questionnaires.FindVersion("q1", 4)
+		if err != nil || version.MaxAttempts.Int64 != 3 || version.HoursBetweenAttempts != 12 {
+			t.Fatalf("Unexpected version 4.\nGot: %+v, %v", version, err)
+		}
+		if _, err := questionnaires.FindVersion("q1", 5); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Expected not found for an unknown version.\nGot: %v", err)
+		}
+
+		// The pending schedule still points to the version it was issued against.
+		stored, err := schedules.FindScheduledQuestionnaireByQuestionnaireIDAndUserID("q1", "p1")
+		if err != nil || stored.QuestionnaireVersion != 1 {
+			t.Fatalf("Unexpected schedule after the updates.\nGot: %+v, %v", stored, err)
+		}
+	})
+}
@@ -3,49 +3,66 @@ package store
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"rescheduler/internals/models"
-	"time"
 )
 
 // ScheduledQuestionnaireStoreInterface defines the methods expected for scheduled questionnaire-related database operations.
 type ScheduledQuestionnaireStoreInterface interface {
 	FindScheduledQuestionnaireByQuestionnaireIDAndUserIDAndStudyID(questionnaireID, userID, studyID string) (*models.ScheduledQuestionnaire, error)
 	FindScheduledQuestionnaireByQuestionnaireIDAndUserID(questionnaireID string, userID string) (*models.ScheduledQuestionnaire, error)
+	ListByParticipant(participantID string) ([]models.ScheduledQuestionnaire, error)
 	Update(scheduledQuestionnaire *models.ScheduledQuestionnaire) error
 	Create(scheduledQuestionnaire *models.ScheduledQuestionnaire) error
+	Complete(completion *Completion) error
+}
+
+// Completion groups the writes recording the completion of a schedule, which Complete commits together:
+// a completion is never recorded without the result of its answers or without the schedules that follow it.
+type Completion struct {
+	// Schedule is the schedule completed, updated like Update.
+	Schedule *models.ScheduledQuestionnaire
+	// Result is the result of the answers, created like QuestionnaireResultStoreInterface.Create.
+	Result *models.QuestionnaireResult
+	// Next is the next attempt at the questionnaire, created like Create. It is nil when the series is completed.
+	Next *models.ScheduledQuestionnaire
+	// FollowUps are the schedules of other questionnaires, created like Create unless the participant already has
+	// a pending schedule for the questionnaire. Complete leaves only the schedules it created.
+	FollowUps []models.ScheduledQuestionnaire
+	// EndParticipation is set when the participation of the participant may end with this completion, as it completes
+	// a series of a participation that has an end. Complete then moves an active participant to completed,
+	// once they have no pending schedule left.
+	EndParticipation bool
 }
 
 // ScheduledQuestionnaireStore implements ScheduledQuestionnaireStoreInterface and is responsible for handling scheduled questionnaire-related database operations.
 type ScheduledQuestionnaireStore struct {
-	db *sql.DB
+	db   *sql.DB
+	opts Options
 }
 
-// NewScheduledQuestionnaireStore creates a new ScheduledQuestionnaireStore instance with the given SQL database connection.
-func NewScheduledQuestionnaireStore(db *sql.DB) *ScheduledQuestionnaireStore {
-	return &ScheduledQuestionnaireStore{db: db}
+// NewScheduledQuestionnaireStore creates a new ScheduledQuestionnaireStore instance with the given SQL database connection and options.
+func NewScheduledQuestionnaireStore(db *sql.DB, opts ...Option) *ScheduledQuestionnaireStore {
+	return &ScheduledQuestionnaireStore{db: db, opts: NewOptions(opts...)}
 }
 
 // FindScheduledQuestionnaireByQuestionnaireIDAndUserIDAndStudyID retrieves a scheduled questionnaire by QuestionnaireID, UserID, and StudyID with a specific status.
 // It returns a ScheduledQuestionnaire instance if found, or nil if no scheduled questionnaire is found.
 // An error is returned if there is an issue with the database query.
 // This again shouldn't need the studyID as it doesn't identify the questionnaire but it comes with the event data
+// scheduled_questionnaires has no study_id column, so the study is matched through the questionnaire.
 func (scheduleStore *ScheduledQuestionnaireStore) FindScheduledQuestionnaireByQuestionnaireIDAndUserIDAndStudyID(questionnaireID, userID, studyID string) (*models.ScheduledQuestionnaire, error) {
-	query := "SELECT * FROM scheduled_questionnaires WHERE questionnaire_id = ? AND participant_id = ? AND status =?  AND study_id = ?"
-	row := scheduleStore.db.QueryRow(query, questionnaireID, userID, models.ScheduledQuestionnairePending, studyID)
+	query := scheduledQuestionnairesTable.selectFrom() + " WHERE questionnaire_id = ? AND participant_id = ? AND status = ?" +
+		" AND questionnaire_id IN (SELECT id FROM questionnaires WHERE study_id = ?)"
+	row := scheduleStore.db.QueryRow(scheduleStore.opts.Dialect.Rebind(query), questionnaireID, userID, models.ScheduledQuestionnairePending, studyID)
 
 	var scheduledQuestionnaire models.ScheduledQuestionnaire
-	err := row.Scan(
-		&scheduledQuestionnaire.ID,
-		&scheduledQuestionnaire.QuestionnaireID,
-		&scheduledQuestionnaire.ParticipantID,
-		&scheduledQuestionnaire.ScheduledAt,
-		&scheduledQuestionnaire.Status,
-	)
+	err := row.Scan(scheduledQuestionnaireFields(&scheduledQuestionnaire)...)
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("scheduled questionnaire not found with Questionnaire ID: %s, User ID: %s, and Study ID: %s", questionnaireID, userID, studyID)
+		return nil, fmt.Errorf("scheduled questionnaire %w with Questionnaire ID: %s, User ID: %s, and Study ID: %s", ErrNotFound, questionnaireID, userID, studyID)
 	} else if err != nil {
-		return nil, err
+		return nil, classifyError(err)
 	}
 
 	return &scheduledQuestionnaire, nil
@@ -56,35 +73,63 @@ func (scheduleStore *ScheduledQuestionnaireStore) FindScheduledQuestionnaireByQu
 // An error is returned if there is an issue with the database query.
 // This version omits studyID as this doesn't identify a scheduledQuestionnaire
 func (scheduleStore *ScheduledQuestionnaireStore) FindScheduledQuestionnaireByQuestionnaireIDAndUserID(questionnaireID string, userID string) (*models.ScheduledQuestionnaire, error) {
-	query := "SELECT * FROM scheduled_questionnaires WHERE questionnaire_id = ? AND participant_id = ? AND status = ?"
-	row := scheduleStore.db.QueryRow(query, questionnaireID, userID, models.ScheduledQuestionnairePending)
+	return scheduleStore.findPending(scheduleStore.db, questionnaireID, userID)
+}
+
+func (scheduleStore *ScheduledQuestionnaireStore) findPending(q querier, questionnaireID string, userID string) (*models.ScheduledQuestionnaire, error) {
+	query := scheduledQuestionnairesTable.selectFrom() + " WHERE questionnaire_id = ? AND participant_id = ? AND status = ?"
+	row := q.QueryRow(scheduleStore.opts.Dialect.Rebind(query), questionnaireID, userID, models.ScheduledQuestionnairePending)
 	var scheduledQuestionnaire models.ScheduledQuestionnaire
-	err := row.Scan(
-		&scheduledQuestionnaire.ID,
-		&scheduledQuestionnaire.QuestionnaireID,
-		&scheduledQuestionnaire.ParticipantID,
-		&scheduledQuestionnaire.ScheduledAt,
-		&scheduledQuestionnaire.Status,
-	)
+	err := row.Scan(scheduledQuestionnaireFields(&scheduledQuestionnaire)...)
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("scheduled questionnaire not found with Questionnaire ID: %s, User ID: %s", questionnaireID, userID)
+		return nil, fmt.Errorf("scheduled questionnaire %w with Questionnaire ID: %s, User ID: %s", ErrNotFound, questionnaireID, userID)
 	} else if err != nil {
-		return nil, err
+		return nil, classifyError(err)
 	}
 
 	return &scheduledQuestionnaire, nil
 }
 
+// ListByParticipant retrieves every scheduled questionnaire of a participant, whatever its status,
+// ordered by scheduled time. It returns an empty slice when the participant has none.
+func (scheduleStore *ScheduledQuestionnaireStore) ListByParticipant(participantID string) ([]models.ScheduledQuestionnaire, error) {
+	query := scheduledQuestionnairesTable.selectFrom() + " WHERE participant_id = ? ORDER BY scheduled_at, id"
+	rows, err := scheduleStore.db.Query(scheduleStore.opts.Dialect.Rebind(query), participantID)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer rows.Close()
+
+	schedules := []models.ScheduledQuestionnaire{}
+	for rows.Next() {
+		var scheduledQuestionnaire models.ScheduledQuestionnaire
+		if err := rows.Scan(scheduledQuestionnaireFields(&scheduledQuestionnaire)...); err != nil {
+			return nil, classifyError(err)
+		}
+		schedules = append(schedules, scheduledQuestionnaire)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, classifyError(err)
+	}
+
+	return schedules, nil
+}
+
 // Update modifies the fields of an existing scheduled questionnaire record in the database.
 // It takes a pointer to a ScheduledQuestionnaire struct and updates the corresponding
-// record in the "scheduled_questionnaires" table based on the unique identifier (ID).
+// record in the "scheduled_questionnaires" table based on the unique identifier (ID) and the Version read.
 // The function returns an error if the database operation encounters any issues.
 //
+// The update only applies if the stored version still equals scheduledQuestionnaire.Version, in which case
+// the version is incremented in the database and on the struct. Otherwise the record was changed by someone
+// else since it was read and ErrConflict is returned, or it no longer exists and ErrNotFound is returned.
+//
 // Parameters:
 //   - scheduledQuestionnaire: A pointer to a ScheduledQuestionnaire struct containing the updated data.
 //
 // Returns:
-//   - error: An error indicating the success or failure of the database operation.
+//   - error: An error indicating the success or failure of the database operation, ErrConflict for a stale version
+//     and ErrNotFound for an unknown ID.
 //
 // Database Table Schema:
 //   - Table Name: scheduled_questionnaires
@@ -94,10 +139,29 @@ func (scheduleStore *ScheduledQuestionnaireStore) FindScheduledQuestionnaireByQu
 //   - participant_id (string): Identifier of the participant for whom the questionnaire is scheduled.
 //   - scheduled_at (time.Time): Timestamp indicating when the questionnaire is scheduled.
 //   - status (string): Status of the scheduled questionnaire (e.g., "pending" or "completed").
+//   - version (int): Version of the record, incremented by every update.
 func (scheduleStore *ScheduledQuestionnaireStore) Update(scheduledQuestionnaire *models.ScheduledQuestionnaire) error {
-	query := "UPDATE scheduled_questionnaires SET questionnaire_id = ?, participant_id = ?, scheduled_at = ?, status = ? WHERE id = ?"
-	_, err := scheduleStore.db.Exec(query, scheduledQuestionnaire.QuestionnaireID, scheduledQuestionnaire.ParticipantID, scheduledQuestionnaire.ScheduledAt, scheduledQuestionnaire.Status, scheduledQuestionnaire.ID)
-	return err
+	return scheduleStore.update(scheduleStore.db, scheduledQuestionnaire)
+}
+
+func (scheduleStore *ScheduledQuestionnaireStore) update(q querier, scheduledQuestionnaire *models.ScheduledQuestionnaire) error {
+	args := scheduledQuestionnairesTable.updateArgs(scheduledQuestionnaireValues(scheduledQuestionnaire))
+	res, err := q.Exec(scheduleStore.opts.Dialect.Rebind(scheduledQuestionnairesTable.update()), args...)
+	if err != nil {
+		return classifyError(err)
+	}
+
+	// Every successful update increments the version, so zero affected rows means a stale version or a missing record.
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return classifyError(err)
+	}
+	if affected == 0 {
+		return scheduleStore.missedUpdate(q, scheduledQuestionnaire)
+	}
+
+	scheduledQuestionnaire.Version++
+	return nil
 }
 
 // Create inserts a new scheduled questionnaire record into the database.
@@ -118,18 +182,128 @@ func (scheduleStore *ScheduledQuestionnaireStore) Update(scheduledQuestionnaire
 //   - id (string): Unique identifier for the scheduled questionnaire.
 //   - questionnaire_id (string): Identifier of the associated questionnaire.
 //   - participant_id (string): Identifier of the participant assigned to the questionnaire.
-//   - scheduled_at (string): Scheduled time of the questionnaire in RFC3339 format.
+//   - scheduled_at (string): Scheduled time of the questionnaire in UTC, formatted as "2006-01-02 15:04:05".
 //   - status (string): Status of the scheduled questionnaire (e.g., "pending" or "completed").
+//   - version (int): Version of the record, 1 when created.
+//   - questionnaire_version (int): Version of the questionnaire the schedule is issued against.
+//
+// If the scheduled questionnaire has no ID, one is generated with the store's ID generator and set on the struct.
+// A zero Version is set to 1, and a zero QuestionnaireVersion to the current version of the questionnaire.
 func (scheduleStore *ScheduledQuestionnaireStore) Create(scheduledQuestionnaire *models.ScheduledQuestionnaire) error {
-	query := "INSERT INTO scheduled_questionnaires (id, questionnaire_id, participant_id, scheduled_at, status) VALUES (?, ?, ?, ?, ?)"
+	return scheduleStore.create(scheduleStore.db, scheduledQuestionnaire)
+}
+
+func (scheduleStore *ScheduledQuestionnaireStore) create(q querier, scheduledQuestionnaire *models.ScheduledQuestionnaire) error {
+	if scheduledQuestionnaire.ID == "" {
+		scheduledQuestionnaire.ID = scheduleStore.opts.IDs.NewID()
+	}
+	if scheduledQuestionnaire.Version == 0 {
+		scheduledQuestionnaire.Version = 1
+	}
+	if scheduledQuestionnaire.QuestionnaireVersion == 0 {
+		query := "SELECT current_version FROM " + questionnairesTable.name + " WHERE id = ?"
+		err := q.QueryRow(scheduleStore.opts.Dialect.Rebind(query), scheduledQuestionnaire.QuestionnaireID).Scan(&scheduledQuestionnaire.QuestionnaireVersion)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("questionnaire %w with ID: %s", ErrNotFound, scheduledQuestionnaire.QuestionnaireID)
+		} else if err != nil {
+			return classifyError(err)
+		}
+	}
 
-	_, err := scheduleStore.db.Exec(query,
-		scheduledQuestionnaire.ID,
-		scheduledQuestionnaire.QuestionnaireID,
-		scheduledQuestionnaire.ParticipantID,
-		scheduledQuestionnaire.ScheduledAt.Format(time.RFC3339),
-		scheduledQuestionnaire.Status,
-	)
+	_, err := q.Exec(scheduleStore.opts.Dialect.Rebind(scheduledQuestionnairesTable.insert()), scheduledQuestionnaireValues(scheduledQuestionnaire)...)
+	return classifyError(err)
+}
+
+// Complete records the completion of a schedule in one transaction: it updates completion.Schedule, creates
+// completion.Result, then each of completion.FollowUps and completion.Next, and ends the participation when
+// completion.EndParticipation is set. Either every write is committed or none is.
+//
+// A follow-up whose questionnaire the participant already has a pending schedule for is left out, and removed
+// from completion.FollowUps.
+//
+// Parameters:
+//   - completion: A pointer to a Completion with the schedule completed and the records created with it.
+//
+// Returns:
+//   - error: The error of the first write that failed, as returned by Update, QuestionnaireResultStore.Create or Create:
+//     ErrConflict when the schedule was changed since it was read, ErrInvalid for invalid answers and ErrDuplicate when
+//     another result records the same event, or a concurrent write created one of the pending schedules first.
+//     The version of completion.Schedule is then left as it was.
+func (scheduleStore *ScheduledQuestionnaireStore) Complete(completion *Completion) error {
+	tx, err := scheduleStore.db.Begin()
+	if err != nil {
+		return classifyError(err)
+	}
+	defer tx.Rollback()
+
+	version := completion.Schedule.Version
+	followUps, err := scheduleStore.complete(tx, completion)
+	if err == nil {
+		err = classifyError(tx.Commit())
+	}
+	if err != nil {
+		completion.Schedule.Version = version
+		return err
+	}
+
+	completion.FollowUps = followUps
+	return nil
+}
+
+// complete runs the writes of Complete with q, returning the follow-ups created.
+func (scheduleStore *ScheduledQuestionnaireStore) complete(q querier, completion *Completion) ([]models.ScheduledQuestionnaire, error) {
+	if err := scheduleStore.update(q, completion.Schedule); err != nil {
+		return nil, err
+	}
+	if err := createResult(q, scheduleStore.opts, completion.Result); err != nil {
+		return nil, err
+	}
+
+	var followUps []models.ScheduledQuestionnaire
+	for _, followUp := range completion.FollowUps {
+		// The pending schedule is looked for rather than the insert left to fail, as a failed statement
+		// aborts the whole transaction on PostgreSQL.
+		_, err := scheduleStore.findPending(q, followUp.QuestionnaireID, followUp.ParticipantID)
+		if err == nil {
+			continue
+		} else if !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		if err := scheduleStore.create(q, &followUp); err != nil {
+			return nil, err
+		}
+		followUps = append(followUps, followUp)
+	}
+
+	if completion.Next != nil {
+		if err := scheduleStore.create(q, completion.Next); err != nil {
+			return nil, err
+		}
+	}
+
+	if completion.EndParticipation {
+		// A participant still due other questionnaires keeps their status until the last one is completed.
+		query := "UPDATE " + participantsTable.name + " SET status = ? WHERE id = ? AND status = ? AND NOT EXISTS (SELECT 1 FROM " +
+			scheduledQuestionnairesTable.name + " WHERE participant_id = ? AND status = ?)"
+		participantID := completion.Schedule.ParticipantID
+		if _, err := q.Exec(scheduleStore.opts.Dialect.Rebind(query), string(models.ParticipantCompleted), participantID, string(models.ParticipantActive),
+			participantID, models.ScheduledQuestionnairePending); err != nil {
+			return nil, classifyError(err)
+		}
+	}
+	return followUps, nil
+}
 
-	return err
+// missedUpdate explains an update that changed no row: ErrNotFound when no record has the ID,
+// ErrConflict when it exists at another version.
+func (scheduleStore *ScheduledQuestionnaireStore) missedUpdate(q querier, scheduledQuestionnaire *models.ScheduledQuestionnaire) error {
+	query := "SELECT version FROM " + scheduledQuestionnairesTable.name + " WHERE id = ?"
+	var version int64
+	err := q.QueryRow(scheduleStore.opts.Dialect.Rebind(query), scheduledQuestionnaire.ID).Scan(&version)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("scheduled questionnaire %w with ID: %s", ErrNotFound, scheduledQuestionnaire.ID)
+	} else if err != nil {
+		return classifyError(err)
+	}
+	return fmt.Errorf("%w: scheduled questionnaire %s at version %d, stored version is %d", ErrConflict, scheduledQuestionnaire.ID, scheduledQuestionnaire.Version, version)
 }
@@ -0,0 +1,113 @@
+// File: ./internals/store/scheduled_questionnaire_store_test.go
+
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"rescheduler/internals/dialect"
+	"rescheduler/internals/models"
+	"rescheduler/internals/timestamp"
+)
+
+func TestScheduledQuestionnaireStore_Complete(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, d dialect.Dialect, db *sql.DB) {
+		seed(t, d, db)
+		schedules := NewScheduledQuestionnaireStore(db, WithDialect(d))
+		results := NewQuestionnaireResultStore(db, WithDialect(d))
+
+		scheduledAt := time.Date(2023, 12, 4, 2, 0, 0, 0, time.UTC)
+		pending := &models.ScheduledQuestionnaire{ID: "s1", QuestionnaireID: "q1", ParticipantID: "p1", ScheduledAt: timestamp.TimeStamp{Time: scheduledAt}, Status: models.ScheduledQuestionnairePending}
+		if err := schedules.Create(pending); err != nil {
+			t.Fatalf("Error creating schedule: %v", err)
+		}
+		completion := func(nextID string) *Completion {
+			schedule := *pending
+			schedule.Status = models.ScheduledQuestionnaireCompleted
+			return &Completion{
+				Schedule: &schedule,
+				Result:   &models.QuestionnaireResult{Answers: json.RawMessage(`{}`), QuestionnaireID: "q1", QuestionnaireVersion: 1, ParticipantID: "p1", QuestionnaireScheduleID: "s1", EventID: "event-1"},
+				Next:     &models.ScheduledQuestionnaire{ID: nextID, QuestionnaireID: "q1", ParticipantID: "p1", ScheduledAt: timestamp.TimeStamp{Time: scheduledAt.Add(24 * time.Hour)}, Status: models.ScheduledQuestionnairePending},
+			}
+		}
+
+		// The next schedule fails on the ID of the completed one: the update and the result are rolled back with it.
+		failed := completion("s1")
+		if err := schedules.Complete(failed); !errors.Is(err, ErrDuplicate) {
+			t.Fatalf("Expected a duplicate next schedule.\nGot: %v", err)
+		}
+		if failed.Schedule.Version != pending.Version {
+			t.Fatalf("Unexpected version after a rollback.\nGot: %d\nExpected: %d", failed.Schedule.Version, pending.Version)
+		}
+		if found, err := schedules.FindScheduledQuestionnaireByQuestionnaireIDAndUserID("q1", "p1"); err != nil || found.ID != "s1" {
+			t.Fatalf("Expected the schedule to stay pending.\nGot: %+v, %v", found, err)
+		}
+		if _, err := results.FindByEventID("event-1"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Expected no result after a rollback.\nGot: %v", err)
+		}
+
+		completed := completion("s2")
+		if err := schedules.Complete(completed); err != nil {
+			t.Fatalf("Error completing schedule: %v", err)
+		}
+		if found, err := schedules.FindScheduledQuestionnaireByQuestionnaireIDAndUserID("q1", "p1"); err != nil || found.ID != "s2" {
+			t.Fatalf("Expected the next schedule to be pending.\nGot: %+v, %v", found, err)
+		}
+		if result, err := results.FindByEventID("event-1"); err != nil || result.ID != completed.Result.ID {
+			t.Fatalf("Unexpected result.\nGot: %+v, %v\nExpected: %s", result, err, completed.Result.ID)
+		}
+
+		if err := schedules.Complete(completion("s3")); !errors.Is(err, ErrConflict) {
+			t.Fatalf("Expected a conflict for a schedule completed already.\nGot: %v", err)
+		}
+
+		// A follow-up is only created when the participant has no pending schedule for its questionnaire.
+		followUps := &Completion{
+			Schedule: completed.Next,
+			Result:   &models.QuestionnaireResult{Answers: json.RawMessage(`{}`), QuestionnaireID: "q1", QuestionnaireVersion: 1, ParticipantID: "p1", QuestionnaireScheduleID: "s2"},
+			FollowUps: []models.ScheduledQuestionnaire{
+				{ID: "s4", QuestionnaireID: "q1", ParticipantID: "p1", ScheduledAt: timestamp.TimeStamp{Time: scheduledAt.Add(48 * time.Hour)}, Status: models.ScheduledQuestionnairePending},
+				{ID: "s5", QuestionnaireID: "q1", ParticipantID: "p1", ScheduledAt: timestamp.TimeStamp{Time: scheduledAt.Add(72 * time.Hour)}, Status: models.ScheduledQuestionnairePending},
+			},
+		}
+		followUps.Schedule.Status = models.ScheduledQuestionnaireCompleted
+		if err := schedules.Complete(followUps); err != nil {
+			t.Fatalf("Error completing schedule: %v", err)
+		}
+		if len(followUps.FollowUps) != 1 || followUps.FollowUps[0].ID != "s4" {
+			t.Fatalf("Unexpected follow-ups.\nGot: %+v\nExpected: s4", followUps.FollowUps)
+		}
+
+		// The participation only ends once the participant has no pending schedule left.
+		participants := NewParticipantStore(db, WithDialect(d))
+		for _, tt := range []struct {
+			next     *models.ScheduledQuestionnaire
+			expected models.ParticipantStatus
+		}{
+			{next: &models.ScheduledQuestionnaire{ID: "s6", QuestionnaireID: "q1", ParticipantID: "p1", ScheduledAt: timestamp.TimeStamp{Time: scheduledAt.Add(96 * time.Hour)}, Status: models.ScheduledQuestionnairePending}, expected: models.ParticipantActive},
+			{expected: models.ParticipantCompleted},
+		} {
+			schedule, err := schedules.FindScheduledQuestionnaireByQuestionnaireIDAndUserID("q1", "p1")
+			if err != nil {
+				t.Fatalf("Error finding schedule: %v", err)
+			}
+			schedule.Status = models.ScheduledQuestionnaireCompleted
+			ending := &Completion{
+				Schedule:         schedule,
+				Result:           &models.QuestionnaireResult{Answers: json.RawMessage(`{}`), QuestionnaireID: "q1", QuestionnaireVersion: 1, ParticipantID: "p1", QuestionnaireScheduleID: schedule.ID},
+				Next:             tt.next,
+				EndParticipation: true,
+			}
+			if err := schedules.Complete(ending); err != nil {
+				t.Fatalf("Error completing schedule: %v", err)
+			}
+			if participant, err := participants.FindParticipantByID("p1"); err != nil || participant.Status != tt.expected {
+				t.Fatalf("Unexpected participant status.\nGot: %+v, %v\nExpected: %v", participant, err, tt.expected)
+			}
+		}
+	})
+}
@@ -0,0 +1,311 @@
+// File: ./internals/store/store_test.go
+
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"rescheduler/internals/database"
+	"rescheduler/internals/dialect"
+	"rescheduler/internals/migrate"
+	"rescheduler/internals/models"
+	"rescheduler/internals/timestamp"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+)
+
+// testDSNEnv names, per dialect, the environment variable holding the DSN of a disposable test database.
+// Tests against a server dialect are skipped when its variable is not set, SQLite always runs on a temporary file.
+var testDSNEnv = map[dialect.Dialect]string{
+	dialect.MySQL:    "RESCHEDULER_TEST_MYSQL_DSN",
+	dialect.Postgres: "RESCHEDULER_TEST_POSTGRES_DSN",
+}
+
+// requireServersEnv names the environment variable that, when set to true, fails the tests of a server dialect
+// whose DSN is not set instead of skipping them, so CI cannot pass without running them.
+const requireServersEnv = "RESCHEDULER_TEST_REQUIRE_SERVERS"
+
+// forEachDatabase runs test once per dialect against its test database.
+// Every run starts from an empty schema: all migrations are rolled back and applied again.
+func forEachDatabase(t *testing.T, test func(t *testing.T, d dialect.Dialect, db *sql.DB)) {
+	t.Helper()
+
+	for _, d := range dialect.All() {
+		d := d
+		t.Run(string(d), func(t *testing.T) {
+			db := openTestDB(t, d)
+
+			migrator, err := migrate.New(db, d)
+			if err != nil {
+				t.Fatalf("Error loading migrations: %v", err)
+			}
+			if _, err := migrator.To(context.Background(), 0); err != nil {
+				t.Fatalf("Error resetting database: %v", err)
+			}
+			if _, err := migrator.Up(context.Background()); err != nil {
+				t.Fatalf("Error migrating database: %v", err)
+			}
+
+			test(t, d, db)
+		})
+	}
+}
+
+// openTestDB opens the test database of the dialect, skipping the test when none is configured.
+func openTestDB(t *testing.T, d dialect.Dialect) *sql.DB {
+	t.Helper()
+
+	var db *sql.DB
+	var err error
+	if d.Embedded() {
+		path := filepath.Join(t.TempDir(), "rescheduler.db")
+		db, err = database.Open(context.Background(), d, database.DatabaseConnection{Database: path})
+	} else {
+		dsn := os.Getenv(testDSNEnv[d])
+		if dsn == "" && os.Getenv(requireServersEnv) == "true" {
+			t.Fatalf("%s is not set and %s is true", testDSNEnv[d], requireServersEnv)
+		} else if dsn == "" {
+			t.Skipf("%s is not set", testDSNEnv[d])
+		}
+		db, err = sql.Open(d.DriverName(), dsn)
+	}
+	if err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// seedStudies inserts the studies the store tests reference, as questionnaires, participants and protocols
+// have foreign keys to their study on MySQL and PostgreSQL.
+func seedStudies(t *testing.T, d dialect.Dialect, db *sql.DB) {
+	t.Helper()
+
+	for _, id := range []string{"Study5", "Study6"} {
+		if _, err := db.Exec(d.Rebind("INSERT INTO studies (id, name) VALUES (?, ?)"), id, id); err != nil {
+			t.Fatalf("Error seeding study: %v", err)
+		}
+	}
+}
+
+// seed inserts the studies, participant and questionnaire the store tests work with.
+func seed(t *testing.T, d dialect.Dialect, db *sql.DB) {
+	t.Helper()
+
+	seedStudies(t, d, db)
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{"INSERT INTO participants (id, name) VALUES (?, ?)", []interface{}{"p1", "Ada"}},
+		{
+			"INSERT INTO questionnaires (id, study_id, name, questions, max_attempts, hours_between_attempts) VALUES (?, ?, ?, ?, ?, ?)",
+			[]interface{}{"q1", "Study5", "Mood", `{"questions": []}`, 3, 24},
+		},
+		{
+			"INSERT INTO questionnaire_versions (questionnaire_id, version, questions, max_attempts, hours_between_attempts, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+			[]interface{}{"q1", 1, `{"questions": []}`, 3, 24, timestamp.TimeStamp{Time: time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)}},
+		},
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(d.Rebind(statement.query), statement.args...); err != nil {
+			t.Fatalf("Error seeding database: %v", err)
+		}
+	}
+}
+
+func TestStores(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, d dialect.Dialect, db *sql.DB) {
+		seed(t, d, db)
+
+		participants := NewParticipantStore(db, WithDialect(d))
+		questionnaires := NewQuestionnaireStore(db, WithDialect(d))
+		schedules := NewScheduledQuestionnaireStore(db, WithDialect(d))
+		results := NewQuestionnaireResultStore(db, WithDialect(d))
+
+		participant, err := participants.FindParticipantByID("p1")
+		if err != nil || participant.Name != "Ada" {
+			t.Fatalf("Unexpected participant.\nGot: %+v, %v", participant, err)
+		}
+
+		questionnaire, err := questionnaires.FindQuestionnaireByIDAndStudyID("q1", "Study5")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if questionnaire.MaxAttempts.Int64 != 3 || questionnaire.HoursBetweenAttempts != 24 {
+			t.Fatalf("Unexpected questionnaire.\nGot: %+v", questionnaire)
+		}
+		if _, err := questionnaires.FindQuestionnaireByIDAndStudyID("q1", "Study6"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Expected not found for a questionnaire of another study.\nGot: %v", err)
+		}
+
+		scheduledAt := time.Date(2023, 12, 5, 2, 11, 0, 0, time.UTC)
+		schedule := &models.ScheduledQuestionnaire{
+			QuestionnaireID: "q1",
+			ParticipantID:   "p1",
+			ScheduledAt:     timestamp.TimeStamp{Time: scheduledAt},
+			Status:          models.ScheduledQuestionnairePending,
+		}
+		if err := schedules.Create(schedule); err != nil {
+			t.Fatalf("Error creating schedule: %v", err)
+		}
+
+		found, err := schedules.FindScheduledQuestionnaireByQuestionnaireIDAndUserIDAndStudyID("q1", "p1", "Study5")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if found.ID != schedule.ID || !found.ScheduledAt.Equal(scheduledAt) {
+			t.Fatalf("Unexpected schedule.\nGot: %+v\nExpected: %+v", found, schedule)
+		}
+
+		// The unique index allows a single pending schedule per participant and questionnaire.
+		duplicate := &models.ScheduledQuestionnaire{QuestionnaireID: "q1", ParticipantID: "p1", ScheduledAt: schedule.ScheduledAt, Status: models.ScheduledQuestionnairePending}
+		if err := schedules.Create(duplicate); !errors.Is(err, ErrDuplicate) {
+			t.Fatalf("Expected a duplicate for a second pending schedule.\nGot: %v", err)
+		}
+
+		stale := *found
+		found.Status = models.ScheduledQuestionnaireCompleted
+		if err := schedules.Update(found); err != nil {
+			t.Fatalf("Error updating schedule: %v", err)
+		}
+		if found.Version != 2 {
+			t.Fatalf("Unexpected version after an update.\nGot: %d\nExpected: 2", found.Version)
+		}
+
+		// A second update based on the version read before the first one is rejected.
+		stale.Status = models.ScheduledQuestionnaireCompleted
+		if err := schedules.Update(&stale); !errors.Is(err, ErrConflict) {
+			t.Fatalf("Expected a conflict for a stale version.\nGot: %v", err)
+		}
+		unknown := &models.ScheduledQuestionnaire{ID: "unknown", QuestionnaireID: "q1", ParticipantID: "p1", Status: models.ScheduledQuestionnaireCompleted, Version: 1}
+		if err := schedules.Update(unknown); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Expected not found for an unknown ID.\nGot: %v", err)
+		}
+		if _, err := schedules.FindScheduledQuestionnaireByQuestionnaireIDAndUserID("q1", "p1"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Expected no pending schedule after completing it")
+		}
+
+		result := &models.QuestionnaireResult{
+			Answers:                 json.RawMessage(`{"mood": 4,  "notes": "tired"}`),
+			QuestionnaireID:         "q1",
+			QuestionnaireVersion:    found.QuestionnaireVersion,
+			ParticipantID:           "p1",
+			QuestionnaireScheduleID: found.ID,
+			CompletedAt:             timestamp.TimeStamp{Time: scheduledAt.Add(time.Hour)},
+			EventID:                 "event-1",
+			Scores:                  map[string]float64{"total": 12, "mood": 2.5},
+		}
+		if err := results.Create(result); err != nil {
+			t.Fatalf("Error creating result: %v", err)
+		}
+		if recorded, err := results.FindByEventID("event-1"); err != nil || recorded.ID != result.ID || recorded.EventID != "event-1" {
+			t.Fatalf("Unexpected result of the event.\nGot: %+v, %v\nExpected: %s", recorded, err, result.ID)
+		}
+		if _, err := results.FindByEventID("event-2"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Expected not found for an event without a result.\nGot: %v", err)
+		}
+		again := &models.QuestionnaireResult{Answers: json.RawMessage(`{}`), QuestionnaireID: "q1", QuestionnaireVersion: 1, ParticipantID: "p1", QuestionnaireScheduleID: found.ID, EventID: "event-1"}
+		if err := results.Create(again); !errors.Is(err, ErrDuplicate) {
+			t.Fatalf("Expected a duplicate for a second result of the same event.\nGot: %v", err)
+		}
+
+		var stored models.QuestionnaireResult
+		row := db.QueryRow(d.Rebind(questionnaireResultsTable.selectFrom()+" WHERE id = ?"), result.ID)
+		if err := row.Scan(questionnaireResultFields(&stored)...); err != nil {
+			t.Fatalf("Error reading result: %v", err)
+		}
+		// SQLite keeps the answers byte for byte, the JSON columns of MySQL and PostgreSQL an equivalent document.
+		if d == dialect.SQLite && string(stored.Answers) != string(result.Answers) || !equalJSON(t, stored.Answers, result.Answers) {
+			t.Fatalf("Unexpected stored answers.\nGot: %s\nExpected: %s", stored.Answers, result.Answers)
+		}
+		if !reflect.DeepEqual(stored.Scores, result.Scores) || stored.QuestionnaireVersion != 1 {
+			t.Fatalf("Unexpected stored scores and version.\nGot: %v, %d\nExpected: %v, 1", stored.Scores, stored.QuestionnaireVersion, result.Scores)
+		}
+		if err := results.Create(&models.QuestionnaireResult{Answers: json.RawMessage(`"answer"`), QuestionnaireID: "q1", ParticipantID: "p1"}); !errors.Is(err, ErrInvalid) {
+			t.Fatalf("Expected answers that are not an object to be invalid.\nGot: %v", err)
+		}
+
+		// A result without scores is stored with NULL scores, and read back without any. Without an event ID
+		// it is stored with a NULL event ID, which any number of results share.
+		unscored := &models.QuestionnaireResult{Answers: json.RawMessage(`{}`), QuestionnaireID: "q1", QuestionnaireVersion: 1, ParticipantID: "p1", QuestionnaireScheduleID: found.ID}
+		if err := results.Create(unscored); err != nil {
+			t.Fatalf("Error creating result: %v", err)
+		}
+		stored = models.QuestionnaireResult{}
+		row = db.QueryRow(d.Rebind(questionnaireResultsTable.selectFrom()+" WHERE id = ?"), unscored.ID)
+		if err := row.Scan(questionnaireResultFields(&stored)...); err != nil || stored.Scores != nil || stored.EventID != "" {
+			t.Fatalf("Unexpected result without scores.\nGot: %+v, %v", stored, err)
+		}
+		if err := results.Create(&models.QuestionnaireResult{Answers: json.RawMessage(`{}`), QuestionnaireID: "q1", QuestionnaireVersion: 1, ParticipantID: "p1", QuestionnaireScheduleID: found.ID}); err != nil {
+			t.Fatalf("Error creating a second result without an event ID: %v", err)
+		}
+
+		// The foreign keys reject results of unknown participants.
+		orphan := &models.QuestionnaireResult{Answers: json.RawMessage(`{}`), QuestionnaireID: "q1", ParticipantID: "unknown"}
+		if err := results.Create(orphan); err == nil {
+			t.Fatalf("Expected an error for a result of an unknown participant")
+		}
+	})
+}
+
+// equalJSON reports whether two JSON documents are equal, ignoring formatting and key order.
+func equalJSON(t *testing.T, a, b []byte) bool {
+	t.Helper()
+
+	var av, bv interface{}
+	if err := json.Unmarshal(a, &av); err != nil {
+		t.Fatalf("Invalid JSON %s: %v", a, err)
+	}
+	if err := json.Unmarshal(b, &bv); err != nil {
+		t.Fatalf("Invalid JSON %s: %v", b, err)
+	}
+	return reflect.DeepEqual(av, bv)
+}
+
+func TestTimeStamp_DatabaseRoundTrip(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, d dialect.Dialect, db *sql.DB) {
+		seedStudies(t, d, db)
+		participants := NewParticipantStore(db, WithDialect(d))
+
+		// Written in UTC to the second, whatever the zone and precision of the value.
+		enrolledAt := time.Date(2023, 12, 4, 21, 11, 5, 750000000, time.FixedZone("UTC-5", -5*60*60))
+		expected := time.Date(2023, 12, 5, 2, 11, 5, 0, time.UTC)
+		participant := &models.Participant{ID: "p2", StudyID: "Study5", EnrolledAt: timestamp.TimeStamp{Time: enrolledAt}}
+		if err := participants.Enroll(participant); err != nil {
+			t.Fatalf("Error enrolling participant: %v", err)
+		}
+
+		found, err := participants.FindParticipantByID("p2")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !found.EnrolledAt.Equal(expected) || found.EnrolledAt.Location() != time.UTC {
+			t.Fatalf("Unexpected enrollment time.\nGot: %v\nExpected: %v", found.EnrolledAt, expected)
+		}
+
+		// The zero TimeStamp is NULL, and NULL is the zero TimeStamp or a NullTimeStamp that is not valid.
+		if _, err := db.Exec(d.Rebind("INSERT INTO participants (id, name, enrolled_at) VALUES (?, ?, ?)"), "p3", "Alan", timestamp.TimeStamp{}); err != nil {
+			t.Fatalf("Error inserting participant: %v", err)
+		}
+		var isNull bool
+		var zero timestamp.TimeStamp
+		var null timestamp.NullTimeStamp
+		query := "SELECT enrolled_at IS NULL, enrolled_at, enrolled_at FROM participants WHERE id = ?"
+		if err := db.QueryRow(d.Rebind(query), "p3").Scan(&isNull, &zero, &null); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !isNull || !zero.IsZero() || null.Valid {
+			t.Fatalf("Unexpected NULL round trip.\nGot: %v, %v, %+v", isNull, zero, null)
+		}
+	})
+}
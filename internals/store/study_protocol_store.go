@@ -0,0 +1,119 @@
+// Package store provides functionality to interact with the database for the rescheduler application.
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"rescheduler/internals/models"
+	"rescheduler/internals/protocol"
+	"rescheduler/internals/timestamp"
+)
+
+// StudyProtocolStoreInterface defines the methods expected for study protocol-related database operations.
+type StudyProtocolStoreInterface interface {
+	FindByStudyID(studyID string) (*models.StudyProtocol, error)
+	Save(studyProtocol *models.StudyProtocol) error
+}
+
+// ValidateStudyProtocol checks a study protocol before it is written, returning an error wrapping ErrInvalid
+// that lists every problem found. It is exported so that other implementations of StudyProtocolStoreInterface
+// validate the same way.
+//
+// A protocol needs a study, and its definition must be one protocol.Parse accepts.
+func ValidateStudyProtocol(studyProtocol *models.StudyProtocol) error {
+	var problems []string
+	if strings.TrimSpace(studyProtocol.StudyID) == "" {
+		problems = append(problems, "study ID is required")
+	}
+	if _, err := protocol.Parse(studyProtocol.Definition); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: protocol of study %s: %s", ErrInvalid, studyProtocol.StudyID, strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// ValidateStudyProtocolLinks checks the questionnaires a valid study protocol refers to, returning an error wrapping
+// ErrInvalid that lists every one that does not exist, was deleted or belongs to another study. It is exported
+// so that other implementations of StudyProtocolStoreInterface validate the same way.
+//
+// Parameters:
+//   - studyProtocol: A protocol that passed ValidateStudyProtocol.
+//   - find: Looks a questionnaire up like QuestionnaireStoreInterface.FindQuestionnaireByID.
+//
+// Returns:
+//   - error: ErrInvalid for a link to a questionnaire it cannot refer to, or the error of find other than ErrNotFound.
+func ValidateStudyProtocolLinks(studyProtocol *models.StudyProtocol, find func(questionnaireID string) (*models.Questionnaire, error)) error {
+	parsed, err := protocol.Parse(studyProtocol.Definition)
+	if err != nil {
+		return fmt.Errorf("%w: protocol of study %s: %v", ErrInvalid, studyProtocol.StudyID, err)
+	}
+	return validateLinks("protocol of study "+studyProtocol.StudyID, studyProtocol.StudyID, parsed.Questionnaires(), find)
+}
+
+// StudyProtocolStore implements StudyProtocolStoreInterface and is responsible for handling study protocol-related database operations.
+type StudyProtocolStore struct {
+	db   *sql.DB
+	opts Options
+}
+
+// NewStudyProtocolStore creates a new StudyProtocolStore instance with the given SQL database connection and options.
+func NewStudyProtocolStore(db *sql.DB, opts ...Option) *StudyProtocolStore {
+	return &StudyProtocolStore{db: db, opts: NewOptions(opts...)}
+}
+
+// FindByStudyID retrieves the protocol of a study.
+//
+// Returns:
+//   - *models.StudyProtocol: The protocol of the study.
+//   - error: ErrNotFound when the study has no protocol, or another error of the database query.
+func (ps *StudyProtocolStore) FindByStudyID(studyID string) (*models.StudyProtocol, error) {
+	query := studyProtocolsTable.selectFrom() + " WHERE study_id = ?"
+	row := ps.db.QueryRow(ps.opts.Dialect.Rebind(query), studyID)
+
+	var studyProtocol models.StudyProtocol
+	err := row.Scan(studyProtocolFields(&studyProtocol)...)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("study protocol %w with Study ID: %s", ErrNotFound, studyID)
+	} else if err != nil {
+		return nil, classifyError(err)
+	}
+
+	return &studyProtocol, nil
+}
+
+// Save validates a study protocol and writes it, creating the protocol of the study or replacing it.
+// The new protocol applies to the completions processed from then on, schedules already created are kept.
+//
+// Parameters:
+//   - studyProtocol: A pointer to a StudyProtocol struct containing the protocol to write.
+//
+// Returns:
+//   - error: ErrInvalid when the protocol fails ValidateStudyProtocol or ValidateStudyProtocolLinks,
+//     or another error of the database operation.
+//
+// UpdatedAt is set to the current time of the store's clock, on the struct too.
+func (ps *StudyProtocolStore) Save(studyProtocol *models.StudyProtocol) error {
+	if err := ValidateStudyProtocol(studyProtocol); err != nil {
+		return err
+	}
+	questionnaires := &QuestionnaireStore{db: ps.db, opts: ps.opts}
+	if err := ValidateStudyProtocolLinks(studyProtocol, questionnaires.FindQuestionnaireByID); err != nil {
+		return err
+	}
+	studyProtocol.UpdatedAt = timestamp.TimeStamp{Time: ps.opts.Clock.Now()}
+
+	// The dialects have no common upsert, so a protocol that already exists is replaced by an update instead.
+	_, err := ps.db.Exec(ps.opts.Dialect.Rebind(studyProtocolsTable.insert()), studyProtocolValues(studyProtocol)...)
+	if err = classifyError(err); !errors.Is(err, ErrDuplicate) {
+		return err
+	}
+	args := studyProtocolsTable.updateArgs(studyProtocolValues(studyProtocol))
+	_, err = ps.db.Exec(ps.opts.Dialect.Rebind(studyProtocolsTable.update()), args...)
+	return classifyError(err)
+}
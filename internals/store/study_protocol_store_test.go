@@ -0,0 +1,72 @@
+// File: ./internals/store/study_protocol_store_test.go
+
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"rescheduler/internals/clock"
+	"rescheduler/internals/dialect"
+	"rescheduler/internals/models"
+)
+
+func TestStudyProtocolStore(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, d dialect.Dialect, db *sql.DB) {
+		seedStudies(t, d, db)
+		now := time.Date(2023, 12, 5, 9, 0, 0, 0, time.UTC)
+		fakeClock := clock.NewFake(now)
+		protocols := NewStudyProtocolStore(db, WithDialect(d), WithClock(fakeClock))
+		questionnaires := NewQuestionnaireStore(db, WithDialect(d))
+		for _, questionnaire := range []*models.Questionnaire{
+			{ID: "q1", StudyID: "Study5", Name: "Mood", Questions: `{}`, HoursBetweenAttempts: 24},
+			{ID: "q2", StudyID: "Study5", Name: "Sleep", Questions: `{}`, HoursBetweenAttempts: 24},
+			{ID: "q3", StudyID: "Study6", Name: "Pain", Questions: `{}`, HoursBetweenAttempts: 24},
+			{ID: "q4", StudyID: "Study5", Name: "Retired", Questions: `{}`, HoursBetweenAttempts: 24},
+		} {
+			if err := questionnaires.Create(questionnaire); err != nil {
+				t.Fatalf("Error creating questionnaire: %v", err)
+			}
+		}
+		if err := questionnaires.Delete("q4"); err != nil {
+			t.Fatalf("Error deleting questionnaire: %v", err)
+		}
+
+		if _, err := protocols.FindByStudyID("Study5"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Expected not found for a study without a protocol.\nGot: %v", err)
+		}
+
+		first := &models.StudyProtocol{StudyID: "Study5", Definition: `{"links": [{"after": "q1", "schedule": "q2", "in_hours": 48}]}`}
+		if err := protocols.Save(first); err != nil || !first.UpdatedAt.Equal(now) {
+			t.Fatalf("Error saving protocol: %v, updated at %v", err, first.UpdatedAt)
+		}
+
+		// Saving the protocol of the same study again replaces it.
+		fakeClock.Advance(time.Hour)
+		second := &models.StudyProtocol{StudyID: "Study5", Definition: `{"sequences": [{"id": "visit-1", "questionnaires": ["q1", "q2"]}]}`}
+		if err := protocols.Save(second); err != nil {
+			t.Fatalf("Error replacing protocol: %v", err)
+		}
+		found, err := protocols.FindByStudyID("Study5")
+		if err != nil || !equalJSON(t, []byte(found.Definition), []byte(second.Definition)) || !found.UpdatedAt.Equal(now.Add(time.Hour)) {
+			t.Fatalf("Unexpected protocol.\nGot: %+v, %v\nExpected: %+v", found, err, second)
+		}
+
+		for _, definition := range []string{
+			`{"links": [{"after": "q1", "schedule": "q1"}]}`,
+			`{"links": [{"after": "q1", "schedule": "q3"}]}`,
+			`{"links": [{"after": "q4", "schedule": "q2"}]}`,
+			`{"sequences": [{"id": "visit-1", "questionnaires": ["q1", "q5"]}]}`,
+		} {
+			invalid := &models.StudyProtocol{StudyID: "Study5", Definition: definition}
+			if err := protocols.Save(invalid); !errors.Is(err, ErrInvalid) {
+				t.Fatalf("Expected an invalid protocol for %s.\nGot: %v", definition, err)
+			}
+		}
+		if found, err := protocols.FindByStudyID("Study5"); err != nil || !equalJSON(t, []byte(found.Definition), []byte(second.Definition)) {
+			t.Fatalf("Expected the protocol to be kept.\nGot: %+v, %v", found, err)
+		}
+	})
+}
@@ -0,0 +1,173 @@
+// Package store provides functionality to interact with the database for the rescheduler application.
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"rescheduler/internals/models"
+)
+
+// StudyStoreInterface defines the methods expected for study-related database operations.
+// A study is closed by updating its status to models.StudyClosed.
+type StudyStoreInterface interface {
+	FindStudyByID(studyID string) (*models.Study, error)
+	Create(study *models.Study) error
+	Update(study *models.Study) error
+}
+
+// maxStudyName is the length of the name column.
+const maxStudyName = 128
+
+// ValidateStudy checks a study before it is written, returning an error wrapping ErrInvalid
+// that lists every problem found. It is exported so that other implementations of StudyStoreInterface
+// validate the same way.
+//
+// A study needs a name of at most 128 characters, a known status and a time zone known to the time zone database.
+// EndsAt, when both dates are set, is after StartsAt. HoursBetweenAttempts is at least 1 and MaxAttempts, when set,
+// is at least 1, as they are for a questionnaire. ParticipationDays, when set, is at least 1.
+func ValidateStudy(study *models.Study) error {
+	var problems []string
+	if strings.TrimSpace(study.Name) == "" {
+		problems = append(problems, "name is required")
+	} else if len(study.Name) > maxStudyName {
+		problems = append(problems, fmt.Sprintf("name is longer than %d characters", maxStudyName))
+	}
+	if study.Status != models.StudyActive && study.Status != models.StudyClosed {
+		problems = append(problems, fmt.Sprintf("unknown status %q", study.Status))
+	}
+	if study.StartsAt.Valid && study.EndsAt.Valid && !study.EndsAt.TimeStamp.After(study.StartsAt.TimeStamp.Time) {
+		problems = append(problems, "end date must be after the start date")
+	}
+	if !knownTimeZone(study.TimeZone) {
+		problems = append(problems, fmt.Sprintf("unknown time zone %q", study.TimeZone))
+	}
+	if study.HoursBetweenAttempts < 1 {
+		problems = append(problems, fmt.Sprintf("hours between attempts must be at least 1, got %d", study.HoursBetweenAttempts))
+	}
+	if study.MaxAttempts.Valid && study.MaxAttempts.Int64 < 1 {
+		problems = append(problems, fmt.Sprintf("max attempts must be at least 1, got %d", study.MaxAttempts.Int64))
+	}
+	if study.ParticipationDays.Valid && study.ParticipationDays.Int64 < 1 {
+		problems = append(problems, fmt.Sprintf("participation days must be at least 1, got %d", study.ParticipationDays.Int64))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: study %s: %s", ErrInvalid, study.ID, strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// PrepareStudy fills in the defaults of a study about to be created, as described on StudyStore.Create.
+// It is exported so that other implementations of StudyStoreInterface create studies the same way.
+func PrepareStudy(study *models.Study, opts Options) {
+	if study.ID == "" {
+		study.ID = opts.IDs.NewID()
+	}
+	if study.Status == "" {
+		study.Status = models.StudyActive
+	}
+	if study.TimeZone == "" {
+		study.TimeZone = "UTC"
+	}
+	if study.HoursBetweenAttempts == 0 {
+		study.HoursBetweenAttempts = 24
+	}
+}
+
+// ApplyStudyDefaults fills in the scheduling defaults of its study on a questionnaire created without
+// hours between attempts: the hours between attempts of the study and, unless the questionnaire sets them,
+// its max attempts. A questionnaire with hours between attempts is left as it is.
+func ApplyStudyDefaults(questionnaire *models.Questionnaire, study *models.Study) {
+	if questionnaire.HoursBetweenAttempts != 0 {
+		return
+	}
+	questionnaire.HoursBetweenAttempts = study.HoursBetweenAttempts
+	if !questionnaire.MaxAttempts.Valid {
+		questionnaire.MaxAttempts = study.MaxAttempts
+	}
+}
+
+// StudyStore implements StudyStoreInterface and is responsible for handling study-related database operations.
+type StudyStore struct {
+	db   *sql.DB
+	opts Options
+}
+
+// NewStudyStore creates a new StudyStore instance with the given SQL database connection and options.
+func NewStudyStore(db *sql.DB, opts ...Option) *StudyStore {
+	return &StudyStore{db: db, opts: NewOptions(opts...)}
+}
+
+// FindStudyByID retrieves a study by its ID.
+//
+// Returns:
+//   - *models.Study: The study found.
+//   - error: ErrNotFound when no study has the ID, or another error of the database query.
+func (ss *StudyStore) FindStudyByID(studyID string) (*models.Study, error) {
+	query := studiesTable.selectFrom() + " WHERE id = ?"
+	row := ss.db.QueryRow(ss.opts.Dialect.Rebind(query), studyID)
+
+	var study models.Study
+	err := row.Scan(studyFields(&study)...)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("study %w with ID: %s", ErrNotFound, studyID)
+	} else if err != nil {
+		return nil, classifyError(err)
+	}
+
+	return &study, nil
+}
+
+// Create validates a study and inserts it into the "studies" table.
+//
+// Parameters:
+//   - study: A pointer to a Study struct containing the data to be inserted.
+//
+// Returns:
+//   - error: ErrInvalid when the study fails ValidateStudy, ErrDuplicate when the ID is taken,
+//     or another error of the database operation.
+//
+// If the study has no ID, one is generated with the store's ID generator. An empty status is active,
+// an empty time zone is "UTC" and zero hours between attempts are 24. All of them are set on the struct.
+func (ss *StudyStore) Create(study *models.Study) error {
+	PrepareStudy(study, ss.opts)
+	if err := ValidateStudy(study); err != nil {
+		return err
+	}
+
+	_, err := ss.db.Exec(ss.opts.Dialect.Rebind(studiesTable.insert()), studyValues(study)...)
+	return classifyError(err)
+}
+
+// Update validates a study and writes every field to the record with the same ID.
+// Closing a study, or reopening it, is an update of its status.
+//
+// Parameters:
+//   - study: A pointer to a Study struct containing the updated data.
+//
+// Returns:
+//   - error: ErrInvalid when the study fails ValidateStudy, ErrNotFound when no study has the ID,
+//     or another error of the database operation.
+func (ss *StudyStore) Update(study *models.Study) error {
+	if err := ValidateStudy(study); err != nil {
+		return err
+	}
+
+	res, err := ss.db.Exec(ss.opts.Dialect.Rebind(studiesTable.update()), studiesTable.updateArgs(studyValues(study))...)
+	if err != nil {
+		return classifyError(err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return classifyError(err)
+	}
+	if affected == 0 {
+		// MySQL counts changed rows rather than matched ones, so an update writing the stored values changes none.
+		_, err := ss.FindStudyByID(study.ID)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+// File: ./internals/store/study_store_test.go
+
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"rescheduler/internals/dialect"
+	"rescheduler/internals/idgen"
+	"rescheduler/internals/models"
+	"rescheduler/internals/timestamp"
+)
+
+func TestStudyStore(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, d dialect.Dialect, db *sql.DB) {
+		studies := NewStudyStore(db, WithDialect(d), WithIDGenerator(idgen.NewSequence("study")))
+
+		if _, err := studies.FindStudyByID("Study9"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Expected not found for an unknown study.\nGot: %v", err)
+		}
+
+		study := &models.Study{
+			Name:              "Sleep study",
+			StartsAt:          timestamp.NewNullTimeStamp(timestamp.TimeStamp{Time: time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)}),
+			EndsAt:            timestamp.NewNullTimeStamp(timestamp.TimeStamp{Time: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}),
+			ParticipationDays: sql.NullInt64{Int64: 84, Valid: true},
+		}
+		if err := studies.Create(study); err != nil {
+			t.Fatalf("Error creating study: %v", err)
+		}
+		expected := models.Study{
+			ID:                   "study-1",
+			Name:                 "Sleep study",
+			Status:               models.StudyActive,
+			StartsAt:             study.StartsAt,
+			EndsAt:               study.EndsAt,
+			TimeZone:             "UTC",
+			HoursBetweenAttempts: 24,
+			ParticipationDays:    study.ParticipationDays,
+		}
+		found, err := studies.FindStudyByID("study-1")
+		if err != nil || !equalStudies(found, &expected) {
+			t.Fatalf("Unexpected study.\nGot: %+v, %v\nExpected: %+v", found, err, expected)
+		}
+
+		// Closing a study is an update of its status, writing the same values again is not an error.
+		found.Status = models.StudyClosed
+		for i := 0; i < 2; i++ {
+			if err := studies.Update(found); err != nil {
+				t.Fatalf("Error closing study: %v", err)
+			}
+		}
+		if closed, err := studies.FindStudyByID("study-1"); err != nil || closed.Status != models.StudyClosed {
+			t.Fatalf("Expected a closed study.\nGot: %+v, %v", closed, err)
+		}
+
+		if err := studies.Update(&models.Study{ID: "Study9", Name: "Unknown", Status: models.StudyActive, TimeZone: "UTC", HoursBetweenAttempts: 24}); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Expected not found when updating an unknown study.\nGot: %v", err)
+		}
+		if err := studies.Create(&models.Study{ID: "study-1", Name: "Again"}); !errors.Is(err, ErrDuplicate) {
+			t.Fatalf("Expected a duplicate study.\nGot: %v", err)
+		}
+	})
+}
+
+func TestValidateStudy(t *testing.T) {
+	valid := models.Study{ID: "s1", Name: "Sleep study", Status: models.StudyActive, TimeZone: "UTC", HoursBetweenAttempts: 24}
+	tests := []struct {
+		name   string
+		change func(study *models.Study)
+		valid  bool
+	}{
+		{name: "Valid", change: func(study *models.Study) {}, valid: true},
+		{name: "Missing name", change: func(study *models.Study) { study.Name = " " }},
+		{name: "Unknown status", change: func(study *models.Study) { study.Status = "paused" }},
+		{name: "Unknown time zone", change: func(study *models.Study) { study.TimeZone = "Local" }},
+		{name: "No hours between attempts", change: func(study *models.Study) { study.HoursBetweenAttempts = 0 }},
+		{name: "No attempts", change: func(study *models.Study) { study.MaxAttempts = sql.NullInt64{Int64: 0, Valid: true} }},
+		{name: "No participation days", change: func(study *models.Study) { study.ParticipationDays = sql.NullInt64{Int64: 0, Valid: true} }},
+		{
+			name: "Ends before it starts",
+			change: func(study *models.Study) {
+				study.StartsAt = timestamp.NewNullTimeStamp(timestamp.TimeStamp{Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)})
+				study.EndsAt = timestamp.NewNullTimeStamp(timestamp.TimeStamp{Time: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			study := valid
+			tt.change(&study)
+			err := ValidateStudy(&study)
+			if tt.valid != (err == nil) || err != nil && !errors.Is(err, ErrInvalid) {
+				t.Fatalf("Unexpected validation.\nGot: %v\nExpected valid: %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestQuestionnaireStore_StudyDefaults(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, d dialect.Dialect, db *sql.DB) {
+		studies := NewStudyStore(db, WithDialect(d))
+		questionnaires := NewQuestionnaireStore(db, WithDialect(d))
+		study := &models.Study{ID: "Study8", Name: "Study 8", HoursBetweenAttempts: 12, MaxAttempts: sql.NullInt64{Int64: 3, Valid: true}}
+		if err := studies.Create(study); err != nil {
+			t.Fatalf("Error creating study: %v", err)
+		}
+
+		tests := []struct {
+			name          string
+			questionnaire models.Questionnaire
+			hours         int
+			maxAttempts   sql.NullInt64
+			invalid       bool
+		}{
+			{name: "Defaults of the study", questionnaire: models.Questionnaire{StudyID: "Study8"}, hours: 12, maxAttempts: study.MaxAttempts},
+			{
+				name:          "Own max attempts",
+				questionnaire: models.Questionnaire{StudyID: "Study8", MaxAttempts: sql.NullInt64{Int64: 1, Valid: true}},
+				hours:         12,
+				maxAttempts:   sql.NullInt64{Int64: 1, Valid: true},
+			},
+			{name: "Own hours between attempts", questionnaire: models.Questionnaire{StudyID: "Study8", HoursBetweenAttempts: 48}, hours: 48},
+			{name: "Study not in the store", questionnaire: models.Questionnaire{StudyID: "Study9"}, invalid: true},
+		}
+
+		for i, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				questionnaire := tt.questionnaire
+				questionnaire.ID = "defaults-" + string(rune('a'+i))
+				questionnaire.Name = tt.name
+				err := questionnaires.Create(&questionnaire)
+				if tt.invalid {
+					if !errors.Is(err, ErrInvalid) {
+						t.Fatalf("Expected an invalid questionnaire.\nGot: %v", err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("Error creating questionnaire: %v", err)
+				}
+				found, err := questionnaires.FindQuestionnaireByID(questionnaire.ID)
+				if err != nil || found.HoursBetweenAttempts != tt.hours || found.MaxAttempts != tt.maxAttempts {
+					t.Fatalf("Unexpected settings.\nGot: %+v, %v\nExpected: %d hours, max attempts %v", found, err, tt.hours, tt.maxAttempts)
+				}
+			})
+		}
+	})
+}
+
+func equalStudies(a, b *models.Study) bool {
+	return a.ID == b.ID && a.Name == b.Name && a.Status == b.Status && a.TimeZone == b.TimeZone &&
+		a.HoursBetweenAttempts == b.HoursBetweenAttempts && a.MaxAttempts == b.MaxAttempts && a.ParticipationDays == b.ParticipationDays &&
+		a.StartsAt.Valid == b.StartsAt.Valid && a.StartsAt.TimeStamp.Equal(b.StartsAt.TimeStamp.Time) &&
+		a.EndsAt.Valid == b.EndsAt.Valid && a.EndsAt.TimeStamp.Equal(b.EndsAt.TimeStamp.Time)
+}
@@ -0,0 +1,495 @@
+// File: ./internals/storetest/memstore/memstore_test.go
+
+package memstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"rescheduler/internals/models"
+	"rescheduler/internals/store"
+	"rescheduler/internals/timestamp"
+)
+
+func TestQuestionnaireStore_Find(t *testing.T) {
+	questionnaires := NewQuestionnaireStore(models.Questionnaire{ID: "q1", StudyID: "Study5", Name: "Mood"})
+
+	tests := []struct {
+		name    string
+		find    func() (*models.Questionnaire, error)
+		wantErr bool
+	}{
+		{
+			name: "By ID",
+			find: func() (*models.Questionnaire, error) { return questionnaires.FindQuestionnaireByID("q1") },
+		},
+		{
+			name:    "Unknown ID",
+			find:    func() (*models.Questionnaire, error) { return questionnaires.FindQuestionnaireByID("missing") },
+			wantErr: true,
+		},
+		{
+			name: "By ID and study",
+			find: func() (*models.Questionnaire, error) {
+				return questionnaires.FindQuestionnaireByIDAndStudyID("q1", "Study5")
+			},
+		},
+		{
+			name: "Wrong study",
+			find: func() (*models.Questionnaire, error) {
+				return questionnaires.FindQuestionnaireByIDAndStudyID("q1", "Study6")
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			questionnaire, err := tt.find()
+			if tt.wantErr {
+				if !errors.Is(err, store.ErrNotFound) {
+					t.Fatalf("Expected not found, got questionnaire %+v, %v", questionnaire, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if questionnaire.Name != "Mood" {
+				t.Fatalf("Unexpected questionnaire.\nGot: %+v", questionnaire)
+			}
+		})
+	}
+}
+
+func TestQuestionnaireStore_ListCreateUpdateDelete(t *testing.T) {
+	questionnaires := NewQuestionnaireStore(models.Questionnaire{ID: "q1", StudyID: "Study5", Name: "Mood"})
+	for _, questionnaire := range []models.Questionnaire{
+		{ID: "q3", StudyID: "Study5", Name: "Evening mood", Questions: `{}`, HoursBetweenAttempts: 24},
+		{ID: "q2", StudyID: "Study5", Name: "Sleep", Questions: `{}`, HoursBetweenAttempts: 24},
+		{ID: "q4", StudyID: "Study6", Name: "Mood", Questions: `{}`, HoursBetweenAttempts: 24},
+	} {
+		questionnaire := questionnaire
+		if err := questionnaires.Create(&questionnaire); err != nil {
+			t.Fatalf("Error creating questionnaire: %v", err)
+		}
+	}
+	if err := questionnaires.Create(&models.Questionnaire{StudyID: "Study5", Name: "Mood", Questions: `{}`}); !errors.Is(err, store.ErrInvalid) {
+		t.Fatalf("Expected an invalid questionnaire.\nGot: %v", err)
+	}
+
+	page, err := questionnaires.ListByStudy("Study5", store.QuestionnaireFilter{Limit: 2})
+	if err != nil || len(page.Questionnaires) != 2 || page.Questionnaires[0].ID != "q1" || page.NextCursor != "q2" {
+		t.Fatalf("Unexpected first page.\nGot: %+v, %v", page, err)
+	}
+	page, err = questionnaires.ListByStudy("Study5", store.QuestionnaireFilter{Limit: 2, Cursor: page.NextCursor})
+	if err != nil || len(page.Questionnaires) != 1 || page.Questionnaires[0].ID != "q3" || page.NextCursor != "" {
+		t.Fatalf("Unexpected last page.\nGot: %+v, %v", page, err)
+	}
+
+	if err := questionnaires.Delete("q1"); err != nil {
+		t.Fatalf("Error deleting questionnaire: %v", err)
+	}
+	if err := questionnaires.Update(&models.Questionnaire{ID: "q1", StudyID: "Study5", Name: "Mood", Questions: `{}`, HoursBetweenAttempts: 12}); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected not found updating a deleted questionnaire.\nGot: %v", err)
+	}
+	page, err = questionnaires.ListByStudy("Study5", store.QuestionnaireFilter{Name: "MOOD"})
+	if err != nil || len(page.Questionnaires) != 1 || page.Questionnaires[0].ID != "q3" {
+		t.Fatalf("Unexpected filtered listing.\nGot: %+v, %v", page, err)
+	}
+}
+
+func TestQuestionnaireStore_Links(t *testing.T) {
+	questionnaires := NewQuestionnaireStore(
+		models.Questionnaire{ID: "q2", StudyID: "Study5", Name: "Sleep"},
+		models.Questionnaire{ID: "q3", StudyID: "Study6", Name: "Pain"},
+		models.Questionnaire{ID: "q4", StudyID: "Study5", Name: "Retired", DeletedAt: timestamp.NewNullTimeStamp(timestamp.TimeStamp{Time: time.Now()})},
+	)
+	rules := `{"questions": [{"id": "harm", "type": "text"}], "rules": [{"when": {"answer": "harm", "op": "=", "value": "yes"}, "then": {"schedule": "%s"}}]}`
+
+	tests := []struct {
+		name      string
+		scheduled string
+		expected  error
+	}{
+		{name: "Questionnaire of another study", scheduled: "q3", expected: store.ErrInvalid},
+		{name: "Deleted questionnaire", scheduled: "q4", expected: store.ErrInvalid},
+		{name: "Missing questionnaire", scheduled: "q5", expected: store.ErrInvalid},
+		{name: "Questionnaire of the study", scheduled: "q2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			questionnaire := &models.Questionnaire{ID: "q1", StudyID: "Study5", Name: "Mood", Questions: fmt.Sprintf(rules, tt.scheduled), HoursBetweenAttempts: 24}
+			if err := questionnaires.Create(questionnaire); !errors.Is(err, tt.expected) || (tt.expected == nil) != (err == nil) {
+				t.Fatalf("Unexpected result.\nGot: %v\nExpected: %v", err, tt.expected)
+			}
+		})
+	}
+
+	updated := &models.Questionnaire{ID: "q1", StudyID: "Study5", Name: "Mood", Questions: fmt.Sprintf(rules, "q4"), HoursBetweenAttempts: 24}
+	if err := questionnaires.Update(updated); !errors.Is(err, store.ErrInvalid) {
+		t.Fatalf("Expected an invalid update.\nGot: %v", err)
+	}
+}
+
+func TestQuestionnaireStore_Versions(t *testing.T) {
+	questionnaires := NewQuestionnaireStore()
+	schedules := NewScheduledQuestionnaireStore(questionnaires)
+	questionnaire := &models.Questionnaire{ID: "q1", StudyID: "Study5", Name: "Mood", Questions: `{}`, HoursBetweenAttempts: 24}
+	if err := questionnaires.Create(questionnaire); err != nil || questionnaire.Version != 1 {
+		t.Fatalf("Expected a new questionnaire at version 1.\nGot: %d, %v", questionnaire.Version, err)
+	}
+
+	renamed := &models.Questionnaire{ID: "q1", StudyID: "Study5", Name: "Daily mood", Questions: `{ }`, HoursBetweenAttempts: 24}
+	if err := questionnaires.Update(renamed); err != nil || renamed.Version != 1 {
+		t.Fatalf("Expected renaming to keep the version.\nGot: %d, %v", renamed.Version, err)
+	}
+	changed := &models.Questionnaire{ID: "q1", StudyID: "Study5", Name: "Daily mood", Questions: `{}`, HoursBetweenAttempts: 12}
+	if err := questionnaires.Update(changed); err != nil || changed.Version != 2 {
+		t.Fatalf("Expected a new version.\nGot: %d, %v", changed.Version, err)
+	}
+
+	if version, err := questionnaires.FindVersion("q1", 1); err != nil || version.HoursBetweenAttempts != 24 {
+		t.Fatalf("Unexpected version 1.\nGot: %+v, %v", version, err)
+	}
+	if _, err := questionnaires.FindVersion("q1", 3); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected not found for an unknown version.\nGot: %v", err)
+	}
+
+	schedule := &models.ScheduledQuestionnaire{QuestionnaireID: "q1", ParticipantID: "p1", Status: models.ScheduledQuestionnairePending}
+	if err := schedules.Create(schedule); err != nil || schedule.QuestionnaireVersion != 2 {
+		t.Fatalf("Expected a schedule of the current version.\nGot: %d, %v", schedule.QuestionnaireVersion, err)
+	}
+}
+
+func TestQuestionnaireStore_StudyDefaults(t *testing.T) {
+	studies := NewStudyStore()
+	if err := studies.Create(&models.Study{ID: "Study5", Name: "Study 5", HoursBetweenAttempts: 12}); err != nil {
+		t.Fatalf("Error creating study: %v", err)
+	}
+	questionnaires := NewQuestionnaireStore().WithStudies(studies)
+
+	questionnaire := &models.Questionnaire{ID: "q1", StudyID: "Study5", Name: "Mood", Questions: `{}`}
+	if err := questionnaires.Create(questionnaire); err != nil || questionnaire.HoursBetweenAttempts != 12 {
+		t.Fatalf("Expected the hours between attempts of the study.\nGot: %d, %v", questionnaire.HoursBetweenAttempts, err)
+	}
+	other := &models.Questionnaire{ID: "q2", StudyID: "Study6", Name: "Pain", Questions: `{}`}
+	if err := questionnaires.Create(other); !errors.Is(err, store.ErrInvalid) {
+		t.Fatalf("Expected a questionnaire without study defaults to be invalid.\nGot: %v", err)
+	}
+}
+
+func TestStudyProtocolStore_Save(t *testing.T) {
+	questionnaires := NewQuestionnaireStore(
+		models.Questionnaire{ID: "q1", StudyID: "Study5", Name: "Mood"},
+		models.Questionnaire{ID: "q2", StudyID: "Study5", Name: "Sleep"},
+		models.Questionnaire{ID: "q3", StudyID: "Study6", Name: "Pain"},
+	)
+	if err := NewStudyProtocolStore().Save(&models.StudyProtocol{StudyID: "Study5", Definition: `{}`}); err == nil {
+		t.Fatalf("Expected an error without a questionnaire store")
+	}
+	protocols := NewStudyProtocolStore().WithQuestionnaires(questionnaires)
+
+	tests := []struct {
+		name       string
+		definition string
+		expected   error
+	}{
+		{name: "Questionnaires of the study", definition: `{"links": [{"after": "q1", "schedule": "q2"}]}`},
+		{name: "Questionnaire of another study", definition: `{"links": [{"after": "q1", "schedule": "q3"}]}`, expected: store.ErrInvalid},
+		{name: "Missing questionnaire", definition: `{"sequences": [{"id": "visit-1", "questionnaires": ["q1", "q4"]}]}`, expected: store.ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			studyProtocol := &models.StudyProtocol{StudyID: "Study5", Definition: tt.definition}
+			if err := protocols.Save(studyProtocol); !errors.Is(err, tt.expected) || (tt.expected == nil) != (err == nil) {
+				t.Fatalf("Unexpected result.\nGot: %v\nExpected: %v", err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestScheduledQuestionnaireStore_FindPendingOnly(t *testing.T) {
+	questionnaires := NewQuestionnaireStore(models.Questionnaire{ID: "q1", StudyID: "Study5"})
+	schedules := NewScheduledQuestionnaireStore(questionnaires)
+	base := time.Date(2023, 12, 4, 2, 11, 0, 0, time.UTC)
+
+	for _, schedule := range []models.ScheduledQuestionnaire{
+		{ID: "done", QuestionnaireID: "q1", ParticipantID: "p1", ScheduledAt: timestamp.TimeStamp{Time: base}, Status: models.ScheduledQuestionnaireCompleted},
+		{ID: "next", QuestionnaireID: "q1", ParticipantID: "p1", ScheduledAt: timestamp.TimeStamp{Time: base.Add(24 * time.Hour)}, Status: models.ScheduledQuestionnairePending},
+		{ID: "other", QuestionnaireID: "q1", ParticipantID: "p2", ScheduledAt: timestamp.TimeStamp{Time: base}, Status: models.ScheduledQuestionnairePending},
+	} {
+		schedule := schedule
+		if err := schedules.Create(&schedule); err != nil {
+			t.Fatalf("Error creating schedule: %v", err)
+		}
+	}
+
+	schedule, err := schedules.FindScheduledQuestionnaireByQuestionnaireIDAndUserID("q1", "p1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if schedule.ID != "next" {
+		t.Fatalf("Expected the pending schedule.\nGot: %s\nExpected: next", schedule.ID)
+	}
+
+	if _, err := schedules.FindScheduledQuestionnaireByQuestionnaireIDAndUserIDAndStudyID("q1", "p1", "Study5"); err != nil {
+		t.Fatalf("Unexpected error for study scoped lookup: %v", err)
+	}
+	if _, err := schedules.FindScheduledQuestionnaireByQuestionnaireIDAndUserIDAndStudyID("q1", "p1", "Study6"); err == nil {
+		t.Fatalf("Expected an error for a schedule in another study")
+	}
+
+	// Completing the pending schedule leaves nothing to find.
+	schedule.Status = models.ScheduledQuestionnaireCompleted
+	if err := schedules.Update(schedule); err != nil {
+		t.Fatalf("Error updating schedule: %v", err)
+	}
+	if _, err := schedules.FindScheduledQuestionnaireByQuestionnaireIDAndUserID("q1", "p1"); err == nil {
+		t.Fatalf("Expected a not found error once no schedule is pending")
+	}
+}
+
+func TestScheduledQuestionnaireStore_OnePendingPerParticipant(t *testing.T) {
+	schedules := NewScheduledQuestionnaireStore(nil)
+	first := models.ScheduledQuestionnaire{ID: "s1", QuestionnaireID: "q1", ParticipantID: "p1", Status: models.ScheduledQuestionnairePending}
+	second := models.ScheduledQuestionnaire{ID: "s2", QuestionnaireID: "q1", ParticipantID: "p1", Status: models.ScheduledQuestionnairePending}
+
+	if err := schedules.Create(&first); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := schedules.Create(&second); !errors.Is(err, store.ErrDuplicate) {
+		t.Fatalf("Expected a duplicate for a second pending schedule.\nGot: %v", err)
+	}
+
+	first.Status = models.ScheduledQuestionnaireCompleted
+	if err := schedules.Update(&first); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := schedules.Create(&second); err != nil {
+		t.Fatalf("Expected a new pending schedule once the previous one is completed: %v", err)
+	}
+}
+
+func TestScheduledQuestionnaireStore_UpdateConflict(t *testing.T) {
+	schedules := NewScheduledQuestionnaireStore(nil)
+	schedule := models.ScheduledQuestionnaire{ID: "s1", QuestionnaireID: "q1", ParticipantID: "p1", Status: models.ScheduledQuestionnairePending}
+	if err := schedules.Create(&schedule); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	first, second := schedule, schedule
+	first.Status = models.ScheduledQuestionnaireCompleted
+	if err := schedules.Update(&first); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if first.Version != 2 {
+		t.Fatalf("Unexpected version.\nGot: %d\nExpected: 2", first.Version)
+	}
+
+	second.Status = models.ScheduledQuestionnaireCompleted
+	if err := schedules.Update(&second); !errors.Is(err, store.ErrConflict) {
+		t.Fatalf("Expected a conflict for a stale version.\nGot: %v", err)
+	}
+	unknown := models.ScheduledQuestionnaire{ID: "s2", Version: 1}
+	if err := schedules.Update(&unknown); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected not found for an unknown ID.\nGot: %v", err)
+	}
+}
+
+func TestScheduledQuestionnaireStore_CreateDuplicateID(t *testing.T) {
+	schedules := NewScheduledQuestionnaireStore(nil)
+	schedule := models.ScheduledQuestionnaire{ID: "s1", Status: models.ScheduledQuestionnairePending}
+
+	if err := schedules.Create(&schedule); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := schedules.Create(&schedule); !errors.Is(err, store.ErrDuplicate) {
+		t.Fatalf("Expected a duplicate when creating a duplicate ID.\nGot: %v", err)
+	}
+}
+
+func TestScheduledQuestionnaireStore_Complete(t *testing.T) {
+	results := NewQuestionnaireResultStore()
+	schedules := NewScheduledQuestionnaireStore(nil).WithResults(results)
+	pending := models.ScheduledQuestionnaire{ID: "s1", QuestionnaireID: "q1", ParticipantID: "p1", Status: models.ScheduledQuestionnairePending}
+	if err := schedules.Create(&pending); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	completion := func(answers string) *store.Completion {
+		schedule := pending
+		schedule.Status = models.ScheduledQuestionnaireCompleted
+		return &store.Completion{
+			Schedule:  &schedule,
+			Result:    &models.QuestionnaireResult{ID: "r1", Answers: json.RawMessage(answers)},
+			Next:      &models.ScheduledQuestionnaire{ID: "s2", QuestionnaireID: "q1", ParticipantID: "p1", Status: models.ScheduledQuestionnairePending},
+			FollowUps: []models.ScheduledQuestionnaire{{ID: "s3", QuestionnaireID: "q2", ParticipantID: "p1", Status: models.ScheduledQuestionnairePending}},
+		}
+	}
+
+	// Invalid answers fail the result, written last: the schedules written before it are restored.
+	if err := schedules.Complete(completion(`"answer"`)); !errors.Is(err, store.ErrInvalid) {
+		t.Fatalf("Expected invalid answers.\nGot: %v", err)
+	}
+	if all := schedules.All(); len(all) != 1 || all[0].Status != models.ScheduledQuestionnairePending || all[0].Version != 1 || len(results.All()) != 0 {
+		t.Fatalf("Expected nothing to change.\nGot: %+v, %d results", all, len(results.All()))
+	}
+
+	completed := completion(`{}`)
+	if err := schedules.Complete(completed); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(schedules.All()) != 3 || len(results.All()) != 1 || len(completed.FollowUps) != 1 {
+		t.Fatalf("Unexpected records.\nGot: %+v, %d results, follow-ups %+v", schedules.All(), len(results.All()), completed.FollowUps)
+	}
+	if err := schedules.Complete(completion(`{}`)); !errors.Is(err, store.ErrConflict) {
+		t.Fatalf("Expected a conflict for a schedule completed already.\nGot: %v", err)
+	}
+}
+
+func TestScheduledQuestionnaireStore_CompleteEndsParticipation(t *testing.T) {
+	results := NewQuestionnaireResultStore()
+	schedules := NewScheduledQuestionnaireStore(nil).WithResults(results)
+	participants := NewParticipantStore(models.Participant{ID: "p1", Status: models.ParticipantActive}).WithSchedules(schedules)
+	schedules.WithParticipants(participants)
+	for _, schedule := range []models.ScheduledQuestionnaire{
+		{ID: "s1", QuestionnaireID: "q1", ParticipantID: "p1", Status: models.ScheduledQuestionnairePending},
+		{ID: "s2", QuestionnaireID: "q2", ParticipantID: "p1", Status: models.ScheduledQuestionnairePending},
+	} {
+		schedule := schedule
+		if err := schedules.Create(&schedule); err != nil {
+			t.Fatalf("Error creating schedule: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name            string
+		questionnaireID string
+		expected        models.ParticipantStatus
+	}{
+		{name: "Another schedule pending", questionnaireID: "q1", expected: models.ParticipantActive},
+		{name: "Last schedule pending", questionnaireID: "q2", expected: models.ParticipantCompleted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := schedules.FindScheduledQuestionnaireByQuestionnaireIDAndUserID(tt.questionnaireID, "p1")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			schedule.Status = models.ScheduledQuestionnaireCompleted
+			completion := &store.Completion{
+				Schedule:         schedule,
+				Result:           &models.QuestionnaireResult{ID: "r-" + tt.questionnaireID, Answers: json.RawMessage(`{}`)},
+				EndParticipation: true,
+			}
+			if err := schedules.Complete(completion); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if participant, _ := participants.FindParticipantByID("p1"); participant.Status != tt.expected {
+				t.Fatalf("Unexpected participant status.\nGot: %v\nExpected: %v", participant.Status, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParticipantStore_FindParticipantByID(t *testing.T) {
+	participants := NewParticipantStore(models.Participant{ID: "p1", Name: "Ada"})
+
+	participant, err := participants.FindParticipantByID("p1")
+	if err != nil || participant.Name != "Ada" {
+		t.Fatalf("Unexpected result.\nGot: %+v, %v", participant, err)
+	}
+	if _, err := participants.FindParticipantByID("p2"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected a not found error")
+	}
+}
+
+func TestParticipantStore_WithdrawCancelsPending(t *testing.T) {
+	schedules := NewScheduledQuestionnaireStore(nil)
+	participants := NewParticipantStore().WithSchedules(schedules)
+
+	participant := &models.Participant{ID: "p1", StudyID: "Study5"}
+	if err := participants.Enroll(participant); err != nil {
+		t.Fatalf("Error enrolling participant: %v", err)
+	}
+	if participant.Status != models.ParticipantActive || participant.TimeZone != "UTC" || participant.EnrolledAt.IsZero() {
+		t.Fatalf("Unexpected enrollment defaults.\nGot: %+v", participant)
+	}
+	for _, schedule := range []models.ScheduledQuestionnaire{
+		{ID: "s1", QuestionnaireID: "q1", ParticipantID: "p1", Status: models.ScheduledQuestionnairePending},
+		{ID: "s2", QuestionnaireID: "q1", ParticipantID: "p2", Status: models.ScheduledQuestionnairePending},
+	} {
+		schedule := schedule
+		if err := schedules.Create(&schedule); err != nil {
+			t.Fatalf("Error creating schedule: %v", err)
+		}
+	}
+
+	if err := participants.Resume("p1"); !errors.Is(err, store.ErrConflict) {
+		t.Fatalf("Expected a conflict resuming an active participant.\nGot: %v", err)
+	}
+	if err := participants.Withdraw("p1"); err != nil {
+		t.Fatalf("Error withdrawing participant: %v", err)
+	}
+	if err := participants.Pause("p1"); !errors.Is(err, store.ErrConflict) {
+		t.Fatalf("Expected a conflict pausing a withdrawn participant.\nGot: %v", err)
+	}
+
+	statuses := map[string]models.ScheduledQuestionnaireStatus{}
+	for _, schedule := range schedules.All() {
+		statuses[schedule.ID] = schedule.Status
+	}
+	if statuses["s1"] != models.ScheduledQuestionnaireCancelled || statuses["s2"] != models.ScheduledQuestionnairePending {
+		t.Fatalf("Unexpected statuses after withdrawal.\nGot: %v", statuses)
+	}
+}
+
+func TestQuestionnaireResultStore_ConcurrentCreate(t *testing.T) {
+	results := NewQuestionnaireResultStore()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := results.Create(&models.QuestionnaireResult{ID: fmt.Sprintf("r%d", i), Answers: json.RawMessage(`{}`)}); err != nil {
+				t.Errorf("Error creating result: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := len(results.All()); got != 50 {
+		t.Fatalf("Unexpected number of results.\nGot: %d\nExpected: 50", got)
+	}
+}
+
+func TestQuestionnaireResultStore_EventID(t *testing.T) {
+	results := NewQuestionnaireResultStore()
+	for _, result := range []models.QuestionnaireResult{
+		{ID: "r1", Answers: json.RawMessage(`{}`), EventID: "event-1"},
+		{ID: "r2", Answers: json.RawMessage(`{}`)},
+		{ID: "r3", Answers: json.RawMessage(`{}`)},
+	} {
+		if err := results.Create(&result); err != nil {
+			t.Fatalf("Error creating result: %v", err)
+		}
+	}
+
+	if found, err := results.FindByEventID("event-1"); err != nil || found.ID != "r1" {
+		t.Fatalf("Unexpected result of the event.\nGot: %+v, %v\nExpected: r1", found, err)
+	}
+	if _, err := results.FindByEventID(""); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected results without an event ID not to be found.\nGot: %v", err)
+	}
+	if err := results.Create(&models.QuestionnaireResult{ID: "r4", Answers: json.RawMessage(`{}`), EventID: "event-1"}); !errors.Is(err, store.ErrDuplicate) {
+		t.Fatalf("Expected a duplicate for a second result of the same event.\nGot: %v", err)
+	}
+}
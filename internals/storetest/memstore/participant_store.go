@@ -0,0 +1,123 @@
+package memstore
+
+import (
+	"fmt"
+	"sync"
+
+	"rescheduler/internals/models"
+	"rescheduler/internals/store"
+)
+
+var _ store.ParticipantStoreInterface = (*ParticipantStore)(nil)
+
+// ParticipantStore is an in-memory implementation of store.ParticipantStoreInterface.
+type ParticipantStore struct {
+	mu           sync.RWMutex
+	participants map[string]models.Participant
+
+	// schedules holds the schedules cancelled by Withdraw, it may be nil in which case nothing is cancelled.
+	schedules *ScheduledQuestionnaireStore
+	opts      store.Options
+}
+
+// NewParticipantStore creates an empty ParticipantStore, optionally seeded with the given participants.
+// It uses the default store options.
+func NewParticipantStore(participants ...models.Participant) *ParticipantStore {
+	ps := &ParticipantStore{participants: make(map[string]models.Participant), opts: store.NewOptions()}
+	for _, participant := range participants {
+		ps.Add(participant)
+	}
+	return ps
+}
+
+// WithSchedules sets the schedule store whose pending schedules Withdraw cancels, and returns the participant store.
+func (ps *ParticipantStore) WithSchedules(schedules *ScheduledQuestionnaireStore) *ParticipantStore {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	ps.schedules = schedules
+	return ps
+}
+
+// Add inserts or replaces a participant without validating it, so tests can seed any data.
+func (ps *ParticipantStore) Add(participant models.Participant) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	ps.participants[participant.ID] = participant
+}
+
+// FindParticipantByID retrieves a participant by their ID.
+func (ps *ParticipantStore) FindParticipantByID(participantID string) (*models.Participant, error) {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+
+	participant, ok := ps.participants[participantID]
+	if !ok {
+		return nil, fmt.Errorf("participant %w with ID: %s", store.ErrNotFound, participantID)
+	}
+
+	return &participant, nil
+}
+
+// Enroll validates and stores a new participant with the same defaults as the SQL store.
+// It returns store.ErrDuplicate if a participant with the same ID exists.
+func (ps *ParticipantStore) Enroll(participant *models.Participant) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	store.PrepareEnrollment(participant, ps.opts)
+	if err := store.ValidateParticipant(participant); err != nil {
+		return err
+	}
+	if _, ok := ps.participants[participant.ID]; ok {
+		return fmt.Errorf("%w: participant already exists with ID: %s", store.ErrDuplicate, participant.ID)
+	}
+	ps.participants[participant.ID] = *participant
+	return nil
+}
+
+// Pause suspends an active participant.
+func (ps *ParticipantStore) Pause(participantID string) error {
+	return ps.setStatus(participantID, models.ParticipantPaused, models.ParticipantActive)
+}
+
+// Resume reactivates a paused participant.
+func (ps *ParticipantStore) Resume(participantID string) error {
+	return ps.setStatus(participantID, models.ParticipantActive, models.ParticipantPaused)
+}
+
+// Withdraw withdraws an active or paused participant and cancels their pending schedules.
+func (ps *ParticipantStore) Withdraw(participantID string) error {
+	if err := ps.setStatus(participantID, models.ParticipantWithdrawn, models.ParticipantActive, models.ParticipantPaused); err != nil {
+		return err
+	}
+
+	ps.mu.RLock()
+	schedules := ps.schedules
+	ps.mu.RUnlock()
+	if schedules != nil {
+		schedules.cancelPending(participantID)
+	}
+	return nil
+}
+
+// setStatus moves a participant to the status to, provided their current status is one of from.
+// Like the SQL store, it returns store.ErrNotFound for an unknown participant and store.ErrConflict otherwise.
+func (ps *ParticipantStore) setStatus(participantID string, to models.ParticipantStatus, from ...models.ParticipantStatus) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	participant, ok := ps.participants[participantID]
+	if !ok {
+		return fmt.Errorf("participant %w with ID: %s", store.ErrNotFound, participantID)
+	}
+	for _, status := range from {
+		if participant.Status == status {
+			participant.Status = to
+			ps.participants[participantID] = participant
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: participant %s is %s and cannot become %s", store.ErrConflict, participantID, participant.Status, to)
+}
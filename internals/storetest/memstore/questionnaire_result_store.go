@@ -0,0 +1,75 @@
+package memstore
+
+import (
+	"fmt"
+	"sync"
+
+	"rescheduler/internals/models"
+	"rescheduler/internals/store"
+	"rescheduler/internals/timestamp"
+)
+
+var _ store.QuestionnaireResultStoreInterface = (*QuestionnaireResultStore)(nil)
+
+// QuestionnaireResultStore is an in-memory implementation of store.QuestionnaireResultStoreInterface.
+// Results are kept in insertion order.
+type QuestionnaireResultStore struct {
+	mu      sync.RWMutex
+	results []models.QuestionnaireResult
+	opts    store.Options
+}
+
+// NewQuestionnaireResultStore creates an empty QuestionnaireResultStore.
+func NewQuestionnaireResultStore(opts ...store.Option) *QuestionnaireResultStore {
+	return &QuestionnaireResultStore{opts: store.NewOptions(opts...)}
+}
+
+// FindByEventID retrieves the result recording the completion event with the given ID.
+func (qrs *QuestionnaireResultStore) FindByEventID(eventID string) (*models.QuestionnaireResult, error) {
+	qrs.mu.RLock()
+	defer qrs.mu.RUnlock()
+
+	for _, result := range qrs.results {
+		if result.EventID != "" && result.EventID == eventID {
+			return &result, nil
+		}
+	}
+	return nil, fmt.Errorf("questionnaire result %w with Event ID: %s", store.ErrNotFound, eventID)
+}
+
+// Create validates and stores a new questionnaire result, generating an ID and completion time if they are missing.
+// Like the SQL store, it returns store.ErrInvalid for answers that fail store.ValidateAnswers
+// and store.ErrDuplicate if a result with the same ID, or recording the same event, already exists.
+func (qrs *QuestionnaireResultStore) Create(result *models.QuestionnaireResult) error {
+	qrs.mu.Lock()
+	defer qrs.mu.Unlock()
+
+	if result.ID == "" {
+		result.ID = qrs.opts.IDs.NewID()
+	}
+	if result.CompletedAt.IsZero() {
+		result.CompletedAt = timestamp.TimeStamp{Time: qrs.opts.Clock.Now()}
+	}
+	if err := store.ValidateAnswers(result.Answers); err != nil {
+		return err
+	}
+
+	for _, existing := range qrs.results {
+		if existing.ID == result.ID {
+			return fmt.Errorf("%w: questionnaire result already exists with ID: %s", store.ErrDuplicate, result.ID)
+		}
+		if result.EventID != "" && existing.EventID == result.EventID {
+			return fmt.Errorf("%w: questionnaire result already exists with Event ID: %s", store.ErrDuplicate, result.EventID)
+		}
+	}
+	qrs.results = append(qrs.results, *result)
+	return nil
+}
+
+// All returns every stored questionnaire result in insertion order.
+func (qrs *QuestionnaireResultStore) All() []models.QuestionnaireResult {
+	qrs.mu.RLock()
+	defer qrs.mu.RUnlock()
+
+	return append([]models.QuestionnaireResult(nil), qrs.results...)
+}
@@ -0,0 +1,245 @@
+// Package memstore provides thread-safe in-memory implementations of the store interfaces.
+//
+// The stores in this package honour the same semantics as the MySQL backed stores in
+// the store package (pending-only schedule lookups, not-found errors for missing rows)
+// so that the rescheduling flow can be unit tested without a running database.
+// Records are copied on the way in and on the way out, callers never share memory with the store.
+package memstore
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"rescheduler/internals/models"
+	"rescheduler/internals/store"
+	"rescheduler/internals/timestamp"
+)
+
+var _ store.QuestionnaireStoreInterface = (*QuestionnaireStore)(nil)
+
+// QuestionnaireStore is an in-memory implementation of store.QuestionnaireStoreInterface.
+type QuestionnaireStore struct {
+	mu             sync.RWMutex
+	questionnaires map[string]models.Questionnaire
+	// versions holds the versions of each questionnaire, indexed by version number minus one.
+	versions map[string][]models.QuestionnaireVersion
+
+	// studies holds the scheduling defaults Create applies, it may be nil in which case there are none.
+	studies *StudyStore
+	opts    store.Options
+}
+
+// NewQuestionnaireStore creates an empty QuestionnaireStore, optionally seeded with the given questionnaires.
+// It uses the default store options.
+func NewQuestionnaireStore(questionnaires ...models.Questionnaire) *QuestionnaireStore {
+	qs := &QuestionnaireStore{
+		questionnaires: make(map[string]models.Questionnaire),
+		versions:       make(map[string][]models.QuestionnaireVersion),
+		opts:           store.NewOptions(),
+	}
+	for _, questionnaire := range questionnaires {
+		qs.Add(questionnaire)
+	}
+	return qs
+}
+
+// WithStudies sets the study store whose scheduling defaults Create applies, and returns the questionnaire store.
+func (qs *QuestionnaireStore) WithStudies(studies *StudyStore) *QuestionnaireStore {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+
+	qs.studies = studies
+	return qs
+}
+
+// Add inserts or replaces a questionnaire without validating it, so tests can seed any data.
+// A zero Version is set to 1. The settings of the questionnaire are recorded as that version, replacing any
+// version with the same number, so adding a questionnaire again with the next Version seeds a new version.
+func (qs *QuestionnaireStore) Add(questionnaire models.Questionnaire) {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+
+	if questionnaire.Version == 0 {
+		questionnaire.Version = 1
+	}
+	qs.questionnaires[questionnaire.ID] = questionnaire
+
+	versions := qs.versions[questionnaire.ID]
+	for len(versions) < questionnaire.Version {
+		versions = append(versions, models.QuestionnaireVersion{QuestionnaireID: questionnaire.ID, Version: len(versions) + 1})
+	}
+	versions[questionnaire.Version-1] = store.VersionOf(&questionnaire, timestamp.TimeStamp{Time: qs.opts.Clock.Now()})
+	qs.versions[questionnaire.ID] = versions
+}
+
+// FindQuestionnaireByIDAndStudyID retrieves a questionnaire by its ID and Study ID.
+func (qs *QuestionnaireStore) FindQuestionnaireByIDAndStudyID(questionnaireID, studyID string) (*models.Questionnaire, error) {
+	qs.mu.RLock()
+	defer qs.mu.RUnlock()
+
+	questionnaire, ok := qs.questionnaires[questionnaireID]
+	if !ok || questionnaire.StudyID != studyID || questionnaire.DeletedAt.Valid {
+		return nil, fmt.Errorf("questionnaire %w with ID: %s and Study ID: %s", store.ErrNotFound, questionnaireID, studyID)
+	}
+
+	return &questionnaire, nil
+}
+
+// FindQuestionnaireByID retrieves a questionnaire by its ID.
+func (qs *QuestionnaireStore) FindQuestionnaireByID(questionnaireID string) (*models.Questionnaire, error) {
+	qs.mu.RLock()
+	defer qs.mu.RUnlock()
+
+	return qs.find(questionnaireID)
+}
+
+// find is FindQuestionnaireByID without the lock, the caller must hold it.
+func (qs *QuestionnaireStore) find(questionnaireID string) (*models.Questionnaire, error) {
+	questionnaire, ok := qs.questionnaires[questionnaireID]
+	if !ok || questionnaire.DeletedAt.Valid {
+		return nil, fmt.Errorf("questionnaire %w with ID: %s", store.ErrNotFound, questionnaireID)
+	}
+
+	return &questionnaire, nil
+}
+
+// FindVersion retrieves a version of a questionnaire, even of a deleted one.
+func (qs *QuestionnaireStore) FindVersion(questionnaireID string, version int) (*models.QuestionnaireVersion, error) {
+	qs.mu.RLock()
+	defer qs.mu.RUnlock()
+
+	versions := qs.versions[questionnaireID]
+	if version < 1 || version > len(versions) {
+		return nil, fmt.Errorf("questionnaire version %w with ID: %s and version: %d", store.ErrNotFound, questionnaireID, version)
+	}
+
+	found := versions[version-1]
+	return &found, nil
+}
+
+// ListByStudy returns a page of the questionnaires of a study ordered by ID,
+// with the same filtering and cursors as the SQL store.
+func (qs *QuestionnaireStore) ListByStudy(studyID string, filter store.QuestionnaireFilter) (*store.QuestionnairePage, error) {
+	qs.mu.RLock()
+	defer qs.mu.RUnlock()
+
+	name := strings.ToLower(filter.Name)
+	page := &store.QuestionnairePage{Questionnaires: []models.Questionnaire{}}
+	for _, questionnaire := range qs.questionnaires {
+		if questionnaire.StudyID != studyID || questionnaire.DeletedAt.Valid || questionnaire.ID <= filter.Cursor {
+			continue
+		}
+		if !strings.Contains(strings.ToLower(questionnaire.Name), name) {
+			continue
+		}
+		page.Questionnaires = append(page.Questionnaires, questionnaire)
+	}
+	sort.Slice(page.Questionnaires, func(i, j int) bool {
+		return page.Questionnaires[i].ID < page.Questionnaires[j].ID
+	})
+
+	if size := filter.PageSize(); len(page.Questionnaires) > size {
+		page.Questionnaires = page.Questionnaires[:size]
+		page.NextCursor = page.Questionnaires[size-1].ID
+	}
+	return page, nil
+}
+
+// Create validates and stores a new questionnaire as its version 1, generating an ID if it has none.
+// Like the SQL store, the questionnaires its rules schedule must pass store.ValidateQuestionnaireLinks.
+// Like the SQL store, a questionnaire without hours between attempts takes the scheduling defaults of its study.
+// It returns store.ErrDuplicate if a questionnaire with the same ID exists, even a deleted one.
+func (qs *QuestionnaireStore) Create(questionnaire *models.Questionnaire) error {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+
+	if questionnaire.ID == "" {
+		questionnaire.ID = qs.opts.IDs.NewID()
+	}
+	if qs.studies != nil {
+		if study, err := qs.studies.FindStudyByID(questionnaire.StudyID); err == nil {
+			store.ApplyStudyDefaults(questionnaire, study)
+		}
+	}
+	questionnaire.DeletedAt = timestamp.NullTimeStamp{}
+	questionnaire.Version = 1
+	if err := store.ValidateQuestionnaire(questionnaire); err != nil {
+		return err
+	}
+	if err := store.ValidateQuestionnaireLinks(questionnaire, qs.find); err != nil {
+		return err
+	}
+	if _, ok := qs.questionnaires[questionnaire.ID]; ok {
+		return fmt.Errorf("%w: questionnaire already exists with ID: %s", store.ErrDuplicate, questionnaire.ID)
+	}
+	qs.questionnaires[questionnaire.ID] = *questionnaire
+	qs.versions[questionnaire.ID] = []models.QuestionnaireVersion{store.VersionOf(questionnaire, timestamp.TimeStamp{Time: qs.opts.Clock.Now()})}
+	return nil
+}
+
+// Update validates a questionnaire and replaces the stored one with the same ID, keeping its DeletedAt.
+// Like the SQL store, it creates a new version when store.ChangesVersion reports one is needed,
+// and sets Version on the struct to the current version.
+// It returns store.ErrNotFound if the ID is unknown or the questionnaire was deleted.
+func (qs *QuestionnaireStore) Update(questionnaire *models.Questionnaire) error {
+	if err := store.ValidateQuestionnaire(questionnaire); err != nil {
+		return err
+	}
+
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+
+	if err := store.ValidateQuestionnaireLinks(questionnaire, qs.find); err != nil {
+		return err
+	}
+	stored, ok := qs.questionnaires[questionnaire.ID]
+	if !ok || stored.DeletedAt.Valid {
+		return fmt.Errorf("questionnaire %w with ID: %s", store.ErrNotFound, questionnaire.ID)
+	}
+	versions := qs.versions[questionnaire.ID]
+	updated := *questionnaire
+	updated.DeletedAt = stored.DeletedAt
+	updated.Version = stored.Version
+	if store.ChangesVersion(&versions[stored.Version-1], &updated) {
+		updated.Version = len(versions) + 1
+		qs.versions[questionnaire.ID] = append(versions, store.VersionOf(&updated, timestamp.TimeStamp{Time: qs.opts.Clock.Now()}))
+	}
+	qs.questionnaires[questionnaire.ID] = updated
+	questionnaire.Version = updated.Version
+	return nil
+}
+
+// Delete soft deletes a questionnaire, setting its DeletedAt to the current time.
+// It returns store.ErrNotFound if the ID is unknown or the questionnaire was already deleted.
+func (qs *QuestionnaireStore) Delete(questionnaireID string) error {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+
+	questionnaire, ok := qs.questionnaires[questionnaireID]
+	if !ok || questionnaire.DeletedAt.Valid {
+		return fmt.Errorf("questionnaire %w with ID: %s", store.ErrNotFound, questionnaireID)
+	}
+	questionnaire.DeletedAt = timestamp.NewNullTimeStamp(timestamp.TimeStamp{Time: qs.opts.Clock.Now()})
+	qs.questionnaires[questionnaireID] = questionnaire
+	return nil
+}
+
+// studyID returns the study that owns the given questionnaire, if the questionnaire is known.
+func (qs *QuestionnaireStore) studyID(questionnaireID string) (string, bool) {
+	qs.mu.RLock()
+	defer qs.mu.RUnlock()
+
+	questionnaire, ok := qs.questionnaires[questionnaireID]
+	return questionnaire.StudyID, ok
+}
+
+// currentVersion returns the current version of the given questionnaire, if the questionnaire is known.
+func (qs *QuestionnaireStore) currentVersion(questionnaireID string) (int, bool) {
+	qs.mu.RLock()
+	defer qs.mu.RUnlock()
+
+	questionnaire, ok := qs.questionnaires[questionnaireID]
+	return questionnaire.Version, ok
+}
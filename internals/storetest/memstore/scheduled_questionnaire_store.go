@@ -0,0 +1,289 @@
+package memstore
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"rescheduler/internals/models"
+	"rescheduler/internals/store"
+)
+
+var _ store.ScheduledQuestionnaireStoreInterface = (*ScheduledQuestionnaireStore)(nil)
+
+// ScheduledQuestionnaireStore is an in-memory implementation of store.ScheduledQuestionnaireStoreInterface.
+type ScheduledQuestionnaireStore struct {
+	mu        sync.RWMutex
+	schedules map[string]models.ScheduledQuestionnaire
+
+	// questionnaires resolves the study of a schedule for the study scoped lookup.
+	questionnaires *QuestionnaireStore
+	// results holds the results created by Complete, it may be nil in which case Complete fails.
+	results *QuestionnaireResultStore
+	// participants holds the participants whose participation Complete ends, it may be nil in which case
+	// no participant is marked completed.
+	participants *ParticipantStore
+	opts         store.Options
+}
+
+// NewScheduledQuestionnaireStore creates an empty ScheduledQuestionnaireStore.
+// The questionnaire store is used to resolve the study a schedule belongs to,
+// it may be nil in which case study scoped lookups never match.
+func NewScheduledQuestionnaireStore(questionnaires *QuestionnaireStore, opts ...store.Option) *ScheduledQuestionnaireStore {
+	return &ScheduledQuestionnaireStore{
+		schedules:      make(map[string]models.ScheduledQuestionnaire),
+		questionnaires: questionnaires,
+		opts:           store.NewOptions(opts...),
+	}
+}
+
+// WithResults sets the result store in which Complete creates results, and returns the schedule store.
+func (scheduleStore *ScheduledQuestionnaireStore) WithResults(results *QuestionnaireResultStore) *ScheduledQuestionnaireStore {
+	scheduleStore.mu.Lock()
+	defer scheduleStore.mu.Unlock()
+
+	scheduleStore.results = results
+	return scheduleStore
+}
+
+// WithParticipants sets the participant store in which Complete ends participations, and returns the schedule store.
+func (scheduleStore *ScheduledQuestionnaireStore) WithParticipants(participants *ParticipantStore) *ScheduledQuestionnaireStore {
+	scheduleStore.mu.Lock()
+	defer scheduleStore.mu.Unlock()
+
+	scheduleStore.participants = participants
+	return scheduleStore
+}
+
+// FindScheduledQuestionnaireByQuestionnaireIDAndUserIDAndStudyID retrieves a pending scheduled questionnaire
+// by QuestionnaireID, UserID and the StudyID of the questionnaire.
+func (scheduleStore *ScheduledQuestionnaireStore) FindScheduledQuestionnaireByQuestionnaireIDAndUserIDAndStudyID(questionnaireID, userID, studyID string) (*models.ScheduledQuestionnaire, error) {
+	if scheduleStore.questionnaires != nil {
+		if owner, ok := scheduleStore.questionnaires.studyID(questionnaireID); ok && owner == studyID {
+			if schedule := scheduleStore.findPending(questionnaireID, userID); schedule != nil {
+				return schedule, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("scheduled questionnaire %w with Questionnaire ID: %s, User ID: %s, and Study ID: %s", store.ErrNotFound, questionnaireID, userID, studyID)
+}
+
+// FindScheduledQuestionnaireByQuestionnaireIDAndUserID retrieves a pending scheduled questionnaire by QuestionnaireID and UserID.
+// When several pending schedules exist the earliest one is returned.
+func (scheduleStore *ScheduledQuestionnaireStore) FindScheduledQuestionnaireByQuestionnaireIDAndUserID(questionnaireID string, userID string) (*models.ScheduledQuestionnaire, error) {
+	schedule := scheduleStore.findPending(questionnaireID, userID)
+	if schedule == nil {
+		return nil, fmt.Errorf("scheduled questionnaire %w with Questionnaire ID: %s, User ID: %s", store.ErrNotFound, questionnaireID, userID)
+	}
+
+	return schedule, nil
+}
+
+// ListByParticipant retrieves every scheduled questionnaire of a participant ordered by scheduled time.
+func (scheduleStore *ScheduledQuestionnaireStore) ListByParticipant(participantID string) ([]models.ScheduledQuestionnaire, error) {
+	schedules := []models.ScheduledQuestionnaire{}
+	for _, schedule := range scheduleStore.All() {
+		if schedule.ParticipantID == participantID {
+			schedules = append(schedules, schedule)
+		}
+	}
+	return schedules, nil
+}
+
+// Update replaces the stored scheduled questionnaire with the same ID and increments its version.
+// Like the SQL store, it returns store.ErrConflict when the stored version differs from the one given
+// and store.ErrNotFound when the ID is unknown.
+func (scheduleStore *ScheduledQuestionnaireStore) Update(scheduledQuestionnaire *models.ScheduledQuestionnaire) error {
+	scheduleStore.mu.Lock()
+	defer scheduleStore.mu.Unlock()
+
+	return scheduleStore.update(scheduledQuestionnaire)
+}
+
+// update runs Update. The caller must hold the write lock.
+func (scheduleStore *ScheduledQuestionnaireStore) update(scheduledQuestionnaire *models.ScheduledQuestionnaire) error {
+	stored, ok := scheduleStore.schedules[scheduledQuestionnaire.ID]
+	if !ok {
+		return fmt.Errorf("scheduled questionnaire %w with ID: %s", store.ErrNotFound, scheduledQuestionnaire.ID)
+	}
+	if stored.Version != scheduledQuestionnaire.Version {
+		return fmt.Errorf("%w: scheduled questionnaire %s at version %d, stored version is %d", store.ErrConflict, scheduledQuestionnaire.ID, scheduledQuestionnaire.Version, stored.Version)
+	}
+	if err := scheduleStore.checkOnePending(scheduledQuestionnaire); err != nil {
+		return err
+	}
+	scheduledQuestionnaire.Version++
+	scheduleStore.schedules[scheduledQuestionnaire.ID] = *scheduledQuestionnaire
+	return nil
+}
+
+// Create stores a new scheduled questionnaire, generating an ID if it has none and starting at version 1.
+// A zero QuestionnaireVersion is set to the current version of the questionnaire, 1 when the store does not know it.
+// It returns store.ErrDuplicate if a scheduled questionnaire with the same ID already exists,
+// or if it is pending and the participant already has a pending schedule for the questionnaire.
+func (scheduleStore *ScheduledQuestionnaireStore) Create(scheduledQuestionnaire *models.ScheduledQuestionnaire) error {
+	scheduleStore.mu.Lock()
+	defer scheduleStore.mu.Unlock()
+
+	return scheduleStore.create(scheduledQuestionnaire)
+}
+
+// create runs Create. The caller must hold the write lock.
+func (scheduleStore *ScheduledQuestionnaireStore) create(scheduledQuestionnaire *models.ScheduledQuestionnaire) error {
+	if scheduledQuestionnaire.ID == "" {
+		scheduledQuestionnaire.ID = scheduleStore.opts.IDs.NewID()
+	}
+	if scheduledQuestionnaire.Version == 0 {
+		scheduledQuestionnaire.Version = 1
+	}
+	if scheduledQuestionnaire.QuestionnaireVersion == 0 {
+		scheduledQuestionnaire.QuestionnaireVersion = 1
+		if scheduleStore.questionnaires != nil {
+			if version, ok := scheduleStore.questionnaires.currentVersion(scheduledQuestionnaire.QuestionnaireID); ok {
+				scheduledQuestionnaire.QuestionnaireVersion = version
+			}
+		}
+	}
+
+	if _, ok := scheduleStore.schedules[scheduledQuestionnaire.ID]; ok {
+		return fmt.Errorf("%w: scheduled questionnaire already exists with ID: %s", store.ErrDuplicate, scheduledQuestionnaire.ID)
+	}
+	if err := scheduleStore.checkOnePending(scheduledQuestionnaire); err != nil {
+		return err
+	}
+	scheduleStore.schedules[scheduledQuestionnaire.ID] = *scheduledQuestionnaire
+	return nil
+}
+
+// Complete updates the schedule, creates the follow-ups, the next schedule and the result of a completion,
+// or, like the transaction of the SQL store, changes nothing when one of them fails.
+// A follow-up whose questionnaire the participant already has a pending schedule for is left out.
+// It fails unless a result store was set with WithResults.
+func (scheduleStore *ScheduledQuestionnaireStore) Complete(completion *store.Completion) error {
+	scheduleStore.mu.Lock()
+	defer scheduleStore.mu.Unlock()
+
+	if scheduleStore.results == nil {
+		return fmt.Errorf("completing scheduled questionnaire %s: no result store, see WithResults", completion.Schedule.ID)
+	}
+
+	saved := make(map[string]models.ScheduledQuestionnaire, len(scheduleStore.schedules))
+	for id, schedule := range scheduleStore.schedules {
+		saved[id] = schedule
+	}
+	version := completion.Schedule.Version
+
+	followUps, err := scheduleStore.complete(completion)
+	if err != nil {
+		scheduleStore.schedules = saved
+		completion.Schedule.Version = version
+		return err
+	}
+	completion.FollowUps = followUps
+	return nil
+}
+
+// complete runs the writes of Complete, the result last as it cannot be undone. The caller must hold the write lock.
+func (scheduleStore *ScheduledQuestionnaireStore) complete(completion *store.Completion) ([]models.ScheduledQuestionnaire, error) {
+	if err := scheduleStore.update(completion.Schedule); err != nil {
+		return nil, err
+	}
+
+	var followUps []models.ScheduledQuestionnaire
+	for _, followUp := range completion.FollowUps {
+		if scheduleStore.checkOnePending(&followUp) != nil {
+			continue
+		}
+		if err := scheduleStore.create(&followUp); err != nil {
+			return nil, err
+		}
+		followUps = append(followUps, followUp)
+	}
+
+	if completion.Next != nil {
+		if err := scheduleStore.create(completion.Next); err != nil {
+			return nil, err
+		}
+	}
+	if err := scheduleStore.results.Create(completion.Result); err != nil {
+		return nil, err
+	}
+
+	if completion.EndParticipation && scheduleStore.participants != nil && !scheduleStore.hasPending(completion.Schedule.ParticipantID) {
+		// Like the SQL store, a participant who is not active keeps their status.
+		_ = scheduleStore.participants.setStatus(completion.Schedule.ParticipantID, models.ParticipantCompleted, models.ParticipantActive)
+	}
+	return followUps, nil
+}
+
+// hasPending reports whether the participant has a pending schedule. The caller must hold the lock.
+func (scheduleStore *ScheduledQuestionnaireStore) hasPending(participantID string) bool {
+	for _, schedule := range scheduleStore.schedules {
+		if schedule.ParticipantID == participantID && schedule.Status == models.ScheduledQuestionnairePending {
+			return true
+		}
+	}
+	return false
+}
+
+// All returns every stored scheduled questionnaire ordered by ScheduledAt, then ID.
+func (scheduleStore *ScheduledQuestionnaireStore) All() []models.ScheduledQuestionnaire {
+	scheduleStore.mu.RLock()
+	defer scheduleStore.mu.RUnlock()
+
+	schedules := make([]models.ScheduledQuestionnaire, 0, len(scheduleStore.schedules))
+	for _, schedule := range scheduleStore.schedules {
+		schedules = append(schedules, schedule)
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		if !schedules[i].ScheduledAt.Equal(schedules[j].ScheduledAt.Time) {
+			return schedules[i].ScheduledAt.Before(schedules[j].ScheduledAt.Time)
+		}
+		return schedules[i].ID < schedules[j].ID
+	})
+	return schedules
+}
+
+// checkOnePending mirrors uq_scheduled_questionnaires_one_pending: at most one pending schedule
+// per participant and questionnaire. The caller must hold the write lock.
+func (scheduleStore *ScheduledQuestionnaireStore) checkOnePending(scheduledQuestionnaire *models.ScheduledQuestionnaire) error {
+	if scheduledQuestionnaire.Status != models.ScheduledQuestionnairePending {
+		return nil
+	}
+	for id, schedule := range scheduleStore.schedules {
+		if id != scheduledQuestionnaire.ID &&
+			schedule.QuestionnaireID == scheduledQuestionnaire.QuestionnaireID &&
+			schedule.ParticipantID == scheduledQuestionnaire.ParticipantID &&
+			schedule.Status == models.ScheduledQuestionnairePending {
+			return fmt.Errorf("%w: participant %s already has a pending schedule for questionnaire %s", store.ErrDuplicate, scheduledQuestionnaire.ParticipantID, scheduledQuestionnaire.QuestionnaireID)
+		}
+	}
+	return nil
+}
+
+// findPending returns the earliest pending schedule for the questionnaire and participant, or nil.
+func (scheduleStore *ScheduledQuestionnaireStore) findPending(questionnaireID, userID string) *models.ScheduledQuestionnaire {
+	for _, schedule := range scheduleStore.All() {
+		if schedule.QuestionnaireID == questionnaireID &&
+			schedule.ParticipantID == userID &&
+			schedule.Status == models.ScheduledQuestionnairePending {
+			return &schedule
+		}
+	}
+	return nil
+}
+
+// cancelPending cancels every pending schedule of the participant, incrementing their versions.
+func (scheduleStore *ScheduledQuestionnaireStore) cancelPending(participantID string) {
+	scheduleStore.mu.Lock()
+	defer scheduleStore.mu.Unlock()
+
+	for id, schedule := range scheduleStore.schedules {
+		if schedule.ParticipantID == participantID && schedule.Status == models.ScheduledQuestionnairePending {
+			schedule.Status = models.ScheduledQuestionnaireCancelled
+			schedule.Version++
+			scheduleStore.schedules[id] = schedule
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package memstore
+
+import (
+	"fmt"
+	"sync"
+
+	"rescheduler/internals/models"
+	"rescheduler/internals/store"
+	"rescheduler/internals/timestamp"
+)
+
+var _ store.StudyProtocolStoreInterface = (*StudyProtocolStore)(nil)
+
+// StudyProtocolStore is an in-memory implementation of store.StudyProtocolStoreInterface.
+type StudyProtocolStore struct {
+	mu        sync.RWMutex
+	protocols map[string]models.StudyProtocol
+
+	// questionnaires resolves the questionnaires a saved protocol refers to, it may be nil in which case Save fails.
+	questionnaires *QuestionnaireStore
+	opts           store.Options
+}
+
+// NewStudyProtocolStore creates an empty StudyProtocolStore, optionally seeded with the given protocols.
+// It uses the default store options.
+func NewStudyProtocolStore(protocols ...models.StudyProtocol) *StudyProtocolStore {
+	ps := &StudyProtocolStore{protocols: make(map[string]models.StudyProtocol), opts: store.NewOptions()}
+	for _, studyProtocol := range protocols {
+		ps.Add(studyProtocol)
+	}
+	return ps
+}
+
+// WithQuestionnaires sets the questionnaire store against which Save checks the links of a protocol,
+// and returns the protocol store.
+func (ps *StudyProtocolStore) WithQuestionnaires(questionnaires *QuestionnaireStore) *StudyProtocolStore {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	ps.questionnaires = questionnaires
+	return ps
+}
+
+// Add inserts or replaces a protocol without validating it, so tests can seed any data.
+func (ps *StudyProtocolStore) Add(studyProtocol models.StudyProtocol) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	ps.protocols[studyProtocol.StudyID] = studyProtocol
+}
+
+// FindByStudyID retrieves the protocol of a study.
+func (ps *StudyProtocolStore) FindByStudyID(studyID string) (*models.StudyProtocol, error) {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+
+	studyProtocol, ok := ps.protocols[studyID]
+	if !ok {
+		return nil, fmt.Errorf("study protocol %w with Study ID: %s", store.ErrNotFound, studyID)
+	}
+
+	return &studyProtocol, nil
+}
+
+// Save validates a protocol and creates or replaces the protocol of its study, setting UpdatedAt to the current time.
+// Like the SQL store, the questionnaires it refers to must pass store.ValidateStudyProtocolLinks.
+// It fails unless a questionnaire store was set with WithQuestionnaires.
+func (ps *StudyProtocolStore) Save(studyProtocol *models.StudyProtocol) error {
+	if err := store.ValidateStudyProtocol(studyProtocol); err != nil {
+		return err
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if ps.questionnaires == nil {
+		return fmt.Errorf("saving protocol of study %s: no questionnaire store, see WithQuestionnaires", studyProtocol.StudyID)
+	}
+	if err := store.ValidateStudyProtocolLinks(studyProtocol, ps.questionnaires.FindQuestionnaireByID); err != nil {
+		return err
+	}
+
+	studyProtocol.UpdatedAt = timestamp.TimeStamp{Time: ps.opts.Clock.Now()}
+	ps.protocols[studyProtocol.StudyID] = *studyProtocol
+	return nil
+}
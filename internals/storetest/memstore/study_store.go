@@ -0,0 +1,83 @@
+package memstore
+
+import (
+	"fmt"
+	"sync"
+
+	"rescheduler/internals/models"
+	"rescheduler/internals/store"
+)
+
+var _ store.StudyStoreInterface = (*StudyStore)(nil)
+
+// StudyStore is an in-memory implementation of store.StudyStoreInterface.
+type StudyStore struct {
+	mu      sync.RWMutex
+	studies map[string]models.Study
+	opts    store.Options
+}
+
+// NewStudyStore creates an empty StudyStore, optionally seeded with the given studies.
+// It uses the default store options.
+func NewStudyStore(studies ...models.Study) *StudyStore {
+	ss := &StudyStore{studies: make(map[string]models.Study), opts: store.NewOptions()}
+	for _, study := range studies {
+		ss.Add(study)
+	}
+	return ss
+}
+
+// Add inserts or replaces a study without validating it, so tests can seed any data.
+func (ss *StudyStore) Add(study models.Study) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	ss.studies[study.ID] = study
+}
+
+// FindStudyByID retrieves a study by its ID.
+func (ss *StudyStore) FindStudyByID(studyID string) (*models.Study, error) {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+
+	study, ok := ss.studies[studyID]
+	if !ok {
+		return nil, fmt.Errorf("study %w with ID: %s", store.ErrNotFound, studyID)
+	}
+
+	return &study, nil
+}
+
+// Create validates and stores a new study with the same defaults as the SQL store.
+// It returns store.ErrDuplicate if a study with the same ID exists.
+func (ss *StudyStore) Create(study *models.Study) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	store.PrepareStudy(study, ss.opts)
+	if err := store.ValidateStudy(study); err != nil {
+		return err
+	}
+	if _, ok := ss.studies[study.ID]; ok {
+		return fmt.Errorf("%w: study already exists with ID: %s", store.ErrDuplicate, study.ID)
+	}
+	ss.studies[study.ID] = *study
+	return nil
+}
+
+// Update validates a study and replaces the stored one with the same ID.
+// It returns store.ErrNotFound if the ID is unknown.
+func (ss *StudyStore) Update(study *models.Study) error {
+	if err := store.ValidateStudy(study); err != nil {
+		return err
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if _, ok := ss.studies[study.ID]; !ok {
+		return fmt.Errorf("study %w with ID: %s", store.ErrNotFound, study.ID)
+	}
+	ss.studies[study.ID] = *study
+	return nil
+}
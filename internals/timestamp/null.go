@@ -0,0 +1,88 @@
+package timestamp
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+)
+
+// NullTimeStamp is a TimeStamp that may be absent, like sql.NullTime.
+// When Valid is false it is written as JSON null, empty text and SQL NULL, and TimeStamp is ignored.
+type NullTimeStamp struct {
+	TimeStamp TimeStamp
+	Valid     bool
+}
+
+// NewNullTimeStamp returns a valid NullTimeStamp of ts.
+func NewNullTimeStamp(ts TimeStamp) NullTimeStamp {
+	return NullTimeStamp{TimeStamp: ts, Valid: true}
+}
+
+// Scan implements the sql.Scanner interface, accepting the same values as TimeStamp.Scan. NULL is not valid.
+func (nt *NullTimeStamp) Scan(value interface{}) error {
+	if value == nil {
+		*nt = NullTimeStamp{}
+		return nil
+	}
+
+	var ts TimeStamp
+	if err := ts.Scan(value); err != nil {
+		return err
+	}
+	*nt = NewNullTimeStamp(ts)
+	return nil
+}
+
+// Value implements the driver.Valuer interface, writing NULL when not valid and DBLayout otherwise.
+func (nt NullTimeStamp) Value() (driver.Value, error) {
+	if !nt.Valid {
+		return nil, nil
+	}
+	return nt.TimeStamp.UTC().Format(DBLayout), nil
+}
+
+// MarshalJSON writes null when not valid and a JSON string in Layout otherwise.
+func (nt NullTimeStamp) MarshalJSON() ([]byte, error) {
+	if !nt.Valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(nt.TimeStamp.UTC().Format(Layout))
+}
+
+// UnmarshalJSON reads null as not valid and anything else as TimeStamp.UnmarshalJSON does.
+func (nt *NullTimeStamp) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(bytes.TrimSpace(b), []byte("null")) {
+		*nt = NullTimeStamp{}
+		return nil
+	}
+
+	var ts TimeStamp
+	if err := ts.UnmarshalJSON(b); err != nil {
+		return err
+	}
+	*nt = NewNullTimeStamp(ts)
+	return nil
+}
+
+// MarshalText writes empty text when not valid and Layout otherwise.
+func (nt NullTimeStamp) MarshalText() ([]byte, error) {
+	if !nt.Valid {
+		return []byte{}, nil
+	}
+	return []byte(nt.TimeStamp.UTC().Format(Layout)), nil
+}
+
+// UnmarshalText reads empty text as not valid and anything else as TimeStamp.UnmarshalText does.
+func (nt *NullTimeStamp) UnmarshalText(text []byte) error {
+	if len(bytes.TrimSpace(text)) == 0 {
+		*nt = NullTimeStamp{}
+		return nil
+	}
+
+	var ts TimeStamp
+	if err := ts.UnmarshalText(text); err != nil {
+		return err
+	}
+	*nt = NewNullTimeStamp(ts)
+	return nil
+}
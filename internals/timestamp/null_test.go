@@ -0,0 +1,94 @@
+// File: ./internals/timestamp/null_test.go
+
+package timestamp
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNullTimeStamp_JSON(t *testing.T) {
+	valid := NewNullTimeStamp(TimeStamp{Time: time.Date(2023, 12, 4, 2, 11, 0, 0, time.UTC)})
+
+	tests := []struct {
+		name   string
+		input  NullTimeStamp
+		output string
+	}{
+		{name: "Valid", input: valid, output: `{"at":"2023-12-04T02:11:00Z"}`},
+		{name: "Not valid", input: NullTimeStamp{}, output: `{"at":null}`},
+		// The TimeStamp of a NullTimeStamp that is not valid is ignored.
+		{name: "Not valid with a time", input: NullTimeStamp{TimeStamp: valid.TimeStamp}, output: `{"at":null}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := json.Marshal(struct {
+				At NullTimeStamp `json:"at"`
+			}{tt.input})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if string(b) != tt.output {
+				t.Fatalf("Unexpected JSON.\nGot: %s\nExpected: %s", b, tt.output)
+			}
+
+			// A previous value is overwritten, null included.
+			roundTripped := struct {
+				At NullTimeStamp `json:"at"`
+			}{valid}
+			if err := json.Unmarshal(b, &roundTripped); err != nil {
+				t.Fatalf("Unexpected error reading the JSON back: %v", err)
+			}
+			if roundTripped.At.Valid != tt.input.Valid || tt.input.Valid && !roundTripped.At.TimeStamp.Equal(tt.input.TimeStamp.Time) {
+				t.Fatalf("Unexpected round trip.\nGot: %+v\nExpected: %+v", roundTripped.At, tt.input)
+			}
+		})
+	}
+
+	var nt NullTimeStamp
+	if err := json.Unmarshal([]byte(`"soon"`), &nt); !errors.Is(err, ErrInvalidTimeStamp) {
+		t.Fatalf("Unexpected error.\nGot: %v\nExpected: %v", err, ErrInvalidTimeStamp)
+	}
+}
+
+func TestNullTimeStamp_SQL(t *testing.T) {
+	valid := NewNullTimeStamp(TimeStamp{Time: time.Date(2023, 12, 4, 3, 11, 0, 0, time.FixedZone("UTC+1", 60*60))})
+
+	value, err := valid.Value()
+	if err != nil || value != "2023-12-04 02:11:00" {
+		t.Fatalf("Unexpected value.\nGot: %v, %v\nExpected: %v", value, err, "2023-12-04 02:11:00")
+	}
+	var scanned NullTimeStamp
+	if err := scanned.Scan(value); err != nil || !scanned.Valid || !scanned.TimeStamp.Equal(valid.TimeStamp.Time) {
+		t.Fatalf("Unexpected round trip.\nGot: %+v, %v\nExpected: %+v", scanned, err, valid)
+	}
+
+	if value, err := (NullTimeStamp{}).Value(); err != nil || value != nil {
+		t.Fatalf("Expected NULL.\nGot: %v, %v", value, err)
+	}
+	if err := scanned.Scan(nil); err != nil || scanned.Valid {
+		t.Fatalf("Expected NULL to be scanned as not valid.\nGot: %+v, %v", scanned, err)
+	}
+	if err := scanned.Scan(3.5); !errors.Is(err, ErrInvalidTimeStamp) {
+		t.Fatalf("Unexpected error.\nGot: %v\nExpected: %v", err, ErrInvalidTimeStamp)
+	}
+}
+
+func TestNullTimeStamp_Text(t *testing.T) {
+	var nt NullTimeStamp
+	if err := nt.UnmarshalText([]byte("2023-12-04 02:11:00")); err != nil || !nt.Valid {
+		t.Fatalf("Unexpected text result.\nGot: %+v, %v", nt, err)
+	}
+	if text, err := nt.MarshalText(); err != nil || string(text) != "2023-12-04T02:11:00Z" {
+		t.Fatalf("Unexpected text.\nGot: %s, %v\nExpected: %s", text, err, "2023-12-04T02:11:00Z")
+	}
+	if err := nt.UnmarshalText([]byte("")); err != nil || nt.Valid {
+		t.Fatalf("Expected empty text to be not valid.\nGot: %+v, %v", nt, err)
+	}
+	if text, err := nt.MarshalText(); err != nil || len(text) != 0 {
+		t.Fatalf("Expected empty text.\nGot: %q, %v", text, err)
+	}
+}
@@ -4,10 +4,24 @@
 //
 // TimeStamp enhances the standard time.Time functionality and is particularly
 // useful when working with databases that require custom handling of time values.
+//
+// Values are accepted in several formats, from JSON and from every database driver, and are always normalised
+// to UTC: an offset in the input is applied, never discarded, and input without an offset is taken to be UTC.
+//
+// Values are written in exactly two formats, both in UTC: Layout for JSON and text, and DBLayout for the database.
+// Both are read back by Parse, so every TimeStamp round-trips through JSON, text and the database.
+// The zero TimeStamp is written as JSON null, empty text and SQL NULL, and read back from them.
+// NullTimeStamp is the variant for values that are optional and whose validity is tracked explicitly.
 package timestamp
 
 import (
+	"bytes"
+	"database/sql/driver"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -17,36 +31,175 @@ type TimeStamp struct {
 	time.Time
 }
 
-var layout string = "2006-01-02 15:04:05"
+// ErrInvalidTimeStamp is returned when a value cannot be read as a timestamp.
+var ErrInvalidTimeStamp = errors.New("invalid timestamp")
+
+// Layout is the canonical wire format of a TimeStamp in JSON and text: RFC 3339 in UTC,
+// with fractional seconds only when there are any, such as "2023-12-04T02:11:00Z".
+const Layout = time.RFC3339Nano
+
+// DBLayout is the format a TimeStamp is written to the database with, in UTC, to the second: the MySQL DATETIME layout,
+// which PostgreSQL TIMESTAMP columns and SQLite also accept.
+const DBLayout = "2006-01-02 15:04:05"
+
+var layout string = DBLayout
+
+// layouts are the text formats Parse accepts, tried in order. The ones without an offset are read as UTC.
+var layouts = []string{
+	Layout, // also matches RFC3339, the fraction is optional when parsing
+	"2006-01-02 15:04:05.999999999Z07:00",
+	layout + ".999999999", // the MySQL layout, with an optional fraction
+	"2006-01-02T15:04:05.999999999",
+}
+
+// epochMillisThreshold separates epoch seconds from epoch milliseconds. As seconds it is in the year 5138,
+// as milliseconds in 1973, so every realistic timestamp in either unit falls on the right side.
+const epochMillisThreshold = 100_000_000_000
+
+// Parse reads a timestamp in one of the accepted formats:
+//   - RFC 3339, with or without fractional seconds, such as "2023-12-04T02:11:00+01:00";
+//   - the MySQL layout "2006-01-02 15:04:05", with optional fractional seconds and offset;
+//   - Unix epoch seconds or milliseconds, such as "1701655860" or "1701655860000".
+//
+// The result is in UTC. An error wrapping ErrInvalidTimeStamp is returned for any other input.
+func Parse(value string) (TimeStamp, error) {
+	value = strings.TrimSpace(value)
+	if isInteger(value) {
+		epoch, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return TimeStamp{}, fmt.Errorf("%w: %q: %v", ErrInvalidTimeStamp, value, err)
+		}
+		return FromEpoch(epoch), nil
+	}
+
+	for _, l := range layouts {
+		if parsed, err := time.Parse(l, value); err == nil {
+			return TimeStamp{Time: parsed.UTC()}, nil
+		}
+	}
+	return TimeStamp{}, fmt.Errorf("%w: %q is neither RFC 3339, %q nor epoch seconds or milliseconds", ErrInvalidTimeStamp, value, layout)
+}
+
+// FromEpoch returns the timestamp of Unix epoch seconds or, from epochMillisThreshold on, milliseconds.
+func FromEpoch(epoch int64) TimeStamp {
+	if epoch >= epochMillisThreshold || epoch <= -epochMillisThreshold {
+		return TimeStamp{Time: time.UnixMilli(epoch).UTC()}
+	}
+	return TimeStamp{Time: time.Unix(epoch, 0).UTC()}
+}
+
+func isInteger(value string) bool {
+	digits := strings.TrimPrefix(value, "-")
+	if digits == "" {
+		return false
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
 
 // Scan implements the sql.Scanner interface for TimeStamp.
-// It parses the input value into a time.Time and sets it
-// as the underlying time.Time field of TimeStamp.s
+// It accepts the values database drivers return for date and time columns: time.Time (MySQL with parseTime,
+// PostgreSQL, SQLite), text as []byte or string in any format Parse accepts, and integers as epoch
+// seconds or milliseconds. NULL leaves the zero TimeStamp.
 func (ct *TimeStamp) Scan(value interface{}) error {
-	if value == nil {
+	var parsed TimeStamp
+	var err error
+	switch v := value.(type) {
+	case nil:
 		return nil
+	case time.Time:
+		parsed = TimeStamp{Time: v.UTC()}
+	case []byte:
+		parsed, err = Parse(string(v))
+	case string:
+		parsed, err = Parse(v)
+	case int64:
+		parsed = FromEpoch(v)
+	default:
+		err = fmt.Errorf("%w: unsupported type %T", ErrInvalidTimeStamp, value)
 	}
-
-	parsedTime, err := time.Parse(layout, string(value.([]byte)))
 	if err != nil {
 		return err
 	}
-	ct.Time = parsedTime
+
+	*ct = parsed
 	return nil
 }
 
+// UnmarshalJSON accepts a JSON string in any format Parse accepts, or a JSON number of epoch seconds
+// or milliseconds. null leaves the TimeStamp unchanged, like the standard library types.
 func (ct *TimeStamp) UnmarshalJSON(b []byte) error {
-	var timeStr string
-	err := json.Unmarshal(b, &timeStr)
+	b = bytes.TrimSpace(b)
+	if bytes.Equal(b, []byte("null")) {
+		return nil
+	}
+
+	var parsed TimeStamp
+	var err error
+	if len(b) > 0 && b[0] == '"' {
+		var timeStr string
+		if err := json.Unmarshal(b, &timeStr); err != nil {
+			return err
+		}
+		parsed, err = Parse(timeStr)
+	} else {
+		parsed, err = Parse(string(b))
+	}
 	if err != nil {
 		return err
 	}
 
-	parsedTime, err := time.Parse(layout, timeStr)
+	*ct = parsed
+	return nil
+}
+
+// MarshalJSON writes the TimeStamp as a JSON string in Layout, or null for the zero TimeStamp.
+func (ct TimeStamp) MarshalJSON() ([]byte, error) {
+	if ct.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(ct.UTC().Format(Layout))
+}
+
+// MarshalText writes the TimeStamp in Layout, or empty text for the zero TimeStamp.
+// It is used by encoders other than JSON, such as flag.TextVar.
+func (ct TimeStamp) MarshalText() ([]byte, error) {
+	if ct.IsZero() {
+		return []byte{}, nil
+	}
+	return []byte(ct.UTC().Format(Layout)), nil
+}
+
+// UnmarshalText reads text in any format Parse accepts. Empty text is the zero TimeStamp.
+func (ct *TimeStamp) UnmarshalText(text []byte) error {
+	if len(bytes.TrimSpace(text)) == 0 {
+		*ct = TimeStamp{}
+		return nil
+	}
+
+	parsed, err := Parse(string(text))
 	if err != nil {
 		return err
 	}
-
-	ct.Time = parsedTime
+	*ct = parsed
 	return nil
 }
+
+// Value implements the driver.Valuer interface, writing the TimeStamp in DBLayout, or NULL for the zero TimeStamp.
+// Fractional seconds are dropped, as DATETIME columns have none.
+func (ct TimeStamp) Value() (driver.Value, error) {
+	if ct.IsZero() {
+		return nil, nil
+	}
+	return ct.UTC().Format(DBLayout), nil
+}
+
+// String returns the TimeStamp in Layout, empty for the zero TimeStamp.
+func (ct TimeStamp) String() string {
+	text, _ := ct.MarshalText()
+	return string(text)
+}
@@ -4,12 +4,16 @@ package timestamp
 
 import (
 	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"reflect"
 	"testing"
 	"time"
 )
 
 func TestTimeStamp_Scan(t *testing.T) {
+	plusOne := time.FixedZone("UTC+1", 60*60)
+
 	tests := []struct {
 		name   string
 		input  interface{}
@@ -26,7 +30,7 @@ func TestTimeStamp_Scan(t *testing.T) {
 			name:   "Invalid time string",
 			input:  "invalid_time_string",
 			output: TimeStamp{},
-			err:    driver.ErrSkip, // Assuming ErrSkip is returned on scan failure
+			err:    ErrInvalidTimeStamp,
 		},
 		{
 			name:   "Nil input",
@@ -34,6 +38,37 @@ func TestTimeStamp_Scan(t *testing.T) {
 			output: TimeStamp{},
 			err:    nil,
 		},
+		{
+			name:   "MySQL bytes",
+			input:  []byte("2023-12-04 02:11:00"),
+			output: TimeStamp{Time: time.Date(2023, 12, 4, 2, 11, 0, 0, time.UTC)},
+		},
+		{
+			name:   "MySQL bytes with fraction",
+			input:  []byte("2023-12-04 02:11:00.250"),
+			output: TimeStamp{Time: time.Date(2023, 12, 4, 2, 11, 0, 250000000, time.UTC)},
+		},
+		{
+			name:   "Driver time in another zone",
+			input:  time.Date(2023, 12, 4, 3, 11, 0, 0, plusOne),
+			output: TimeStamp{Time: time.Date(2023, 12, 4, 2, 11, 0, 0, time.UTC)},
+		},
+		{
+			name:   "RFC 3339 string with offset",
+			input:  "2023-12-04T03:11:00+01:00",
+			output: TimeStamp{Time: time.Date(2023, 12, 4, 2, 11, 0, 0, time.UTC)},
+		},
+		{
+			name:   "Epoch seconds",
+			input:  int64(1701655860),
+			output: TimeStamp{Time: time.Date(2023, 12, 4, 2, 11, 0, 0, time.UTC)},
+		},
+		{
+			name:   "Unsupported type",
+			input:  3.14,
+			output: TimeStamp{},
+			err:    ErrInvalidTimeStamp,
+		},
 	}
 
 	for _, tt := range tests {
@@ -41,7 +76,7 @@ func TestTimeStamp_Scan(t *testing.T) {
 			var ts TimeStamp
 			err := ts.Scan(tt.input)
 
-			if err != tt.err {
+			if !errors.Is(err, tt.err) {
 				t.Fatalf("Unexpected error.\nGot: %v\nExpected: %v", err, tt.err)
 			}
 
@@ -51,3 +86,160 @@ func TestTimeStamp_Scan(t *testing.T) {
 		})
 	}
 }
+
+func TestParse(t *testing.T) {
+	expected := time.Date(2023, 12, 4, 2, 11, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		input  string
+		output time.Time
+		err    error
+	}{
+		{name: "MySQL layout", input: "2023-12-04 02:11:00", output: expected},
+		{name: "MySQL layout with offset", input: "2023-12-04 04:11:00+02:00", output: expected},
+		{name: "RFC 3339 UTC", input: "2023-12-04T02:11:00Z", output: expected},
+		{name: "RFC 3339 offset", input: "2023-12-03T21:11:00-05:00", output: expected},
+		{name: "RFC 3339 nano", input: "2023-12-04T02:11:00.123456789Z", output: expected.Add(123456789)},
+		{name: "No offset", input: "2023-12-04T02:11:00", output: expected},
+		{name: "Epoch seconds", input: "1701655860", output: expected},
+		{name: "Epoch milliseconds", input: "1701655860500", output: expected.Add(500 * time.Millisecond)},
+		{name: "Surrounding space", input: " 2023-12-04 02:11:00 ", output: expected},
+		{name: "Date only", input: "2023-12-04", err: ErrInvalidTimeStamp},
+		{name: "Empty", input: "", err: ErrInvalidTimeStamp},
+		{name: "Garbage", input: "yesterday", err: ErrInvalidTimeStamp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, err := Parse(tt.input)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Unexpected error.\nGot: %v\nExpected: %v", err, tt.err)
+			}
+			if !ts.Time.Equal(tt.output) || (err == nil && ts.Location() != time.UTC) {
+				t.Fatalf("Unexpected TimeStamp value.\nGot: %v\nExpected: %v in UTC", ts.Time, tt.output)
+			}
+		})
+	}
+}
+
+func TestTimeStamp_UnmarshalJSON(t *testing.T) {
+	expected := time.Date(2023, 12, 4, 2, 11, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		input  string
+		output time.Time
+		err    bool
+	}{
+		{name: "MySQL layout", input: `"2023-12-04 02:11:00"`, output: expected},
+		{name: "RFC 3339 offset", input: `"2023-12-04T03:11:00+01:00"`, output: expected},
+		{name: "Epoch seconds number", input: `1701655860`, output: expected},
+		{name: "Epoch milliseconds number", input: `1701655860000`, output: expected},
+		{name: "Null", input: `null`},
+		{name: "Fractional number", input: `1701655860.5`, err: true},
+		{name: "Invalid string", input: `"soon"`, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ts TimeStamp
+			err := ts.UnmarshalJSON([]byte(tt.input))
+			if tt.err != (err != nil) {
+				t.Fatalf("Unexpected error.\nGot: %v\nExpected an error: %v", err, tt.err)
+			}
+			if !ts.Time.Equal(tt.output) {
+				t.Fatalf("Unexpected TimeStamp value.\nGot: %v\nExpected: %v", ts.Time, tt.output)
+			}
+		})
+	}
+}
+
+func TestTimeStamp_MarshalJSON(t *testing.T) {
+	plusOne := time.FixedZone("UTC+1", 60*60)
+
+	tests := []struct {
+		name   string
+		input  TimeStamp
+		output string
+	}{
+		{name: "UTC", input: TimeStamp{Time: time.Date(2023, 12, 4, 2, 11, 0, 0, time.UTC)}, output: `"2023-12-04T02:11:00Z"`},
+		{name: "Offset is converted to UTC", input: TimeStamp{Time: time.Date(2023, 12, 4, 3, 11, 0, 0, plusOne)}, output: `"2023-12-04T02:11:00Z"`},
+		{name: "Fractional seconds", input: TimeStamp{Time: time.Date(2023, 12, 4, 2, 11, 0, 500000000, time.UTC)}, output: `"2023-12-04T02:11:00.5Z"`},
+		{name: "Zero", input: TimeStamp{}, output: `null`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := json.Marshal(tt.input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if string(b) != tt.output {
+				t.Fatalf("Unexpected JSON.\nGot: %s\nExpected: %s", b, tt.output)
+			}
+
+			var roundTripped TimeStamp
+			if err := json.Unmarshal(b, &roundTripped); err != nil {
+				t.Fatalf("Unexpected error reading the JSON back: %v", err)
+			}
+			if !roundTripped.Equal(tt.input.Time) || roundTripped.Location() != time.UTC {
+				t.Fatalf("Unexpected round trip.\nGot: %v\nExpected: %v", roundTripped, tt.input)
+			}
+		})
+	}
+}
+
+func TestTimeStamp_Text(t *testing.T) {
+	input := TimeStamp{Time: time.Date(2023, 12, 4, 3, 11, 0, 0, time.FixedZone("UTC+1", 60*60))}
+
+	text, err := input.MarshalText()
+	if err != nil || string(text) != "2023-12-04T02:11:00Z" {
+		t.Fatalf("Unexpected text.\nGot: %s, %v\nExpected: %s", text, err, "2023-12-04T02:11:00Z")
+	}
+
+	var zero TimeStamp
+	if text, err := zero.MarshalText(); err != nil || len(text) != 0 {
+		t.Fatalf("Expected empty text for the zero TimeStamp.\nGot: %q, %v", text, err)
+	}
+	ts := input
+	if err := ts.UnmarshalText(nil); err != nil || !ts.IsZero() {
+		t.Fatalf("Expected empty text to be the zero TimeStamp.\nGot: %v, %v", ts, err)
+	}
+	if err := ts.UnmarshalText([]byte("soon")); !errors.Is(err, ErrInvalidTimeStamp) {
+		t.Fatalf("Unexpected error.\nGot: %v\nExpected: %v", err, ErrInvalidTimeStamp)
+	}
+}
+
+func TestTimeStamp_Value(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  TimeStamp
+		output driver.Value
+	}{
+		{name: "UTC", input: TimeStamp{Time: time.Date(2023, 12, 4, 2, 11, 0, 0, time.UTC)}, output: "2023-12-04 02:11:00"},
+		{name: "Offset is converted to UTC", input: TimeStamp{Time: time.Date(2023, 12, 3, 21, 11, 0, 0, time.FixedZone("UTC-5", -5*60*60))}, output: "2023-12-04 02:11:00"},
+		{name: "Fractional seconds are dropped", input: TimeStamp{Time: time.Date(2023, 12, 4, 2, 11, 0, 999000000, time.UTC)}, output: "2023-12-04 02:11:00"},
+		{name: "Zero is NULL", input: TimeStamp{}, output: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := tt.input.Value()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if value != tt.output {
+				t.Fatalf("Unexpected value.\nGot: %v\nExpected: %v", value, tt.output)
+			}
+
+			var scanned TimeStamp
+			if err := scanned.Scan(value); err != nil {
+				t.Fatalf("Unexpected error scanning the value back: %v", err)
+			}
+			if !scanned.Equal(tt.input.Truncate(time.Second)) {
+				t.Fatalf("Unexpected round trip.\nGot: %v\nExpected: %v", scanned, tt.input.Truncate(time.Second))
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@
 package util
 
 import (
+	"encoding/json"
 	"reflect"
 	"rescheduler/internals/models"
 	"rescheduler/internals/timestamp"
@@ -18,7 +19,8 @@ func TestConvertJSONToEvent(t *testing.T) {
 		"study_id": "Study5",
 		"questionnaire_id": "24b6f062-df29-4e6a-abb4-403e01671e4a",
 		"completed_at": "2023-12-04 02:11:00",
-		"remaining_completions": 2
+		"remaining_completions": 2,
+		"answers": {"mood": 4,  "notes": "tired"}
 	}`
 
 	// Call the ConvertJSONToEvent function
@@ -37,6 +39,7 @@ func TestConvertJSONToEvent(t *testing.T) {
 		QuestionnaireID:      "24b6f062-df29-4e6a-abb4-403e01671e4a",
 		CompletedAt:          timestamp.TimeStamp{Time: time.Date(2023, 12, 4, 2, 11, 0, 0, time.UTC)},
 		RemainingCompletions: 2,
+		Answers:              json.RawMessage(`{"mood": 4,  "notes": "tired"}`),
 	}
 
 	if !reflect.DeepEqual(event, expectedEvent) {
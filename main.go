@@ -10,33 +10,47 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"time"
+	"log"
 
+	"rescheduler/internals/clock"
+	"rescheduler/internals/config"
 	"rescheduler/internals/database"
+	"rescheduler/internals/idgen"
 	"rescheduler/internals/models"
+	"rescheduler/internals/questions"
+	"rescheduler/internals/rescheduler"
 	"rescheduler/internals/sqs"
 	"rescheduler/internals/store"
-	"rescheduler/internals/timestamp"
 	"rescheduler/internals/util"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/google/uuid"
-	"umotif.com/go/credentials"
 )
 
-type DatabaseConnection struct {
-	Host     string
-	Port     string
-	Database string
-	Username string
-	Password string
-}
+// cfg is the configuration and pool the database connection pool, both set up once per cold start by main.
+// Warm invocations reuse the pool, whose credentials are refreshed in the background.
+var (
+	cfg  *config.Config
+	pool *database.Pool
+)
 
 func main() {
 
-	//credentials.MongoDbDsn() could provide the same result as the below one
+	// Fail fast on missing configuration instead of on the first event
+	var err error
+	cfg, err = config.Load()
+	if err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
+
+	pool, err = openPool(cfg)
+	if err != nil {
+		log.Fatalf("Error connecting to database: %v", err)
+	}
+	defer pool.Close()
 
 	//Example event json. The mapping template and the integrtion settings between API Gateway and the Lambda function is not known at this stage.
 	jsonString := `{
@@ -45,13 +59,13 @@ func main() {
 		"study_id": "Study5",
 		"questionnaire_id": "24b6f062-df29-4e6a-abb4-403e01671e4a",
 		"completed_at": "2023-12-04 02:11:00",
-		"remaining_completions": 2
+		"remaining_completions": 2,
+		"answers": {"mood": 4}
 	}`
 	fmt.Printf("Event data: %s", jsonString+"\n")
 
 	// Creating a variable of type models.QuestionnaireCompletedEvent to handle event json
 	var eventData *models.QuestionnaireCompletedEvent
-	var err error
 
 	eventData, err = util.ConvertJSONToEvent(jsonString)
 	if err != nil {
@@ -65,12 +79,8 @@ func main() {
 }
 
 // LambdaHandler is the AWS Lambda handler function for processing questionnaire completion events.
-// It connects to the database, retrieves information about the completed questionnaire and schedule,
-// updates the schedule status, creates a new schedule if needed, sends SQS messages, and records the questionnaire result.
-//
-// The function uses channels for synchronization to find the questionnaire and schedule simultaneously.
-// Once both tasks are completed, it updates the schedule status asynchronously, creates a new schedule if needed,
-// sends SQS messages, and records the questionnaire result concurrently.
+// It wires the stores backed by the shared connection pool and the SQS handler into a rescheduler.Rescheduler
+// and delegates the event to Rescheduler.HandleCompletion, translating the outcome into an HTTP response.
 //
 // Parameters:
 //   - ctx: A context.Context object.
@@ -80,148 +90,115 @@ func main() {
 //   - An events.APIGatewayProxyResponse indicating the success or failure of the operation.
 //   - An error if there was an internal server error.
 func LambdaHandler(ctx context.Context, event *models.QuestionnaireCompletedEvent) (events.APIGatewayProxyResponse, error) {
-	fmt.Println("Connecting to database")
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeouts.Handler)
+	defer cancel()
 
 	//Create an SQS handler instance
-	sqsHandler := sqs.NewSQSHandler("sqs_url", "aws_region")
-
-	//Connect to database
-	dbConfig := credentials.MySQLDbDsn()
-	db, err := database.InitDB(dbConfig)
+	sqsHandler := sqs.NewSQSHandlerWithQueues(cfg.Queues.NewScheduleURL, cfg.Queues.CompletionURL, cfg.Region)
+	db := pool.DB
+	withDialect := store.WithDialect(cfg.Database.Dialect)
+
+	r, err := rescheduler.New(rescheduler.Stores{
+		Questionnaires:          store.NewQuestionnaireStore(db, withDialect),
+		ScheduledQuestionnaires: store.NewScheduledQuestionnaireStore(db, withDialect),
+		Participants:            store.NewParticipantStore(db, withDialect),
+		QuestionnaireResults:    store.NewQuestionnaireResultStore(db, withDialect),
+		Protocols:               store.NewStudyProtocolStore(db, withDialect),
+		Studies:                 store.NewStudyStore(db, withDialect),
+	}, sqsHandler, clock.System{}, idgen.UUID{})
 	if err != nil {
 		fmt.Println("Error: ", err)
-		return events.APIGatewayProxyResponse{
-			Body:       fmt.Sprintf("Internal server error"),
-			StatusCode: 500,
-		}, err
+		return errorResponse(err), nil
 	}
 
-	fmt.Println("Connected to db: ", dbConfig.Database)
-	defer db.Close()
-
-	// Create instances of the database stores
-	questionnaireStore := store.NewQuestionnaireStore(db)
-	scheduledQuestionnaireStore := store.NewScheduledQuestionnaireStore(db)
-	questionnaireResultStore := store.NewQuestionnaireResultStore(db)
-
-	// Use channels for synchronization
-	resultCh := make(chan struct {
-		*models.Questionnaire
-		*models.ScheduledQuestionnaire
-	})
-	errorCh := make(chan error)
-
-	// Find the schedule and questionnaire simultaneously
-	go func() {
-		questionnaire, err := questionnaireStore.FindQuestionnaireByID(event.QuestionnaireID)
-		if err != nil {
-			errorCh <- err
-			return
-		}
-
-		schedule, err := scheduledQuestionnaireStore.FindScheduledQuestionnaireByQuestionnaireIDAndUserID(
-			event.QuestionnaireID, event.UserID,
-		)
-		if err != nil {
-			errorCh <- err
-			return
-		}
-
-		resultCh <- struct {
-			*models.Questionnaire
-			*models.ScheduledQuestionnaire
-		}{questionnaire, schedule}
-	}()
-
-	// Wait for the goroutine to complete
-	select {
-	case result := <-resultCh:
-		// Both tasks completed successfully
-		questionnaire := result.Questionnaire
-		schedule := result.ScheduledQuestionnaire
-
-		// Update the schedule status to 'completed' asynchronously
-		go func() {
-			schedule.Status = models.ScheduledQuestionnaireCompleted
-			err := scheduledQuestionnaireStore.Update(schedule)
-			if err != nil {
-				fmt.Println("Error updating schedule: ", err)
-			}
-		}()
-
-		//Checking if there are remaining completions or if the max_attempt in the database is NULL
-		if event.RemainingCompletions > 0 || !questionnaire.MaxAttempts.Valid {
-			go func() {
-
-				// Create a new schedule for the same questionnaire
-				nextScheduledTime := timestamp.TimeStamp{
-					Time: event.CompletedAt.Add(
-						time.Duration(questionnaire.HoursBetweenAttempts) * time.Hour,
-					),
-				}
-
-				// Debugging output
-				fmt.Println("Original CompletedAt:", event.CompletedAt)
-				fmt.Println("HoursBetweenAttempts:", questionnaire.HoursBetweenAttempts)
-
-				// Debugging output
-				fmt.Println("Next Scheduled Time:", nextScheduledTime)
-
-				newScheduledQuestionnaire := models.ScheduledQuestionnaire{
-					ID:              uuid.New().String(),
-					QuestionnaireID: questionnaire.ID,
-					ParticipantID:   event.UserID,
-					ScheduledAt:     nextScheduledTime,
-					Status:          models.ScheduledQuestionnairePending,
-				}
-				fmt.Println("Created a new Scheduled Questionnaire:", newScheduledQuestionnaire.ID)
-
-				// Save the new schedule
-				err := scheduledQuestionnaireStore.Create(&newScheduledQuestionnaire)
-				if err != nil {
-					fmt.Println("Error: ", err)
-				}
-
-				fmt.Println("Saved the Scheduled Questionnaire: ", newScheduledQuestionnaire.ID)
-
-				sqsHandler.SendNewScheduleMessage(newScheduledQuestionnaire.ID, event.UserID)
-
-			}()
-
-		} else {
-			go func() {
-				sqsHandler.SendCompletionMessage(event.UserID)
-			}()
-		}
-
-		// Create a questionnaire result asynchronously
-		go func() {
-			questionnaireResult := models.QuestionnaireResult{
-				ID:                      uuid.New().String(),
-				Answers:                 `{"question":"answer"}`,
-				QuestionnaireID:         questionnaire.ID,
-				ParticipantID:           event.UserID,
-				QuestionnaireScheduleID: schedule.ID,
-				CompletedAt:             event.CompletedAt,
-			}
-
-			err := questionnaireResultStore.Create(&questionnaireResult)
-			if err != nil {
-				fmt.Println("Error creating questionnaire result: ", err)
-			}
-		}()
-
-		return events.APIGatewayProxyResponse{
-			Body:       fmt.Sprintf("Success"),
-			StatusCode: 200,
-		}, nil
-
-	case err := <-errorCh:
-		// Handle any error that occurred during finding schedule or questionnaire
+	return handleEvent(ctx, r, event), nil
+}
+
+// handleEvent runs the rescheduler for a single event and maps the outcome to an HTTP response.
+func handleEvent(ctx context.Context, r *rescheduler.Rescheduler, event *models.QuestionnaireCompletedEvent) events.APIGatewayProxyResponse {
+	result, err := r.HandleCompletion(ctx, event)
+	if err != nil {
 		fmt.Println("Error: ", err)
-		return events.APIGatewayProxyResponse{
-			Body:       fmt.Sprintf("Internal server error"),
-			StatusCode: 500,
-		}, nil
+		return errorResponse(err)
+	}
+
+	if result.Duplicate && result.QuestionnaireResult != nil {
+		fmt.Println("Ignoring redelivered completion event: ", event.ID, " recorded by the Questionnaire Result: ", result.QuestionnaireResult.ID)
+	} else if result.Duplicate {
+		fmt.Println("Ignoring duplicate completion of the Scheduled Questionnaire: ", result.CompletedSchedule.ID)
+	}
+	if result.LegacyDefinition != nil {
+		fmt.Println("Accepted the answers as they are, the definition predates the questions format: ", result.LegacyDefinition)
+	}
+	for _, skipped := range result.SkippedFollowUps {
+		fmt.Println("Skipped a follow-up, its link needs fixing: ", skipped)
+	}
+	if result.NextSchedule != nil {
+		fmt.Println("Saved the Scheduled Questionnaire: ", result.NextSchedule.ID, " at ", result.NextSchedule.ScheduledAt)
+	}
+
+	return events.APIGatewayProxyResponse{
+		Body:       "Success",
+		StatusCode: 200,
+	}
+}
+
+// openPool opens the database connection pool with the credential provider selected by the configuration.
+func openPool(cfg *config.Config) (*database.Pool, error) {
+	provider, err := database.NewCredentialProvider(cfg.Database, cfg.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Database)
+	defer cancel()
+
+	fmt.Println("Connecting to database")
+	return database.OpenPool(ctx, cfg.Database.Dialect, provider, cfg.Database.RotationInterval)
+}
+
+// errorResponse maps an error from the rescheduler to an HTTP response, telling the caller whether retrying can help:
+// invalid events and unknown records will never succeed, while conflicts and an unavailable database may.
+func errorResponse(err error) events.APIGatewayProxyResponse {
+	switch {
+	case errors.Is(err, rescheduler.ErrInvalidEvent):
+		// The reason is returned so the sender can fix the event, it holds no more than the event itself.
+		return badRequest(err)
+	case errors.Is(err, rescheduler.ErrStudyClosed), errors.Is(err, rescheduler.ErrParticipantInactive):
+		return events.APIGatewayProxyResponse{Body: "Conflict: " + err.Error(), StatusCode: 409}
+	case errors.Is(err, store.ErrNotFound):
+		return events.APIGatewayProxyResponse{Body: "Not found", StatusCode: 404}
+	case errors.Is(err, store.ErrConflict), errors.Is(err, store.ErrDuplicate):
+		return events.APIGatewayProxyResponse{Body: "Conflict", StatusCode: 409}
+	case errors.Is(err, store.ErrUnavailable):
+		return events.APIGatewayProxyResponse{Body: "Service unavailable", StatusCode: 503}
+	default:
+		return events.APIGatewayProxyResponse{Body: "Internal server error", StatusCode: 500}
+	}
+}
+
+// badRequest builds the response to an invalid event. Answers that do not match the questionnaire are
+// reported as JSON with the problem of every question, so the sender can show them next to the questions:
+//
+//	{"message": "Bad request: ...", "errors": [{"question_id": "mood", "message": "an answer is required"}]}
+//
+// Other problems are reported as plain text.
+func badRequest(err error) events.APIGatewayProxyResponse {
+	var answersErr *questions.AnswersError
+	if !errors.As(err, &answersErr) {
+		return events.APIGatewayProxyResponse{Body: "Bad request: " + err.Error(), StatusCode: 400}
+	}
+
+	body, marshalErr := json.Marshal(struct {
+		Message string                  `json:"message"`
+		Errors  []questions.AnswerError `json:"errors"`
+	}{"Bad request: " + err.Error(), answersErr.Errors})
+	if marshalErr != nil {
+		return events.APIGatewayProxyResponse{Body: "Bad request: " + err.Error(), StatusCode: 400}
+	}
+	return events.APIGatewayProxyResponse{
+		Body:       string(body),
+		StatusCode: 400,
+		Headers:    map[string]string{"Content-Type": "application/json"},
 	}
 }